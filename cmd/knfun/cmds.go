@@ -0,0 +1,111 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"github.com/maximilien/knfun/funcs/gvision"
+	"github.com/maximilien/knfun/funcs/mastodon"
+	"github.com/maximilien/knfun/funcs/nlu"
+	"github.com/maximilien/knfun/funcs/reddit"
+	"github.com/maximilien/knfun/funcs/rss"
+	"github.com/maximilien/knfun/funcs/summary"
+	"github.com/maximilien/knfun/funcs/twitter"
+	"github.com/maximilien/knfun/funcs/watson"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	knfunFn *KnfunFn
+)
+
+// KnfunFn has the global flags of the knfun command, which the funcs' own
+// flags of the same name override
+type KnfunFn struct {
+	common.CommonFn
+	common.LoggingFn
+}
+
+func NewKnfunCmd() *cobra.Command {
+	knfunFn = &KnfunFn{}
+
+	// before the funcs' own, so that their --config flag takes precedence
+	cobra.OnInitialize(knfunFn.InitConfig, knfunFn.initLogging)
+
+	knfunCmd := &cobra.Command{
+		Use:   "knfun",
+		Short: "knfun root command",
+		Long: `All the knfun funcs in a single binary, as subcommands, and served
+together from one process with the serve command`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	knfunCmd.PersistentFlags().StringVar(&knfunFn.CfgFile, "config", "", "config file (default is $HOME/.knfun.yaml)")
+	knfunCmd.PersistentFlags().StringVarP(&knfunFn.Output, "output", "o", "text", common.OutputUsage())
+	knfunFn.AddLoggingCmdFlags(knfunCmd)
+
+	knfunCmd.AddCommand(twitter.NewTwitterCmd())
+	knfunCmd.AddCommand(mastodon.NewMastodonCmd())
+	knfunCmd.AddCommand(reddit.NewRedditCmd())
+	knfunCmd.AddCommand(rss.NewRSSCmd())
+	knfunCmd.AddCommand(watson.NewWatsonCmd())
+	knfunCmd.AddCommand(gvision.NewGVisionCmd())
+	knfunCmd.AddCommand(nlu.NewNLUCmd())
+	knfunCmd.AddCommand(summary.NewSummaryCmd())
+
+	knfunCmd.AddCommand(knfunFn.newServeCmd())
+	knfunCmd.AddCommand(newDeployCmd(viper.GetViper()))
+	knfunCmd.AddCommand(knfunFn.newVersionCmd())
+
+	return knfunCmd
+}
+
+func Execute() error {
+	return NewKnfunCmd().Execute()
+}
+
+// Private
+
+func (knfunFn *KnfunFn) newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version",
+		Long:  `Prints the version, build date, and git revision of the knfun binary`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			versionInfo := common.NewVersionInfo()
+			return common.PrintOutput(os.Stdout, &versionInfo, knfunFn.Output, versionInfo.ToText)
+		},
+	}
+}
+
+func (knfunFn *KnfunFn) initLogging() {
+	knfunFn.InitLoggingInputFlags()
+
+	err := knfunFn.InitLogging()
+	if err != nil {
+		fmt.Printf("Error opening log file: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	servingAPIVersion = "serving.knative.dev/v1"
+	partOfLabel       = "app.kubernetes.io/part-of"
+	healthzPath       = "/healthz"
+
+	defaultImagePrefix          = "docker.io/drmax"
+	defaultMaxScale             = 10
+	defaultContainerConcurrency = 10
+)
+
+// DeployFn generates the Knative Serving Service manifests of the funcs and
+// the Secret manifests of their credentials from the config file
+type DeployFn struct {
+	Funcs                []string
+	Namespace            string
+	ImagePrefix          string
+	Tag                  string
+	MinScale             int
+	MaxScale             int
+	ScaleTarget          int
+	ContainerConcurrency int
+
+	// Values are the config values of the env variables of the funcs
+	Values map[string]string
+}
+
+// DeployedFunc is a func deployed as the NAME-fn service with the env variables
+// its start.sh passes as flags
+type DeployedFunc struct {
+	Name string
+	Env  []FuncEnv
+}
+
+// FuncEnv is an env variable of a func set from a config value, referenced in
+// the func's Secret when Secret, read from the file it names when File, or the
+// cluster-local URL of the func Func when deployed along
+type FuncEnv struct {
+	Name      string
+	ConfigKey string
+	Secret    bool
+	File      bool
+	Func      string
+}
+
+var deployedFuncs = []DeployedFunc{
+	{Name: "twitter", Env: []FuncEnv{
+		{Name: "TWITTER_API_KEY", ConfigKey: "twitter-api-key", Secret: true},
+		{Name: "TWITTER_API_SECRET_KEY", ConfigKey: "twitter-api-secret-key", Secret: true},
+		{Name: "TWITTER_ACCESS_TOKEN", ConfigKey: "twitter-access-token", Secret: true},
+		{Name: "TWITTER_ACCESS_TOKEN_SECRET", ConfigKey: "twitter-access-token-secret", Secret: true},
+	}},
+	{Name: "mastodon", Env: []FuncEnv{
+		{Name: "MASTODON_INSTANCE_URL", ConfigKey: "mastodon-instance-url"},
+		{Name: "MASTODON_ACCESS_TOKEN", ConfigKey: "mastodon-access-token", Secret: true},
+	}},
+	{Name: "reddit"},
+	{Name: "rss", Env: []FuncEnv{
+		{Name: "RSS_FEED_URLS", ConfigKey: "rss-feed-urls"},
+	}},
+	{Name: "watson", Env: []FuncEnv{
+		{Name: "WATSON_API_KEY", ConfigKey: "watson-api-key", Secret: true},
+		{Name: "WATSON_API_URL", ConfigKey: "watson-api-url"},
+		{Name: "WATSON_API_VERSION", ConfigKey: "watson-api-version"},
+	}},
+	{Name: "gvision", Env: []FuncEnv{
+		{Name: "GVISION_API_JSON", ConfigKey: "gvision-api-json", Secret: true, File: true},
+	}},
+	{Name: "nlu", Env: []FuncEnv{
+		{Name: "NLU_BACKEND", ConfigKey: "nlu-backend"},
+		{Name: "WATSON_NLU_API_KEY", ConfigKey: "watson-nlu-api-key", Secret: true},
+		{Name: "WATSON_NLU_API_URL", ConfigKey: "watson-nlu-api-url"},
+	}},
+	{Name: "summary", Env: []FuncEnv{
+		{Name: "TWITTER_FN_URL", ConfigKey: "twitter-fn-url", Func: "twitter"},
+		{Name: "WATSON_FN_URL", ConfigKey: "watson-fn-url", Func: "watson"},
+	}},
+}
+
+// ObjectMeta and the types below are the subset of the Kubernetes and Knative
+// Serving API types of the generated manifests
+type ObjectMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type Secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+type Service struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   ObjectMeta  `yaml:"metadata"`
+	Spec       ServiceSpec `yaml:"spec"`
+}
+
+type ServiceSpec struct {
+	Template RevisionTemplate `yaml:"template"`
+}
+
+type RevisionTemplate struct {
+	Metadata ObjectMeta   `yaml:"metadata"`
+	Spec     RevisionSpec `yaml:"spec"`
+}
+
+type RevisionSpec struct {
+	ContainerConcurrency int         `yaml:"containerConcurrency"`
+	Containers           []Container `yaml:"containers"`
+}
+
+type Container struct {
+	Image          string   `yaml:"image"`
+	Env            []EnvVar `yaml:"env,omitempty"`
+	ReadinessProbe Probe    `yaml:"readinessProbe"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	SecretKeyRef SecretKeySelector `yaml:"secretKeyRef"`
+}
+
+type SecretKeySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type Probe struct {
+	HTTPGet HTTPGetAction `yaml:"httpGet"`
+}
+
+type HTTPGetAction struct {
+	Path string `yaml:"path"`
+}
+
+// Generate writes the manifests of the funcs as a multi-document YAML stream,
+// each func's Secret, if it has credentials, followed by its Service
+func (deployFn *DeployFn) Generate(writer io.Writer) error {
+	manifests := []interface{}{}
+	for _, name := range deployFn.funcs() {
+		deployedFunc, ok := deployedFunc(name)
+		if !ok {
+			return fmt.Errorf("unknown func '%s', expected one of: %s", name, strings.Join(deployedFuncNames(), ", "))
+		}
+
+		secret, service, err := deployFn.manifests(deployedFunc)
+		if err != nil {
+			return err
+		}
+
+		if secret != nil {
+			manifests = append(manifests, secret)
+		}
+		manifests = append(manifests, service)
+	}
+
+	for i, manifest := range manifests {
+		data, err := yaml.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			_, err = io.WriteString(writer, "---\n")
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = writer.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Private
+
+func newDeployCmd(config *viper.Viper) *cobra.Command {
+	deployFn := &DeployFn{}
+
+	deployCmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "Deploy the funcs to Knative",
+		Long:  `Generates the manifests to deploy the funcs to a Knative cluster`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the Knative Service manifests of the funcs",
+		Long: fmt.Sprintf(`Generates the Knative Serving Service manifests of the funcs, and the Secret
+manifests of their credentials, from the config file and the flags, or the
+deploy section of the config file, e.g., to apply them with kubectl apply -f -.
+Each service is named NAME-fn, reads its credentials from Secret references,
+and is ready when its /healthz endpoint responds. The summary func calls the
+twitter and watson funcs deployed along with their cluster-local URLs.
+The funcs are: %s`, strings.Join(deployedFuncNames(), ", ")),
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			deployFn.loadConfig(config)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return deployFn.Generate(cmd.OutOrStdout())
+		},
+	}
+
+	generateCmd.Flags().StringSlice("funcs", []string{}, "the funcs to deploy (default all)")
+	generateCmd.Flags().String("namespace", "default", "the namespace of the services and secrets")
+	generateCmd.Flags().String("image-prefix", defaultImagePrefix, "the registry and user of the images, which are named NAME-fn")
+	generateCmd.Flags().String("tag", "latest", "the tag of the images")
+	generateCmd.Flags().Int("min-scale", 0, "the min number of replicas of each service")
+	generateCmd.Flags().Int("max-scale", defaultMaxScale, "the max number of replicas of each service, 0 for unlimited")
+	generateCmd.Flags().Int("scale-target", 0, "the concurrent requests per replica the autoscaler targets (default the cluster's)")
+	generateCmd.Flags().Int("container-concurrency", defaultContainerConcurrency, "the max concurrent requests per replica, 0 for unlimited")
+
+	for _, name := range []string{"funcs", "namespace", "image-prefix", "tag", "min-scale", "max-scale", "scale-target", "container-concurrency"} {
+		config.BindPFlag(fmt.Sprintf("deploy.%s", name), generateCmd.Flags().Lookup(name))
+	}
+
+	deployCmd.AddCommand(generateCmd)
+
+	return deployCmd
+}
+
+// loadConfig reads the settings, which are the flags when set, then the deploy
+// section of the config file, then the flags' defaults, and the config values of
+// the funcs
+func (deployFn *DeployFn) loadConfig(config *viper.Viper) {
+	deployFn.Funcs = config.GetStringSlice("deploy.funcs")
+	deployFn.Namespace = config.GetString("deploy.namespace")
+	deployFn.ImagePrefix = config.GetString("deploy.image-prefix")
+	deployFn.Tag = config.GetString("deploy.tag")
+	deployFn.MinScale = config.GetInt("deploy.min-scale")
+	deployFn.MaxScale = config.GetInt("deploy.max-scale")
+	deployFn.ScaleTarget = config.GetInt("deploy.scale-target")
+	deployFn.ContainerConcurrency = config.GetInt("deploy.container-concurrency")
+
+	deployFn.Values = map[string]string{}
+	for _, deployedFunc := range deployedFuncs {
+		for _, funcEnv := range deployedFunc.Env {
+			deployFn.Values[funcEnv.ConfigKey] = configValue(config, funcEnv.ConfigKey)
+		}
+	}
+}
+
+func (deployFn *DeployFn) funcs() []string {
+	if len(deployFn.Funcs) == 0 {
+		return deployedFuncNames()
+	}
+	return deployFn.Funcs
+}
+
+func (deployFn *DeployFn) manifests(deployedFunc DeployedFunc) (*Secret, Service, error) {
+	name := fmt.Sprintf("%s-fn", deployedFunc.Name)
+	secretName := fmt.Sprintf("%s-credentials", name)
+	labels := map[string]string{partOfLabel: "knfun"}
+
+	env, secretData := []EnvVar{}, map[string]string{}
+	for _, funcEnv := range deployedFunc.Env {
+		value, err := deployFn.envValue(funcEnv)
+		if err != nil {
+			return nil, Service{}, fmt.Errorf("error reading %s of %s func: %s", funcEnv.ConfigKey, deployedFunc.Name, err.Error())
+		}
+
+		if value == "" {
+			continue
+		}
+
+		if funcEnv.Secret {
+			secretData[funcEnv.ConfigKey] = value
+			env = append(env, EnvVar{Name: funcEnv.Name, ValueFrom: &EnvVarSource{SecretKeyRef: SecretKeySelector{Name: secretName, Key: funcEnv.ConfigKey}}})
+		} else {
+			env = append(env, EnvVar{Name: funcEnv.Name, Value: value})
+		}
+	}
+
+	service := Service{
+		APIVersion: servingAPIVersion,
+		Kind:       "Service",
+		Metadata:   ObjectMeta{Name: name, Namespace: deployFn.Namespace, Labels: labels},
+		Spec: ServiceSpec{
+			Template: RevisionTemplate{
+				Metadata: ObjectMeta{Annotations: deployFn.autoscalingAnnotations()},
+				Spec: RevisionSpec{
+					ContainerConcurrency: deployFn.ContainerConcurrency,
+					Containers: []Container{{
+						Image:          fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(deployFn.ImagePrefix, "/"), name, deployFn.Tag),
+						Env:            env,
+						ReadinessProbe: Probe{HTTPGet: HTTPGetAction{Path: healthzPath}},
+					}},
+				},
+			},
+		},
+	}
+
+	if len(secretData) == 0 {
+		return nil, service, nil
+	}
+
+	return &Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   ObjectMeta{Name: secretName, Namespace: deployFn.Namespace, Labels: labels},
+		Type:       "Opaque",
+		StringData: secretData,
+	}, service, nil
+}
+
+func (deployFn *DeployFn) envValue(funcEnv FuncEnv) (string, error) {
+	if funcEnv.Func != "" && contains(deployFn.funcs(), funcEnv.Func) {
+		return fmt.Sprintf("http://%s-fn.%s.svc.cluster.local", funcEnv.Func, deployFn.Namespace), nil
+	}
+
+	value := deployFn.Values[funcEnv.ConfigKey]
+	if funcEnv.File && value != "" && !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		content, err := ioutil.ReadFile(value)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+	return value, nil
+}
+
+func (deployFn *DeployFn) autoscalingAnnotations() map[string]string {
+	annotations := map[string]string{
+		"autoscaling.knative.dev/minScale": fmt.Sprintf("%d", deployFn.MinScale),
+	}
+
+	if deployFn.MaxScale > 0 {
+		annotations["autoscaling.knative.dev/maxScale"] = fmt.Sprintf("%d", deployFn.MaxScale)
+	}
+
+	if deployFn.ScaleTarget > 0 {
+		annotations["autoscaling.knative.dev/target"] = fmt.Sprintf("%d", deployFn.ScaleTarget)
+	}
+	return annotations
+}
+
+func deployedFunc(name string) (DeployedFunc, bool) {
+	for _, deployedFunc := range deployedFuncs {
+		if deployedFunc.Name == name {
+			return deployedFunc, true
+		}
+	}
+	return DeployedFunc{}, false
+}
+
+func deployedFuncNames() []string {
+	names := []string{}
+	for _, deployedFunc := range deployedFuncs {
+		names = append(names, deployedFunc.Name)
+	}
+	return names
+}
+
+// configValue is the config value as a string, with the items of a list, e.g.,
+// of rss-feed-urls, separated by commas as in the flags
+func configValue(config *viper.Viper, key string) string {
+	if values, ok := config.Get(key).([]interface{}); ok {
+		items := []string{}
+		for _, value := range values {
+			items = append(items, fmt.Sprintf("%v", value))
+		}
+		return strings.Join(items, ",")
+	}
+	return config.GetString(key)
+}
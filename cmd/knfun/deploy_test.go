@@ -0,0 +1,67 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gotest.tools/assert"
+	"gotest.tools/golden"
+)
+
+func TestDeployGenerate(t *testing.T) {
+	for _, tc := range []struct {
+		golden string
+		args   []string
+	}{
+		{golden: "deploy-all.golden", args: []string{}},
+		{golden: "deploy-summary.golden", args: []string{"--funcs", "summary,watson", "--namespace", "default", "--scale-target", "5", "--max-scale", "0"}},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			out := bytes.Buffer{}
+			deployCmd := newDeployCmd(testConfig(t))
+			deployCmd.SetOut(&out)
+			deployCmd.SetArgs(append([]string{"generate"}, tc.args...))
+
+			assert.NilError(t, deployCmd.Execute())
+			golden.Assert(t, out.String(), tc.golden)
+		})
+	}
+}
+
+func TestDeployGenerateErrors(t *testing.T) {
+	deployFn := &DeployFn{}
+	deployFn.loadConfig(testConfig(t))
+
+	deployFn.Funcs = []string{"twitter", "tiktok"}
+	err := deployFn.Generate(&bytes.Buffer{})
+	assert.ErrorContains(t, err, "unknown func 'tiktok', expected one of: twitter, mastodon")
+
+	deployFn.Funcs = []string{"gvision"}
+	deployFn.Values["gvision-api-json"] = "testdata/missing.json"
+	err = deployFn.Generate(&bytes.Buffer{})
+	assert.ErrorContains(t, err, "error reading gvision-api-json of gvision func")
+}
+
+// Private
+
+func testConfig(t *testing.T) *viper.Viper {
+	config := viper.New()
+	config.SetConfigFile("testdata/knfun.yaml")
+	assert.NilError(t, config.ReadInConfig())
+	return config
+}
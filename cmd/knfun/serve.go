@@ -0,0 +1,186 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/gvision"
+	"github.com/maximilien/knfun/funcs/mastodon"
+	"github.com/maximilien/knfun/funcs/nlu"
+	"github.com/maximilien/knfun/funcs/reddit"
+	"github.com/maximilien/knfun/funcs/rss"
+	"github.com/maximilien/knfun/funcs/summary"
+	"github.com/maximilien/knfun/funcs/twitter"
+	"github.com/maximilien/knfun/funcs/watson"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const summaryFunc = "summary"
+
+// ServedFunc is a func served by the serve command on the /NAME/ route, but
+// the summary func served on / since its pages link to its other routes
+type ServedFunc struct {
+	Name    string
+	Handler func(ctx context.Context) (http.Handler, error)
+}
+
+var servedFuncs = []ServedFunc{
+	{Name: "twitter", Handler: twitter.Handler},
+	{Name: "mastodon", Handler: mastodon.Handler},
+	{Name: "reddit", Handler: reddit.Handler},
+	{Name: "rss", Handler: rss.Handler},
+	{Name: "watson", Handler: watson.Handler},
+	{Name: "gvision", Handler: gvision.Handler},
+	{Name: "nlu", Handler: nlu.Handler},
+	{Name: summaryFunc, Handler: summary.Handler},
+}
+
+// summaryFuncURLs are the summary config keys of the URLs of the other funcs
+// it calls, defaulting to the routes of the same server when they are served
+var summaryFuncURLs = map[string]string{
+	"twitter": "twitter-fn-url",
+	"watson":  "watson-fn-url",
+	"nlu":     "nlu-fn-url",
+}
+
+// Private
+
+func (knfunFn *KnfunFn) newServeCmd() *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:   "serve [FUNC...]",
+		Short: "Serve several funcs from one process",
+		Long: fmt.Sprintf(`Serves the FUNCs, or all of them, from one process for local development,
+each on its own route, e.g., /twitter/ and /watson/, and the summary func on /.
+Unless their URLs are configured, the summary func calls the twitter, watson,
+and nlu funcs served by the same process. FUNC is one of: %s`, strings.Join(servedFuncNames(), ", ")),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if _, ok := servedFunc(name); !ok {
+					return fmt.Errorf("unknown func '%s', expected one of: %s", name, strings.Join(servedFuncNames(), ", "))
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = servedFuncNames()
+			}
+			return knfunFn.serve(args)
+		},
+	}
+
+	serveCmd.Flags().IntVarP(&knfunFn.Port, "port", "p", 8080, "the port for the server")
+
+	return serveCmd
+}
+
+func (knfunFn *KnfunFn) serve(names []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler, err := knfunFn.serveMux(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving %s funcs on port %d", strings.Join(names, ", "), knfunFn.Port)
+	return knfunFn.ListenAndServe(handler)
+}
+
+// serveMux routes the requests to the funcs, whose handlers are created after
+// setting the defaults of the URLs of the funcs the summary func calls
+func (knfunFn *KnfunFn) serveMux(ctx context.Context, names []string) (http.Handler, error) {
+	for _, name := range names {
+		if key, ok := summaryFuncURLs[name]; ok {
+			viper.SetDefault(key, fmt.Sprintf("http://localhost:%d%s", knfunFn.Port, funcRoute(name)))
+		}
+	}
+
+	mux := http.NewServeMux()
+	served, routes := []string{}, []string{}
+
+	for _, name := range names {
+		if contains(served, name) {
+			continue
+		}
+
+		servedFunc, _ := servedFunc(name)
+		handler, err := servedFunc.Handler(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error serving %s func: %s", name, err.Error())
+		}
+
+		route := funcRoute(name)
+		if route == "/" {
+			mux.Handle(route, handler)
+		} else {
+			mux.Handle(route, http.StripPrefix(strings.TrimSuffix(route, "/"), handler))
+		}
+
+		served = append(served, name)
+		routes = append(routes, fmt.Sprintf("%s-fn: %s", name, route))
+	}
+
+	if !contains(served, summaryFunc) {
+		mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path != "/" {
+				http.NotFound(writer, request)
+				return
+			}
+			fmt.Fprintf(writer, "%s\n", strings.Join(routes, "\n"))
+		})
+	}
+
+	return mux, nil
+}
+
+func servedFunc(name string) (ServedFunc, bool) {
+	for _, servedFunc := range servedFuncs {
+		if servedFunc.Name == name {
+			return servedFunc, true
+		}
+	}
+	return ServedFunc{}, false
+}
+
+func servedFuncNames() []string {
+	names := []string{}
+	for _, servedFunc := range servedFuncs {
+		names = append(names, servedFunc.Name)
+	}
+	return names
+}
+
+func funcRoute(name string) string {
+	if name == summaryFunc {
+		return "/"
+	}
+	return fmt.Sprintf("/%s/", name)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
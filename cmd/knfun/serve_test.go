@@ -0,0 +1,68 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gotest.tools/assert"
+)
+
+func TestServeMux(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	knfunFn := &KnfunFn{}
+	handler, err := knfunFn.serveMux(ctx, []string{"nlu", "nlu"})
+	assert.NilError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/nlu/?q=I+love+this+great+day&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	analysis := map[string]interface{}{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &analysis))
+	assert.Equal(t, analysis["backend"], "lexicon")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "nlu-fn: /nlu/\n")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/twitter/", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestServeMuxSummary(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	knfunFn := &KnfunFn{}
+	knfunFn.Port = 9090
+	handler, err := knfunFn.serveMux(ctx, []string{"twitter", "summary"})
+	assert.NilError(t, err)
+	assert.Equal(t, viper.GetString("twitter-fn-url"), "http://localhost:9090/twitter/")
+	assert.Equal(t, viper.GetString("watson-fn-url"), "")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/static/wordcloud.css", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+}
@@ -17,10 +17,12 @@ package main
 import (
 	"fmt"
 	"os"
+
+	"github.com/maximilien/knfun/funcs/reddit"
 )
 
 func main() {
-	err := Execute()
+	err := reddit.Execute()
 	if err != nil {
 		handleErr(err)
 	}
@@ -30,7 +32,7 @@ func main() {
 
 func handleErr(err error) {
 	if err != nil {
-		fmt.Printf(err.Error())
+		fmt.Printf("%s\n", err.Error())
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,132 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client has the typed Go clients the funcs use to call each other,
+// sharing the encoding of the query parameters, the headers, the checking of
+// the response status, and the decoding of the responses
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	userAgent = "knfun-client"
+
+	maxErrorBodyLength = 200
+)
+
+// Client calls the func at URL, whose own query parameters are kept and
+// overridden by the ones of each request, with the extra Headers, e.g., to
+// authenticate
+type Client struct {
+	Name       string
+	URL        string
+	Headers    map[string]string
+	HTTPClient *http.Client
+}
+
+// StatusError is the error of the requests with a non 2xx response status
+type StatusError struct {
+	Name       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func NewClient(name string, funcURL string, timeout int) Client {
+	return Client{
+		Name: name,
+		URL:  funcURL,
+		HTTPClient: &http.Client{
+			Timeout: time.Second * time.Duration(timeout),
+		},
+	}
+}
+
+// Get requests the func with the query parameters and decodes its JSON
+// response into out
+func (client Client) Get(ctx context.Context, params url.Values, out interface{}) error {
+	funcURL, err := url.Parse(client.URL)
+	if err != nil {
+		return err
+	}
+
+	query := funcURL.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	funcURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, funcURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	for name, value := range client.Headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &StatusError{
+			Name:       client.Name,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Body:       truncate(strings.TrimSpace(string(body)), maxErrorBodyLength),
+		}
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		return fmt.Errorf("error decoding %s response: %s", client.Name, err.Error())
+	}
+	return nil
+}
+
+func (err *StatusError) Error() string {
+	if err.Body == "" {
+		return fmt.Sprintf("%s responded with %s", err.Name, err.Status)
+	}
+	return fmt.Sprintf("%s responded with %s: %s", err.Name, err.Status, err.Body)
+}
+
+// Private functions
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "..."
+}
@@ -0,0 +1,178 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"gotest.tools/assert"
+)
+
+func TestTwitterClientSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		assert.Equal(t, query.Get("q"), "#NBA & finals?")
+		assert.Equal(t, query.Get("c"), "5")
+		assert.Equal(t, query.Get("o"), "json")
+		assert.Equal(t, query.Get("since-id"), "42")
+		assert.Equal(t, query.Get("lang"), "fr")
+		assert.Equal(t, query.Get("extra"), "yes")
+		assert.Equal(t, request.Header.Get("Accept"), "application/json")
+		assert.Equal(t, request.Header.Get("User-Agent"), userAgent)
+
+		fmt.Fprint(writer, `[{"id": "43", "text": "t43", "image-urls": ["http://img/1.jpg"], "created-at": "2019-10-01T10:00:00Z"}]`)
+	}))
+	defer server.Close()
+
+	twitterClient := NewTwitterClient(server.URL+"?lang=fr", 5)
+	tweets, err := twitterClient.Search(context.Background(), SearchParams{
+		Query:   "#NBA & finals?",
+		Count:   5,
+		SinceID: "42",
+		Params:  map[string]string{"extra": "yes"},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tweets, []Tweet{Tweet{ID: "43", Text: "t43", ImageURLs: []string{"http://img/1.jpg"}, CreatedAt: "2019-10-01T10:00:00Z"}})
+}
+
+func TestClientStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewTwitterClient(server.URL, 5).Search(context.Background(), SearchParams{Query: "NBA"})
+	assert.Error(t, err, "TwitterFn responded with 429 Too Many Requests: rate limited")
+
+	statusError := &StatusError{}
+	assert.Assert(t, errors.As(err, &statusError))
+	assert.Equal(t, statusError.StatusCode, http.StatusTooManyRequests)
+}
+
+func TestClientDecodingError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, "<html>not json</html>")
+	}))
+	defer server.Close()
+
+	_, err := NewNLUClient(server.URL, 5).Analyze(context.Background(), "text")
+	assert.ErrorContains(t, err, "error decoding NLUFn response")
+}
+
+func TestWatsonClientClassify(t *testing.T) {
+	imageURL := "http://img/1.jpg?size=large&v=2"
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		assert.Equal(t, query.Get("q"), imageURL)
+		assert.Equal(t, query.Get("max"), "3")
+		assert.Equal(t, query.Get("threshold"), "0.5")
+
+		fmt.Fprintf(writer, `{
+			"source_url": %q,
+			"classifiers": [
+				{"name": "default", "classifier_id": "default", "classes": [{"class": "ball", "score": 0.9}]},
+				{"name": "food", "classifier_id": "food", "classes": [{"class": "non-food", "score": 0.8}]}
+			]
+		}`, imageURL)
+	}))
+	defer server.Close()
+
+	classifiedImage, err := NewWatsonClient(server.URL, 5).Classify(context.Background(), imageURL, common.ClassifyFn{MaxLabels: 3, MinScore: 0.5})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, classifiedImage, ClassifiedImage{
+		ImageURL: imageURL,
+		Labels:   []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "non-food", Score: 0.8}},
+	})
+}
+
+func TestWatsonClientClassifyLabels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `{"ImageURL": "http://img/1.jpg", "labels": [{"name": "ball", "score": 0.9}]}`)
+	}))
+	defer server.Close()
+
+	classifiedImage, err := NewWatsonClient(server.URL, 5).Classify(context.Background(), "http://img/1.jpg", common.ClassifyFn{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, classifiedImage.Labels, []Label{Label{Name: "ball", Score: 0.9}})
+}
+
+func TestWatsonClientClassifyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `{"source_url": "http://img/1.jpg", "error": {"code": 400, "description": "image too large"}, "classifiers": []}`)
+	}))
+	defer server.Close()
+
+	_, err := NewWatsonClient(server.URL, 5).Classify(context.Background(), "http://img/1.jpg", common.ClassifyFn{})
+	assert.Error(t, err, "WatsonFn could not classify 'http://img/1.jpg': image too large")
+}
+
+func TestGVisionClientDetectLabels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, request.URL.Path, "/labels")
+		assert.Equal(t, request.URL.Query().Get("q"), "http://img/1.jpg")
+		fmt.Fprint(writer, `{"ImageURL": "http://img/1.jpg", "Labels": [{"name": "Basketball", "score": 0.97}]}`)
+	}))
+	defer server.Close()
+
+	classifiedImage, err := NewGVisionClient(server.URL+"/labels", 5).DetectLabels(context.Background(), "http://img/1.jpg", common.ClassifyFn{MaxLabels: 10})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, classifiedImage, ClassifiedImage{ImageURL: "http://img/1.jpg", Labels: []Label{Label{Name: "Basketball", Score: 0.97}}})
+}
+
+func TestNLUClientAnalyze(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, request.URL.Query().Get("q"), "what a dunk! #NBA")
+		fmt.Fprint(writer, `{"text": "what a dunk! #NBA", "backend": "lexicon", "sentiment": {"label": "positive", "score": 0.6}, "keywords": [{"text": "dunk", "relevance": 1}]}`)
+	}))
+	defer server.Close()
+
+	textAnalysis, err := NewNLUClient(server.URL, 5).Analyze(context.Background(), "what a dunk! #NBA")
+	assert.NilError(t, err)
+	assert.Equal(t, textAnalysis.Sentiment, Sentiment{Label: "positive", Score: 0.6})
+	assert.DeepEqual(t, textAnalysis.Keywords, []Keyword{Keyword{Text: "dunk", Relevance: 1}})
+}
+
+func TestClientKeepsURLParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.DeepEqual(t, request.URL.Query(), url.Values{"backend": []string{"watson"}, "q": []string{"text"}, "o": []string{"json"}})
+		fmt.Fprint(writer, `{}`)
+	}))
+	defer server.Close()
+
+	_, err := NewNLUClient(server.URL+"?backend=watson&o=yaml", 5).Analyze(context.Background(), "text")
+	assert.NilError(t, err)
+}
+
+func TestClientHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, request.Header.Get("Authorization"), "Bearer token")
+		assert.Equal(t, request.Header.Get("Accept"), "application/json")
+		fmt.Fprint(writer, `{"ImageURL": "http://img/1.jpg", "labels": []}`)
+	}))
+	defer server.Close()
+
+	watsonClient := NewWatsonClient(server.URL, 5)
+	watsonClient.Headers = map[string]string{"Authorization": "Bearer token"}
+	_, err := watsonClient.Classify(context.Background(), "http://img/1.jpg", common.ClassifyFn{})
+	assert.NilError(t, err)
+}
@@ -0,0 +1,47 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+// GVisionClient detects image labels with the `dl` command of gvision-fn, or
+// its `/labels` route
+type GVisionClient struct {
+	Client
+}
+
+func NewGVisionClient(gvisionFnURL string, timeout int) *GVisionClient {
+	return &GVisionClient{Client: NewClient("GVisionFn", gvisionFnURL, timeout)}
+}
+
+func (gvisionClient *GVisionClient) DetectLabels(ctx context.Context, imageURL string, classifyFn common.ClassifyFn) (ClassifiedImage, error) {
+	classifiedImage := ClassifiedImage{}
+	err := gvisionClient.Get(ctx, classifyParams(imageURL, classifyFn), &classifiedImage)
+	if err != nil {
+		return ClassifiedImage{}, err
+	}
+
+	if classifiedImage.ImageURL == "" {
+		classifiedImage.ImageURL = imageURL
+	}
+	if classifiedImage.Labels == nil {
+		classifiedImage.Labels = []Label{}
+	}
+	return classifiedImage, nil
+}
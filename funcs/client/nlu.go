@@ -0,0 +1,43 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/url"
+)
+
+// NLUClient analyzes the sentiment, keywords, and entities of texts with
+// nlu-fn
+type NLUClient struct {
+	Client
+}
+
+func NewNLUClient(nluFnURL string, timeout int) *NLUClient {
+	return &NLUClient{Client: NewClient("NLUFn", nluFnURL, timeout)}
+}
+
+func (nluClient *NLUClient) Analyze(ctx context.Context, text string) (TextAnalysis, error) {
+	params := url.Values{}
+	params.Set("q", text)
+	params.Set("o", "json")
+
+	textAnalysis := TextAnalysis{}
+	err := nluClient.Get(ctx, params, &textAnalysis)
+	if err != nil {
+		return TextAnalysis{}, err
+	}
+	return textAnalysis, nil
+}
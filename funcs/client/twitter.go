@@ -0,0 +1,60 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// TwitterClient searches twitter-fn, or mastodon-fn, reddit-fn, and rss-fn
+// which have the same search API
+type TwitterClient struct {
+	Client
+}
+
+type SearchParams struct {
+	Query   string
+	Count   int
+	SinceID string
+
+	// Params are extra query parameters, overriding the ones above
+	Params map[string]string
+}
+
+func NewTwitterClient(twitterFnURL string, timeout int) *TwitterClient {
+	return &TwitterClient{Client: NewClient("TwitterFn", twitterFnURL, timeout)}
+}
+
+func (twitterClient *TwitterClient) Search(ctx context.Context, searchParams SearchParams) ([]Tweet, error) {
+	params := url.Values{}
+	params.Set("q", searchParams.Query)
+	params.Set("c", strconv.Itoa(searchParams.Count))
+	params.Set("o", "json")
+	if searchParams.SinceID != "" {
+		params.Set("since-id", searchParams.SinceID)
+	}
+	for name, value := range searchParams.Params {
+		params.Set(name, value)
+	}
+
+	tweets := []Tweet{}
+	err := twitterClient.Get(ctx, params, &tweets)
+	if err != nil {
+		return []Tweet{}, err
+	}
+	return tweets, nil
+}
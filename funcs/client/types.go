@@ -0,0 +1,57 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+// Tweet is a search result of twitter-fn, or of the funcs with the same
+// search API, with the name of its content source once collected
+type Tweet struct {
+	ID        string   `yaml:"id,omitempty" json:"id,omitempty"`
+	Text      string   `yaml:"text" json:"text"`
+	Source    string   `yaml:"source" json:"source"`
+	ImageURLs []string `yaml:"image-urls" json:"image-urls"`
+	CreatedAt string   `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+}
+
+type ClassifiedImage struct {
+	ImageURL string  `yaml:"ImageURL" json:"ImageURL"`
+	Labels   []Label `yaml:"labels" json:"labels"`
+}
+
+type Label struct {
+	Name  string  `yaml:"name" json:"name"`
+	Score float32 `yaml:"score" json:"score"`
+}
+
+type TextAnalysis struct {
+	Sentiment Sentiment `yaml:"sentiment" json:"sentiment"`
+	Keywords  []Keyword `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+	Entities  []Entity  `yaml:"entities,omitempty" json:"entities,omitempty"`
+}
+
+type Sentiment struct {
+	Label string  `yaml:"label" json:"label"`
+	Score float64 `yaml:"score" json:"score"`
+}
+
+type Keyword struct {
+	Text      string  `yaml:"text" json:"text"`
+	Relevance float64 `yaml:"relevance" json:"relevance"`
+}
+
+type Entity struct {
+	Text      string  `yaml:"text" json:"text"`
+	Type      string  `yaml:"type" json:"type"`
+	Relevance float64 `yaml:"relevance" json:"relevance"`
+}
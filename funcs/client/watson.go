@@ -0,0 +1,105 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+// WatsonClient classifies images with watson-fn
+type WatsonClient struct {
+	Client
+}
+
+// watsonClassifyData is the subset of the watson-fn response with the classes
+// of each classifier. The labels are also decoded so that responses in the
+// labels format, e.g., from gvision-fn, are kept as is
+type watsonClassifyData struct {
+	ImageURL    string                   `json:"ImageURL"`
+	Labels      []Label                  `json:"labels"`
+	SourceURL   string                   `json:"source_url"`
+	ResolvedURL string                   `json:"resolved_url"`
+	Classifiers []watsonClassifierResult `json:"classifiers"`
+	Error       *watsonError             `json:"error"`
+}
+
+type watsonClassifierResult struct {
+	Classes []watsonClassResult `json:"classes"`
+}
+
+type watsonClassResult struct {
+	Class string  `json:"class"`
+	Score float32 `json:"score"`
+}
+
+type watsonError struct {
+	Description string `json:"description"`
+}
+
+func NewWatsonClient(watsonFnURL string, timeout int) *WatsonClient {
+	return &WatsonClient{Client: NewClient("WatsonFn", watsonFnURL, timeout)}
+}
+
+// Classify returns the classes of all the classifiers as the image labels
+func (watsonClient *WatsonClient) Classify(ctx context.Context, imageURL string, classifyFn common.ClassifyFn) (ClassifiedImage, error) {
+	classifyData := watsonClassifyData{}
+	err := watsonClient.Get(ctx, classifyParams(imageURL, classifyFn), &classifyData)
+	if err != nil {
+		return ClassifiedImage{}, err
+	}
+
+	if classifyData.Error != nil {
+		return ClassifiedImage{}, fmt.Errorf("%s could not classify '%s': %s", watsonClient.Name, imageURL, classifyData.Error.Description)
+	}
+
+	classifiedImage := ClassifiedImage{
+		ImageURL: firstNonEmpty(classifyData.ImageURL, classifyData.SourceURL, classifyData.ResolvedURL, imageURL),
+		Labels:   classifyData.Labels,
+	}
+	for _, classifier := range classifyData.Classifiers {
+		for _, class := range classifier.Classes {
+			classifiedImage.Labels = append(classifiedImage.Labels, Label{Name: class.Class, Score: class.Score})
+		}
+	}
+	if classifiedImage.Labels == nil {
+		classifiedImage.Labels = []Label{}
+	}
+	return classifiedImage, nil
+}
+
+// Private functions
+
+func classifyParams(imageURL string, classifyFn common.ClassifyFn) url.Values {
+	params := url.Values{}
+	params.Set("q", imageURL)
+	params.Set("o", "json")
+	params.Set("max", strconv.Itoa(classifyFn.MaxLabels))
+	params.Set("threshold", strconv.FormatFloat(classifyFn.MinScore, 'f', -1, 64))
+	return params
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -18,30 +18,60 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
 type ToTextFunc = func(in interface{}) string
 
-func ToYAML(in interface{}) string {
+var (
+	htmlTagsRegexp   = regexp.MustCompile(`<[^>]*>`)
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+func ToYAML(in interface{}) (string, error) {
 	yData, err := yaml.Marshal(in)
 	if err != nil {
-		panic("error YAML marshalling Data")
+		return "", fmt.Errorf("error YAML marshalling data: %s", err.Error())
 	}
-	return string(yData)
+	return string(yData), nil
 }
 
-func ToJSON(in interface{}) string {
+func ToJSON(in interface{}) (string, error) {
 	jData, err := json.MarshalIndent(in, "", "  ")
 	if err != nil {
-		panic("error JSON marshalling Data")
+		return "", fmt.Errorf("error JSON marshalling data: %s", err.Error())
 	}
-	return string(jData)
+	return string(jData), nil
+}
+
+// ToNDJSON is a compact JSON document per line, one for each item of a list,
+// e.g., each tweet of a search, to pipe them to line based tools like jq
+func ToNDJSON(in interface{}) (string, error) {
+	value := reflect.Indirect(reflect.ValueOf(in))
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return toJSONLine(in)
+	}
+
+	lines := []string{}
+	for i := 0; i < value.Len(); i++ {
+		line, err := toJSONLine(value.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
 }
 
 func ToText(in interface{}) string {
@@ -50,19 +80,15 @@ func ToText(in interface{}) string {
 	return sb.String()
 }
 
-func Flatten(in interface{}, output string, toText ToTextFunc) string {
-	outputData := ""
-	switch output {
-	case "yaml":
-		outputData = ToYAML(in)
-		break
-	case "json":
-		outputData = ToJSON(in)
-		break
-	default:
-		outputData = toText(in)
-	}
-	return outputData
+// Flatten formats the data in the output, e.g., `json` or `jsonpath=TEMPLATE`,
+// with the formatter of the output, failing for an unknown output, a missing
+// argument or an invalid template
+func Flatten(in interface{}, output string, toText ToTextFunc) (string, error) {
+	formatter, argument, err := lookupFormatter(output, false)
+	if err != nil {
+		return "", err
+	}
+	return formatter.Format(in, argument, toText)
 }
 
 func FileExists(filename string) bool {
@@ -73,20 +99,14 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func CreateTmpFile(content string) (string, error) {
-	file, err := ioutil.TempFile("", "knfun")
-	if err != nil {
-		return "", err
-	}
-
-	out, err := os.Create(file.Name())
-	if err != nil {
-		return "", err
+// ValidateHTTPURL checks the value, e.g., the `q` image URL of a server, is an
+// http or https URL, and not a file path the server would read
+func ValidateHTTPURL(value string) error {
+	parsedURL, err := url.Parse(value)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return fmt.Errorf("invalid URL '%s', expected an http or https URL", value)
 	}
-	defer out.Close()
-
-	_, err = out.WriteString(content)
-	return file.Name(), err
+	return nil
 }
 
 func DownloadTmpFile(url string) (string, error) {
@@ -115,3 +135,51 @@ func DownloadFile(filepath string, url string) error {
 	_, err = io.Copy(out, resp.Body)
 	return err
 }
+
+func FetchURL(url string, headers map[string]string, timeout int) ([]byte, error) {
+	client := http.Client{
+		Timeout: time.Second * time.Duration(timeout),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return []byte{}, fmt.Errorf("error fetching '%s': %s", url, res.Status)
+	}
+
+	return body, nil
+}
+
+func StripHTML(content string) string {
+	text := htmlTagsRegexp.ReplaceAllString(content, " ")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(text, " "))
+}
+
+// Private
+
+func toJSONLine(in interface{}) (string, error) {
+	jData, err := json.Marshal(in)
+	if err != nil {
+		return "", fmt.Errorf("error JSON marshalling data: %s", err.Error())
+	}
+	return string(jData), nil
+}
@@ -0,0 +1,85 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// CORSFn lists the origins of the pages allowed to call a func from the
+// browser, none by default, or `*` for any
+type CORSFn struct {
+	AllowedOrigins []string
+}
+
+func (corsFn *CORSFn) AddCORSCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSliceVar(&corsFn.AllowedOrigins, "allowed-origins", []string{}, "the origins allowed to call the func from a browser, e.g., https://summary.example.com, or * for any (default none)")
+
+	viper.BindPFlag("allowed-origins", cmd.PersistentFlags().Lookup("allowed-origins"))
+}
+
+func (corsFn *CORSFn) InitCORSInputFlags() {
+	if len(corsFn.AllowedOrigins) == 0 {
+		corsFn.AllowedOrigins = viper.GetStringSlice("allowed-origins")
+	}
+}
+
+// WriteCORSHeaders allows the request's origin when it is one of the allowed
+// origins, and returns true when it answered the preflight request, which the
+// handler must then not answer
+func (corsFn *CORSFn) WriteCORSHeaders(writer http.ResponseWriter, request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	allowedOrigin := corsFn.allowedOrigin(origin)
+	if allowedOrigin != "*" {
+		writer.Header().Add("Vary", "Origin")
+	}
+	if allowedOrigin == "" {
+		return false
+	}
+
+	writer.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+	writer.Header().Set("Access-Control-Allow-Headers", "x-requested-with")
+
+	if request.Method != http.MethodOptions || request.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+
+	writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	writer.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// Private CORSFn
+
+func (corsFn *CORSFn) allowedOrigin(origin string) string {
+	for _, allowedOrigin := range corsFn.AllowedOrigins {
+		allowedOrigin = strings.TrimSuffix(strings.TrimSpace(allowedOrigin), "/")
+		if allowedOrigin == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowedOrigin, origin) {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestWriteCORSHeaders(t *testing.T) {
+	corsFn := CORSFn{AllowedOrigins: []string{"https://summary.example.com/"}}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/?q=http://img/1.jpg", nil)
+	request.Header.Set("Origin", "https://summary.example.com")
+	assert.Assert(t, !corsFn.WriteCORSHeaders(recorder, request))
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "https://summary.example.com")
+	assert.Equal(t, recorder.Header().Get("Vary"), "Origin")
+
+	recorder = httptest.NewRecorder()
+	request.Header.Set("Origin", "https://evil.example.com")
+	assert.Assert(t, !corsFn.WriteCORSHeaders(recorder, request))
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "")
+
+	recorder = httptest.NewRecorder()
+	assert.Assert(t, !(&CORSFn{}).WriteCORSHeaders(recorder, request))
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestWriteCORSHeadersPreflight(t *testing.T) {
+	corsFn := CORSFn{AllowedOrigins: []string{"*"}}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("OPTIONS", "/", nil)
+	request.Header.Set("Origin", "https://summary.example.com")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	assert.Assert(t, corsFn.WriteCORSHeaders(recorder, request))
+	assert.Equal(t, recorder.Code, http.StatusNoContent)
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "*")
+	assert.Equal(t, recorder.Header().Get("Vary"), "")
+}
@@ -25,6 +25,10 @@ import (
 	homedir "github.com/mitchellh/go-homedir"
 )
 
+// defaultTimeout is the default of the seconds before the calls to the other
+// funcs and the remote APIs time out
+const defaultTimeout = 30
+
 type CommonFn struct {
 	CfgFile string
 
@@ -36,6 +40,14 @@ type CommonFn struct {
 	Timeout     int
 	StartServer bool
 	Port        int
+
+	Stdin       bool
+	Concurrency int
+}
+
+type ClassifyFn struct {
+	MaxLabels int
+	MinScore  float64
 }
 
 func (commonFn *CommonFn) AddCommonCmdFlags(cmd *cobra.Command) {
@@ -44,10 +56,14 @@ func (commonFn *CommonFn) AddCommonCmdFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&commonFn.SearchString, "search-string", "s", "", "the string to search for")
 	cmd.Flags().IntVarP(&commonFn.Count, "count", "c", 10, "the max number of results")
 
-	cmd.Flags().StringVarP(&commonFn.Output, "output", "o", "text", "the output: text, yaml, or json, of results")
+	cmd.Flags().StringVarP(&commonFn.Output, "output", "o", "text", OutputUsage())
+	cmd.Flags().IntVar(&commonFn.Timeout, "timeout", defaultTimeout, "the seconds before the calls to the other funcs and the remote APIs time out")
 
 	cmd.Flags().BoolVarP(&commonFn.StartServer, "start-server", "S", false, "start as a server")
 	cmd.Flags().IntVarP(&commonFn.Port, "port", "p", 8080, "the port for the server")
+
+	cmd.Flags().BoolVar(&commonFn.Stdin, "stdin", false, "read the inputs from the lines of the standard input, e.g., piped from another func")
+	cmd.Flags().IntVar(&commonFn.Concurrency, "concurrency", defaultConcurrency, "the max number of lines of the standard input processed at the same time")
 }
 
 func (commonFn *CommonFn) InitCommonInputFlags(args []string) error {
@@ -55,7 +71,7 @@ func (commonFn *CommonFn) InitCommonInputFlags(args []string) error {
 		commonFn.SearchString = args[0]
 	}
 
-	if commonFn.SearchString == "" {
+	if commonFn.SearchString == "" && !commonFn.Stdin {
 		return errors.New("you must pass a search string")
 	}
 
@@ -63,6 +79,11 @@ func (commonFn *CommonFn) InitCommonInputFlags(args []string) error {
 }
 
 func (commonFn *CommonFn) InitConfig() {
+	if viper.ConfigFileUsed() != "" && (commonFn.CfgFile == "" || commonFn.CfgFile == viper.ConfigFileUsed()) {
+		// already read by another func of the knfun command
+		return
+	}
+
 	if commonFn.CfgFile != "" {
 		viper.SetConfigFile(commonFn.CfgFile)
 	} else {
@@ -79,6 +100,22 @@ func (commonFn *CommonFn) InitConfig() {
 
 	err := viper.ReadInConfig()
 	if err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	}
+}
+
+func (classifyFn *ClassifyFn) AddClassifyCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&classifyFn.MaxLabels, "max-labels", 10, "the max number of labels per image")
+	cmd.Flags().Float64Var(&classifyFn.MinScore, "min-score", 0.0, "the min score (confidence) of labels, between 0.0 and 1.0")
+}
+
+// Accept returns whether the label at the index, among the accepted ones, is
+// within the max labels, when set, and has at least the min score. The scores
+// are compared as float32, the precision of the vision APIs, so that a 0.9
+// score is accepted with a 0.9 min score
+func (classifyFn *ClassifyFn) Accept(index int, score float64) bool {
+	if classifyFn.MaxLabels > 0 && index >= classifyFn.MaxLabels {
+		return false
 	}
+	return float32(score) >= float32(classifyFn.MinScore)
 }
@@ -0,0 +1,56 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gotest.tools/assert"
+)
+
+func TestClassifyFnAccept(t *testing.T) {
+	for _, test := range []struct {
+		classifyFn ClassifyFn
+		index      int
+		score      float64
+		accepted   bool
+	}{
+		{classifyFn: ClassifyFn{MaxLabels: 2, MinScore: 0.5}, index: 0, score: 0.5, accepted: true},
+		{classifyFn: ClassifyFn{MaxLabels: 2, MinScore: 0.5}, index: 0, score: 0.499, accepted: false},
+		{classifyFn: ClassifyFn{MaxLabels: 2, MinScore: 0.5}, index: 1, score: 0.9, accepted: true},
+		{classifyFn: ClassifyFn{MaxLabels: 2, MinScore: 0.5}, index: 2, score: 0.9, accepted: false},
+		{classifyFn: ClassifyFn{MaxLabels: 0, MinScore: 0.5}, index: 100, score: 0.5, accepted: true},
+		{classifyFn: ClassifyFn{MaxLabels: -1}, index: 100, score: 0.0, accepted: true},
+		{classifyFn: ClassifyFn{}, index: 0, score: 0.0, accepted: true},
+		{classifyFn: ClassifyFn{MinScore: 1.0}, index: 0, score: 1.0, accepted: true},
+		{classifyFn: ClassifyFn{MinScore: 0.9}, index: 0, score: float64(float32(0.9)), accepted: true},
+		{classifyFn: ClassifyFn{MinScore: 0.9}, index: 0, score: float64(float32(0.89)), accepted: false},
+	} {
+		name := fmt.Sprintf("max=%d,min=%g,index=%d,score=%g", test.classifyFn.MaxLabels, test.classifyFn.MinScore, test.index, test.score)
+		assert.Equal(t, test.classifyFn.Accept(test.index, test.score), test.accepted, name)
+	}
+}
+
+func TestAddCommonCmdFlagsTimeout(t *testing.T) {
+	commonFn := &CommonFn{}
+	cmd := &cobra.Command{}
+	commonFn.AddCommonCmdFlags(cmd)
+	assert.Equal(t, commonFn.Timeout, defaultTimeout)
+
+	assert.NilError(t, cmd.ParseFlags([]string{"--timeout", "5"}))
+	assert.Equal(t, commonFn.Timeout, 5)
+}
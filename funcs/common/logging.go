@@ -0,0 +1,71 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// LoggingFn sends the logs of the funcs to stderr by default, appends them to
+// LogFile, or discards them when Quiet
+type LoggingFn struct {
+	LogFile string
+	Quiet   bool
+}
+
+func (loggingFn *LoggingFn) AddLoggingCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&loggingFn.LogFile, "log-file", "", "append the logs to this file (default stderr)")
+	cmd.PersistentFlags().BoolVar(&loggingFn.Quiet, "quiet", false, "discard the logs")
+
+	viper.BindPFlag("log-file", cmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("quiet", cmd.PersistentFlags().Lookup("quiet"))
+}
+
+func (loggingFn *LoggingFn) InitLoggingInputFlags() {
+	if loggingFn.LogFile == "" {
+		loggingFn.LogFile = viper.GetString("log-file")
+	}
+
+	if !loggingFn.Quiet {
+		loggingFn.Quiet = viper.GetBool("quiet")
+	}
+}
+
+// InitLogging sets the output of the standard logger, which all the funcs log
+// with, from the flags
+func (loggingFn *LoggingFn) InitLogging() error {
+	if loggingFn.Quiet {
+		log.SetOutput(ioutil.Discard)
+		return nil
+	}
+
+	if loggingFn.LogFile == "" {
+		log.SetOutput(os.Stderr)
+		return nil
+	}
+
+	logFile, err := os.OpenFile(loggingFn.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	log.SetOutput(logFile)
+	return nil
+}
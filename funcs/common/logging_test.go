@@ -0,0 +1,47 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestInitLogging(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+
+	logFile := filepath.Join(t.TempDir(), "knfun.log")
+	loggingFn := LoggingFn{LogFile: logFile}
+	assert.NilError(t, loggingFn.InitLogging())
+	log.Print("logged")
+
+	loggingFn = LoggingFn{LogFile: logFile, Quiet: true}
+	assert.NilError(t, loggingFn.InitLogging())
+	log.Print("discarded")
+
+	content, err := ioutil.ReadFile(logFile)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(content), "logged"))
+	assert.Assert(t, !strings.Contains(string(content), "discarded"))
+
+	loggingFn = LoggingFn{LogFile: filepath.Join(logFile, "missing", "knfun.log")}
+	assert.Assert(t, loggingFn.InitLogging() != nil)
+}
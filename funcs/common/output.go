@@ -0,0 +1,491 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// FormatFunc formats the data of a func, given the argument of the output,
+// e.g., the template of `go-template=TEMPLATE`, and the func's text format
+type FormatFunc = func(in interface{}, argument string, toText ToTextFunc) (string, error)
+
+// Formatter is an output of the funcs, for the `-o` flag and the `o` query
+// parameter, with the content type of its HTTP responses
+type Formatter struct {
+	Format      FormatFunc
+	ContentType string
+
+	// Argument names the argument the output requires, e.g., TEMPLATE for
+	// `go-template=TEMPLATE`, or is empty when it takes none
+	Argument string
+
+	// CLIOnly rejects the output in the `o` query parameter of the servers,
+	// e.g., `go-template-file=FILE` that reads the files of the server
+	CLIOnly bool
+}
+
+const textContentType = "text/plain; charset=utf-8"
+
+var formatters = map[string]Formatter{
+	"text":   {Format: formatText, ContentType: textContentType},
+	"yaml":   {Format: formatYAML, ContentType: "application/yaml"},
+	"json":   {Format: formatJSON, ContentType: "application/json"},
+	"ndjson": {Format: formatNDJSON, ContentType: "application/x-ndjson"},
+
+	"csv":      {Format: formatCSV, ContentType: "text/csv; charset=utf-8"},
+	"markdown": {Format: formatMarkdown, ContentType: "text/markdown; charset=utf-8"},
+
+	"go-template":      {Format: formatGoTemplate, ContentType: textContentType, Argument: "TEMPLATE"},
+	"go-template-file": {Format: formatGoTemplateFile, ContentType: textContentType, Argument: "FILE", CLIOnly: true},
+	"jsonpath":         {Format: formatJSONPath, ContentType: textContentType, Argument: "TEMPLATE"},
+	"custom-columns":   {Format: formatCustomColumns, ContentType: textContentType, Argument: "HEADER:JSONPATH,..."},
+}
+
+// RegisterFormatter adds an output to all the funcs, or replaces one
+func RegisterFormatter(name string, formatter Formatter) {
+	formatters[name] = formatter
+}
+
+// SupportedOutputs lists the outputs, with their argument, if any, e.g.,
+// `jsonpath=TEMPLATE`
+func SupportedOutputs() []string {
+	outputs := []string{}
+	for name, formatter := range formatters {
+		if formatter.Argument != "" {
+			name = fmt.Sprintf("%s=%s", name, formatter.Argument)
+		}
+		outputs = append(outputs, name)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// OutputUsage is the usage of the `-o` flags, listing the outputs, e.g., html,
+// the func supports besides the formatters
+func OutputUsage(outputs ...string) string {
+	return fmt.Sprintf("the output of results, one of: %s", strings.Join(append(outputs, SupportedOutputs()...), ", "))
+}
+
+// PrintOutput writes the data formatted in the output, e.g., to the standard output
+func PrintOutput(writer io.Writer, in interface{}, output string, toText ToTextFunc) error {
+	outputData, err := Flatten(in, output, toText)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "%s\n", outputData)
+	return err
+}
+
+// RespondOutput responds the data formatted in the output, with its content
+// type and the status, or a bad request when the output can't format it
+func RespondOutput(writer http.ResponseWriter, status int, in interface{}, output string, toText ToTextFunc) {
+	formatter, argument, err := lookupFormatter(output, true)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outputData, err := formatter.Format(in, argument, toText)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Add("Content-Type", OutputContentType(output))
+	writer.WriteHeader(status)
+	fmt.Fprintf(writer, "%s\n", outputData)
+}
+
+// OutputContentType is the content type of the output, or html for the
+// outputs that aren't formatters, e.g., the summary pages
+func OutputContentType(output string) string {
+	formatter, _, err := lookupFormatter(output, true)
+	if err != nil {
+		return "text/html; charset=utf-8"
+	}
+	return formatter.ContentType
+}
+
+// Private
+
+// lookupFormatter looks up the formatter of the output and its argument,
+// rejecting the CLI only outputs for the servers
+func lookupFormatter(output string, server bool) (Formatter, string, error) {
+	name, argument := output, ""
+	if output == "" {
+		name = "text"
+	}
+	if index := strings.Index(output, "="); index >= 0 {
+		name, argument = output[:index], output[index+1:]
+	}
+
+	formatter, ok := formatters[name]
+	if !ok {
+		return Formatter{}, "", fmt.Errorf("unknown output '%s', expected one of: %s", name, strings.Join(SupportedOutputs(), ", "))
+	}
+
+	if formatter.Argument != "" && argument == "" {
+		return Formatter{}, "", fmt.Errorf("the %s output requires an argument, e.g., -o %s=%s", name, name, formatter.Argument)
+	}
+
+	if formatter.Argument == "" && argument != "" {
+		return Formatter{}, "", fmt.Errorf("the %s output takes no argument", name)
+	}
+
+	if server && formatter.CLIOnly {
+		return Formatter{}, "", fmt.Errorf("the %s output is only available on the command line", name)
+	}
+
+	return formatter, argument, nil
+}
+
+func formatText(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	if toText == nil {
+		return ToText(in), nil
+	}
+	return toText(in), nil
+}
+
+func formatYAML(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	return ToYAML(in)
+}
+
+func formatJSON(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	return ToJSON(in)
+}
+
+func formatNDJSON(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	return ToNDJSON(in)
+}
+
+func formatCSV(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	columns, rows, err := toTable(in)
+	if err != nil {
+		return "", err
+	}
+
+	sb := bytes.NewBufferString("")
+	writer := csv.NewWriter(sb)
+	writer.Write(columns)
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func formatMarkdown(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	columns, rows, err := toTable(in)
+	if err != nil {
+		return "", err
+	}
+
+	escaper := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	markdownRow := func(cells []string) string {
+		escapedCells := []string{}
+		for _, cell := range cells {
+			escapedCells = append(escapedCells, escaper.Replace(cell))
+		}
+		return fmt.Sprintf("| %s |", strings.Join(escapedCells, " | "))
+	}
+
+	separators := []string{}
+	for range columns {
+		separators = append(separators, "---")
+	}
+
+	lines := []string{markdownRow(columns), markdownRow(separators)}
+	for _, row := range rows {
+		lines = append(lines, markdownRow(row))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func formatGoTemplate(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	outputTemplate, err := template.New("output").Parse(argument)
+	if err != nil {
+		return "", fmt.Errorf("error parsing the go-template: %s", err.Error())
+	}
+
+	data, err := toGeneric(in)
+	if err != nil {
+		return "", err
+	}
+
+	sb := bytes.NewBufferString("")
+	err = outputTemplate.Execute(sb, data)
+	if err != nil {
+		return "", fmt.Errorf("error executing the go-template: %s", err.Error())
+	}
+	return sb.String(), nil
+}
+
+func formatGoTemplateFile(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	content, err := ioutil.ReadFile(argument)
+	if err != nil {
+		return "", fmt.Errorf("error reading the go-template file: %s", err.Error())
+	}
+	return formatGoTemplate(in, string(content), toText)
+}
+
+func formatJSONPath(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	parser, err := parseJSONPath("output", argument)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := toGeneric(in)
+	if err != nil {
+		return "", err
+	}
+
+	sb := bytes.NewBufferString("")
+	err = parser.Execute(sb, data)
+	if err != nil {
+		return "", fmt.Errorf("error executing the jsonpath: %s", err.Error())
+	}
+	return sb.String(), nil
+}
+
+// formatCustomColumns formats kubectl's custom columns, e.g.,
+// `ID:.id,IMAGES:.image-urls[*]`, with a row per item of a list
+func formatCustomColumns(in interface{}, argument string, toText ToTextFunc) (string, error) {
+	headers, parsers := []string{}, []*jsonpath.JSONPath{}
+	for _, column := range strings.Split(argument, ",") {
+		parts := strings.SplitN(column, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", fmt.Errorf("invalid custom column '%s', expected HEADER:JSONPATH", column)
+		}
+
+		parser, err := parseJSONPath(parts[0], parts[1])
+		if err != nil {
+			return "", err
+		}
+		headers, parsers = append(headers, parts[0]), append(parsers, parser)
+	}
+
+	items, err := toGenericItems(in)
+	if err != nil {
+		return "", err
+	}
+
+	sb := bytes.NewBufferString("")
+	writer := tabwriter.NewWriter(sb, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(headers, "\t"))
+	for _, item := range items {
+		cells := []string{}
+		for _, parser := range parsers {
+			results, err := parser.FindResults(item)
+			if err != nil {
+				return "", fmt.Errorf("error executing the jsonpath: %s", err.Error())
+			}
+
+			values := []string{}
+			for _, result := range results {
+				for _, value := range result {
+					values = append(values, cellValue(value.Interface()))
+				}
+			}
+			if len(values) == 0 {
+				values = append(values, "<none>")
+			}
+			cells = append(cells, strings.Join(values, ","))
+		}
+		fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+	writer.Flush()
+
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// parseJSONPath parses kubectl's jsonpath templates, where the braces of a
+// single expression are optional, e.g., `.text` for `{.text}`
+func parseJSONPath(name string, path string) (*jsonpath.JSONPath, error) {
+	if !strings.Contains(path, "{") {
+		path = fmt.Sprintf("{%s}", path)
+	}
+
+	parser := jsonpath.New(name)
+	parser.AllowMissingKeys(true)
+	err := parser.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the jsonpath '%s': %s", path, err.Error())
+	}
+	return parser, nil
+}
+
+// toGeneric converts the data to its JSON form, i.e., maps, lists and values
+// named and tagged like in the json output, for the templates and jsonpaths
+func toGeneric(in interface{}) (interface{}, error) {
+	jData, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("error JSON marshalling data: %s", err.Error())
+	}
+	return decodeGeneric(jData)
+}
+
+// toGenericItems converts the items of a list, or the data as a single item
+func toGenericItems(in interface{}) ([]interface{}, error) {
+	data, err := toGeneric(in)
+	if err != nil {
+		return nil, err
+	}
+
+	switch data := data.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case []interface{}:
+		return data, nil
+	}
+	return []interface{}{data}, nil
+}
+
+// toTable converts the items of a list, or the data as a single item, to the
+// rows of a table whose columns are the JSON fields of the items in order
+func toTable(in interface{}) ([]string, [][]string, error) {
+	jData, err := json.Marshal(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error JSON marshalling data: %s", err.Error())
+	}
+
+	rawItems := []json.RawMessage{}
+	if json.Unmarshal(jData, &rawItems) != nil {
+		rawItems = []json.RawMessage{jData}
+	}
+
+	columns, items := []string{}, []map[string]json.RawMessage{}
+	for _, rawItem := range rawItems {
+		fields := map[string]json.RawMessage{}
+		if json.Unmarshal(rawItem, &fields) != nil {
+			items = append(items, map[string]json.RawMessage{"value": rawItem})
+			columns = appendMissing(columns, "value")
+			continue
+		}
+
+		keys, err := objectKeys(rawItem)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, fields)
+		columns = appendMissing(columns, keys...)
+	}
+
+	rows := [][]string{}
+	for _, item := range items {
+		row := []string{}
+		for _, column := range columns {
+			row = append(row, rawCellValue(item[column]))
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+func decodeGeneric(jData []byte) (interface{}, error) {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jData))
+	decoder.UseNumber()
+	err := decoder.Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("error JSON unmarshalling data: %s", err.Error())
+	}
+	return data, nil
+}
+
+// objectKeys lists the keys of a JSON object in their order
+func objectKeys(jData []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jData))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return nil, errors.New("error reading the JSON object keys")
+		}
+		keys = append(keys, key)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func appendMissing(values []string, newValues ...string) []string {
+	for _, newValue := range newValues {
+		found := false
+		for _, value := range values {
+			if value == newValue {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, newValue)
+		}
+	}
+	return values
+}
+
+// cellValue is the text of a value in a table, with the lists and objects in
+// compact JSON
+func cellValue(value interface{}) string {
+	jData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return rawCellValue(jData)
+}
+
+func rawCellValue(jData json.RawMessage) string {
+	var text string
+	if json.Unmarshal(jData, &text) == nil {
+		return text
+	}
+
+	if len(jData) == 0 || string(jData) == "null" {
+		return ""
+	}
+
+	sb := bytes.NewBufferString("")
+	if json.Compact(sb, jData) != nil {
+		return string(jData)
+	}
+	return sb.String()
+}
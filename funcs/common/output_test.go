@@ -0,0 +1,168 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type outputItem struct {
+	ID        string            `json:"id"`
+	Text      string            `json:"text"`
+	ImageURLs []string          `json:"image-urls"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type outputItems []outputItem
+
+func (items outputItems) ToText(in interface{}) string {
+	return "items as text"
+}
+
+var testOutputItems = outputItems{
+	{ID: "1", Text: "first | tweet", ImageURLs: []string{"http://images/1.png", "http://images/2.png"}},
+	{ID: "2", Text: "second, tweet", ImageURLs: []string{}, Labels: map[string]string{"z": "last", "a": "first"}},
+}
+
+func TestFlatten(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "output.tmpl")
+	assert.NilError(t, ioutil.WriteFile(templateFile, []byte(`{{range .}}{{.id}}{{"\n"}}{{end}}`), 0644))
+
+	for _, tc := range []struct {
+		output   string
+		expected string
+	}{
+		{"", "items as text"},
+		{"text", "items as text"},
+		{"json", "[\n  {\n    \"id\": \"1\","},
+		{"csv", `id,text,image-urls,labels
+1,first | tweet,"[""http://images/1.png"",""http://images/2.png""]",
+2,"second, tweet",[],"{""a"":""first"",""z"":""last""}"`},
+		{"markdown", `| id | text | image-urls | labels |
+| --- | --- | --- | --- |
+| 1 | first \| tweet | ["http://images/1.png","http://images/2.png"] |  |
+| 2 | second, tweet | [] | {"a":"first","z":"last"} |`},
+		{"go-template={{range .}}{{.id}}:{{len (index . \"image-urls\")}} {{end}}", "1:2 2:0 "},
+		{"go-template-file=" + templateFile, "1\n2\n"},
+		{`jsonpath={range [*]}{.id}={.text}{"\n"}{end}`, "1=first | tweet\n2=second, tweet\n"},
+		{"jsonpath=$[0].image-urls[1]", "http://images/2.png"},
+		{"custom-columns=ID:.id,IMAGES:.image-urls[*],LABEL:.labels.a", `ID        IMAGES                                    LABEL
+1         http://images/1.png,http://images/2.png   <none>
+2         <none>                                    first`},
+	} {
+		t.Run(tc.output, func(t *testing.T) {
+			outputData, err := Flatten(testOutputItems, tc.output, testOutputItems.ToText)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.HasPrefix(outputData, tc.expected), outputData)
+		})
+	}
+}
+
+func TestFlattenSingleItem(t *testing.T) {
+	outputData, err := Flatten(&testOutputItems[0], "csv", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, outputData, "id,text,image-urls\n1,first | tweet,\"[\"\"http://images/1.png\"\",\"\"http://images/2.png\"\"]\"")
+
+	outputData, err = Flatten(testOutputItems[1], "custom-columns=TEXT:.text", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, outputData, "TEXT\nsecond, tweet")
+
+	outputData, err = Flatten([]string{"a", "b"}, "markdown", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, outputData, "| value |\n| --- |\n| a |\n| b |")
+}
+
+func TestFlattenErrors(t *testing.T) {
+	for _, tc := range []struct {
+		output string
+		err    string
+	}{
+		{"xml", "unknown output 'xml', expected one of: csv, custom-columns=HEADER:JSONPATH,..., go-template-file=FILE, go-template=TEMPLATE, json, jsonpath=TEMPLATE, markdown, ndjson, text, yaml"},
+		{"jsonpath", "the jsonpath output requires an argument, e.g., -o jsonpath=TEMPLATE"},
+		{"yaml=x", "the yaml output takes no argument"},
+		{"go-template={{.id", "error parsing the go-template"},
+		{"go-template={{.id.name.first}}", "error executing the go-template"},
+		{"go-template-file=missing.tmpl", "error reading the go-template file"},
+		{"jsonpath={.id", "error parsing the jsonpath"},
+		{"custom-columns=ID", "invalid custom column 'ID', expected HEADER:JSONPATH"},
+	} {
+		t.Run(tc.output, func(t *testing.T) {
+			_, err := Flatten(testOutputItems, tc.output, testOutputItems.ToText)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	_, err := Flatten(map[string]interface{}{"channel": make(chan int)}, "json", nil)
+	assert.ErrorContains(t, err, "error JSON marshalling data")
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	defer delete(formatters, "count")
+
+	RegisterFormatter("count", Formatter{
+		Format: func(in interface{}, argument string, toText ToTextFunc) (string, error) {
+			return argument + ":2", nil
+		},
+		ContentType: "text/plain",
+		Argument:    "PREFIX",
+	})
+
+	outputData, err := Flatten(testOutputItems, "count=items", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, outputData, "items:2")
+	assert.Equal(t, OutputContentType("count=items"), "text/plain")
+	assert.Assert(t, strings.Contains(OutputUsage("html"), "one of: html, count=PREFIX, csv,"))
+}
+
+func TestPrintOutput(t *testing.T) {
+	writer := bytes.NewBufferString("")
+	assert.NilError(t, PrintOutput(writer, testOutputItems, "jsonpath={[*].id}", nil))
+	assert.Equal(t, writer.String(), "1 2\n")
+
+	assert.ErrorContains(t, PrintOutput(writer, testOutputItems, "xml", nil), "unknown output")
+}
+
+func TestRespondOutput(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	RespondOutput(recorder, http.StatusAccepted, testOutputItems, "csv", nil)
+	assert.Equal(t, recorder.Code, http.StatusAccepted)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+	assert.Assert(t, strings.HasPrefix(recorder.Body.String(), "id,text,image-urls,labels\n"))
+
+	recorder = httptest.NewRecorder()
+	RespondOutput(recorder, http.StatusOK, testOutputItems, "go-template={{.id", nil)
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "error parsing the go-template"))
+
+	templateFile := filepath.Join(t.TempDir(), "output.tmpl")
+	assert.NilError(t, ioutil.WriteFile(templateFile, []byte(`secret`), 0644))
+
+	recorder = httptest.NewRecorder()
+	RespondOutput(recorder, http.StatusOK, testOutputItems, "go-template-file="+templateFile, nil)
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Assert(t, !strings.Contains(recorder.Body.String(), "secret"))
+
+	assert.Equal(t, OutputContentType("ndjson"), "application/x-ndjson")
+	assert.Equal(t, OutputContentType("custom-columns=ID:.id"), "text/plain; charset=utf-8")
+	assert.Equal(t, OutputContentType("html"), "text/html; charset=utf-8")
+}
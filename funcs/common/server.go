@@ -15,12 +15,20 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func (commonFn *CommonFn) InitCommonQueryParams(request *http.Request) {
 	commonFn.SearchString = commonFn.ExtractQueryStringParam(request, []string{"q", "query", "search-string", "s"}, commonFn.SearchString)
 	commonFn.Count = commonFn.ExtractQueryIntParam(request, []string{"c", "count"}, commonFn.Count)
@@ -68,7 +76,107 @@ func (commonFn *CommonFn) ExtractQueryStringParam(request *http.Request, paramNa
 	return stringValue
 }
 
+func (commonFn *CommonFn) ExtractQueryStringSliceParam(request *http.Request, paramNames []string, defaultValue []string) []string {
+	query := request.URL.Query()
+	values := []string{}
+
+	for _, paramName := range paramNames {
+		for _, value := range query[paramName] {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
 func (commonFn *CommonFn) ExtractQueryIntParam(request *http.Request, paramNames []string, defaultValue int) int {
+	return extractQueryIntParam(request, paramNames, defaultValue)
+}
+
+func (commonFn *CommonFn) ExtractQueryFloatParam(request *http.Request, paramNames []string, defaultValue float64) float64 {
+	return extractQueryFloatParam(request, paramNames, defaultValue)
+}
+
+// InitClassifyQueryParams sets the max labels and min score of the request,
+// keeping the current ones by default
+func (classifyFn *ClassifyFn) InitClassifyQueryParams(request *http.Request) {
+	classifyFn.MaxLabels = extractQueryIntParam(request, []string{"max", "max-labels"}, classifyFn.MaxLabels)
+	classifyFn.MinScore = extractQueryFloatParam(request, []string{"threshold", "min-score"}, classifyFn.MinScore)
+}
+
+// NegotiateOutput returns the `o` or `output` query parameter when set and
+// otherwise the first output matching the request's Accept header
+func (commonFn *CommonFn) NegotiateOutput(request *http.Request, defaultOutput string) string {
+	if output := commonFn.ExtractQueryStringParam(request, []string{"o", "output"}, ""); output != "" {
+		return output
+	}
+
+	for _, mediaRange := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(mediaRange, ";")[0]))
+		switch mediaType {
+		case "application/json":
+			return "json"
+		case "application/x-ndjson":
+			return "ndjson"
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return "yaml"
+		case "text/csv":
+			return "csv"
+		case "text/markdown":
+			return "markdown"
+		case "text/html":
+			return "html"
+		case "text/plain":
+			return "text"
+		}
+	}
+
+	return defaultOutput
+}
+
+// HealthzHandler responds `ok` while the func is serving, for the readiness
+// probes of the funcs with no client to check
+func (commonFn *CommonFn) HealthzHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Add("Content-Type", "text/plain")
+	fmt.Fprintln(writer, "ok")
+}
+
+// ListenAndServe serves the handler, or the default mux when nil, on the
+// configured port until the process receives SIGINT or SIGTERM, then shuts the
+// server down gracefully
+func (commonFn *CommonFn) ListenAndServe(handler http.Handler) error {
+	server := &http.Server{Addr: fmt.Sprintf(":%d", commonFn.Port), Handler: handler}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Printf("Received %s, shutting down server", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// Private functions
+
+func extractQueryIntParam(request *http.Request, paramNames []string, defaultValue int) int {
 	query := request.URL.Query()
 	intValue := defaultValue
 
@@ -85,12 +193,19 @@ func (commonFn *CommonFn) ExtractQueryIntParam(request *http.Request, paramNames
 	return intValue
 }
 
-func (commonFn *CommonFn) OutputContentType(output string) string {
-	switch output {
-	case "yaml":
-		return "application/yaml"
-	case "json":
-		return "application/json"
+func extractQueryFloatParam(request *http.Request, paramNames []string, defaultValue float64) float64 {
+	query := request.URL.Query()
+	floatValue := defaultValue
+
+	for _, paramName := range paramNames {
+		if query.Get(paramName) != "" {
+			fValue, err := strconv.ParseFloat(query.Get(paramName), 64)
+			if err != nil {
+				log.Print(fmt.Sprintf("`%s` query parameter value is invalid '%s'!", paramName, err.Error()))
+			}
+			floatValue = fValue
+			break
+		}
 	}
-	return "text/html"
+	return floatValue
 }
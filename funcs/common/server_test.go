@@ -0,0 +1,34 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestInitClassifyQueryParams(t *testing.T) {
+	classifyFn := ClassifyFn{MaxLabels: 10, MinScore: 0.2}
+	classifyFn.InitClassifyQueryParams(httptest.NewRequest("GET", "/?q=NBA", nil))
+	assert.Equal(t, classifyFn, ClassifyFn{MaxLabels: 10, MinScore: 0.2})
+
+	classifyFn.InitClassifyQueryParams(httptest.NewRequest("GET", "/?max=0&threshold=0.5", nil))
+	assert.Equal(t, classifyFn, ClassifyFn{MaxLabels: 0, MinScore: 0.5})
+
+	classifyFn.InitClassifyQueryParams(httptest.NewRequest("GET", "/?max-labels=3&min-score=0.7", nil))
+	assert.Equal(t, classifyFn, ClassifyFn{MaxLabels: 3, MinScore: 0.7})
+}
@@ -0,0 +1,115 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+const (
+	defaultConcurrency = 4
+
+	maxLineLength = 1024 * 1024
+)
+
+// ProcessFunc processes a line of the standard input, e.g., a search string or
+// an image URL, into its output, which is written even with an error, e.g., a
+// summary listing its errors
+type ProcessFunc = func(line string) (string, error)
+
+type lineResult struct {
+	line   string
+	output string
+	err    error
+}
+
+// ProcessLines processes the non-empty lines of the reader, at most Concurrency
+// at a time, and writes their outputs in the order of the lines as soon as they
+// are ready, so that a pipeline of funcs produces its output incrementally. The
+// errors are logged, and counted in the returned error, without stopping the
+// processing of the other lines. A writer error stops reading the lines
+func (commonFn *CommonFn) ProcessLines(reader io.Reader, writer io.Writer, process ProcessFunc) error {
+	concurrency := commonFn.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// with the result the writer waits for, at most concurrency lines are processed
+	results := make(chan chan lineResult, concurrency-1)
+	scanErrs := make(chan error, 1)
+
+	// stops the reading of the lines when returning before the last one
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		defer close(results)
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineLength)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			result := make(chan lineResult, 1)
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(line string) {
+				output, err := process(line)
+				result <- lineResult{line: line, output: output, err: err}
+			}(line)
+		}
+		scanErrs <- scanner.Err()
+	}()
+
+	lines, failedLines := 0, 0
+	for result := range results {
+		lineResult := <-result
+		lines++
+
+		if lineResult.err != nil {
+			failedLines++
+			log.Printf("Error processing '%s': %s\n", lineResult.line, lineResult.err.Error())
+		}
+
+		if lineResult.output != "" {
+			_, err := fmt.Fprintf(writer, "%s\n", lineResult.output)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := <-scanErrs
+	if err != nil {
+		return fmt.Errorf("error reading the standard input: %s", err.Error())
+	}
+
+	if failedLines > 0 {
+		return fmt.Errorf("%d of %d lines failed", failedLines, lines)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+type ndjsonItem struct {
+	Text      string   `json:"text"`
+	ImageURLs []string `json:"image-urls"`
+}
+
+func TestToNDJSON(t *testing.T) {
+	items := []ndjsonItem{
+		{Text: "first", ImageURLs: []string{"http://images/1.png"}},
+		{Text: "second", ImageURLs: []string{}},
+	}
+
+	ndjson, err := ToNDJSON(&items)
+	assert.NilError(t, err)
+	assert.Equal(t, ndjson, `{"text":"first","image-urls":["http://images/1.png"]}
+{"text":"second","image-urls":[]}`)
+
+	flattened, err := Flatten(items, "ndjson", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, flattened, ndjson)
+
+	ndjson, err = ToNDJSON(items[0])
+	assert.NilError(t, err)
+	assert.Equal(t, ndjson, `{"text":"first","image-urls":["http://images/1.png"]}`)
+
+	ndjson, err = ToNDJSON([]ndjsonItem{})
+	assert.NilError(t, err)
+	assert.Equal(t, ndjson, "")
+}
+
+func TestProcessLines(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	log.SetOutput(ioutil.Discard)
+
+	var running, maxRunning int32
+	process := func(line string) (string, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+
+		// the first lines take the longest, yet their outputs are written first
+		time.Sleep(time.Duration(10-len(line)) * 5 * time.Millisecond)
+		if line == "bad" {
+			return "partial bad", errors.New("bad line")
+		}
+		return strings.ToUpper(line), nil
+	}
+
+	commonFn := CommonFn{Concurrency: 2}
+	writer := bytes.NewBufferString("")
+	err := commonFn.ProcessLines(strings.NewReader("a\n\nbb\n  ccc  \nbad\ndddd\n"), writer, process)
+	assert.Error(t, err, "1 of 5 lines failed")
+	assert.Equal(t, writer.String(), "A\nBB\nCCC\npartial bad\nDDDD\n")
+	assert.Assert(t, atomic.LoadInt32(&maxRunning) <= 2)
+
+	writer.Reset()
+	commonFn = CommonFn{}
+	assert.NilError(t, commonFn.ProcessLines(strings.NewReader("a"), writer, process))
+	assert.Equal(t, writer.String(), "A\n")
+}
+
+func TestProcessLinesWriterError(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	log.SetOutput(ioutil.Discard)
+
+	var processed int32
+	process := func(line string) (string, error) {
+		atomic.AddInt32(&processed, 1)
+		return line, nil
+	}
+
+	goroutines := runtime.NumGoroutine()
+
+	commonFn := CommonFn{Concurrency: 2}
+	err := commonFn.ProcessLines(strings.NewReader(strings.Repeat("line\n", 100)), failingWriter{}, process)
+	assert.Error(t, err, "broken pipe")
+
+	// the lines are no longer read nor processed once the writer failed
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Assert(t, runtime.NumGoroutine() <= goroutines)
+	assert.Assert(t, atomic.LoadInt32(&processed) < 100)
+}
+
+// Private
+
+type failingWriter struct{}
+
+func (writer failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
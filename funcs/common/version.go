@@ -0,0 +1,62 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Version, BuildDate and GitRevision are set at build time with the -ldflags
+// of hack/build-flags.sh
+var (
+	Version     = "dev"
+	BuildDate   = ""
+	GitRevision = ""
+)
+
+// VersionInfo is the build of the knfun binaries
+type VersionInfo struct {
+	Version     string `yaml:"version" json:"version"`
+	BuildDate   string `yaml:"build-date,omitempty" json:"build-date,omitempty"`
+	GitRevision string `yaml:"git-revision,omitempty" json:"git-revision,omitempty"`
+	GoVersion   string `yaml:"go-version" json:"go-version"`
+	Platform    string `yaml:"platform" json:"platform"`
+}
+
+func NewVersionInfo() VersionInfo {
+	return VersionInfo{
+		Version:     Version,
+		BuildDate:   BuildDate,
+		GitRevision: GitRevision,
+		GoVersion:   runtime.Version(),
+		Platform:    fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+func (versionInfo VersionInfo) ToText(in interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Version:      %s\n", versionInfo.Version))
+	if versionInfo.BuildDate != "" {
+		sb.WriteString(fmt.Sprintf("Build Date:   %s\n", versionInfo.BuildDate))
+	}
+	if versionInfo.GitRevision != "" {
+		sb.WriteString(fmt.Sprintf("Git Revision: %s\n", versionInfo.GitRevision))
+	}
+	sb.WriteString(fmt.Sprintf("Go Version:   %s\n", versionInfo.GoVersion))
+	sb.WriteString(fmt.Sprintf("Platform:     %s", versionInfo.Platform))
+	return sb.String()
+}
@@ -0,0 +1,307 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gvision
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/common"
+
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+)
+
+const (
+	FeatureLabels     = "labels"
+	FeatureObjects    = "objects"
+	FeatureFaces      = "faces"
+	FeatureText       = "text"
+	FeatureLogos      = "logos"
+	FeatureLandmarks  = "landmarks"
+	FeatureColors     = "colors"
+	FeatureSafeSearch = "safe-search"
+)
+
+var featureTypes = map[string]pb.Feature_Type{
+	FeatureLabels:     pb.Feature_LABEL_DETECTION,
+	FeatureObjects:    pb.Feature_OBJECT_LOCALIZATION,
+	FeatureFaces:      pb.Feature_FACE_DETECTION,
+	FeatureText:       pb.Feature_TEXT_DETECTION,
+	FeatureLogos:      pb.Feature_LOGO_DETECTION,
+	FeatureLandmarks:  pb.Feature_LANDMARK_DETECTION,
+	FeatureColors:     pb.Feature_IMAGE_PROPERTIES,
+	FeatureSafeSearch: pb.Feature_SAFE_SEARCH_DETECTION,
+}
+
+type Vertex struct {
+	X float32 `yaml:"x" json:"x"`
+	Y float32 `yaml:"y" json:"y"`
+}
+
+type Object struct {
+	Name        string   `yaml:"name" json:"name"`
+	Score       float32  `yaml:"score" json:"score"`
+	BoundingBox []Vertex `yaml:"bounding-box" json:"bounding-box"`
+}
+
+type Face struct {
+	Confidence  float32  `yaml:"confidence" json:"confidence"`
+	Joy         string   `yaml:"joy" json:"joy"`
+	Sorrow      string   `yaml:"sorrow" json:"sorrow"`
+	Anger       string   `yaml:"anger" json:"anger"`
+	Surprise    string   `yaml:"surprise" json:"surprise"`
+	Headwear    string   `yaml:"headwear" json:"headwear"`
+	Blurred     string   `yaml:"blurred" json:"blurred"`
+	BoundingBox []Vertex `yaml:"bounding-box" json:"bounding-box"`
+}
+
+type Text struct {
+	Text        string   `yaml:"text" json:"text"`
+	Locale      string   `yaml:"locale,omitempty" json:"locale,omitempty"`
+	BoundingBox []Vertex `yaml:"bounding-box" json:"bounding-box"`
+}
+
+type Location struct {
+	Latitude  float64 `yaml:"latitude" json:"latitude"`
+	Longitude float64 `yaml:"longitude" json:"longitude"`
+}
+
+type Landmark struct {
+	Name      string     `yaml:"name" json:"name"`
+	Score     float32    `yaml:"score" json:"score"`
+	Locations []Location `yaml:"locations" json:"locations"`
+}
+
+type Color struct {
+	Hex           string  `yaml:"hex" json:"hex"`
+	Score         float32 `yaml:"score" json:"score"`
+	PixelFraction float32 `yaml:"pixel-fraction" json:"pixel-fraction"`
+}
+
+type SafeSearch struct {
+	Adult    string `yaml:"adult" json:"adult"`
+	Spoof    string `yaml:"spoof" json:"spoof"`
+	Medical  string `yaml:"medical" json:"medical"`
+	Violence string `yaml:"violence" json:"violence"`
+	Racy     string `yaml:"racy" json:"racy"`
+}
+
+type AnnotateImageData struct {
+	ImageURL   string      `yaml:"ImageURL" json:"ImageURL"`
+	Labels     []Label     `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Objects    []Object    `yaml:"objects,omitempty" json:"objects,omitempty"`
+	Faces      []Face      `yaml:"faces,omitempty" json:"faces,omitempty"`
+	Texts      []Text      `yaml:"texts,omitempty" json:"texts,omitempty"`
+	Logos      []Label     `yaml:"logos,omitempty" json:"logos,omitempty"`
+	Landmarks  []Landmark  `yaml:"landmarks,omitempty" json:"landmarks,omitempty"`
+	Colors     []Color     `yaml:"colors,omitempty" json:"colors,omitempty"`
+	SafeSearch *SafeSearch `yaml:"safe-search,omitempty" json:"safe-search,omitempty"`
+}
+
+func SupportedFeatures() []string {
+	features := []string{}
+	for feature := range featureTypes {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// Private functions
+
+func parseFeatures(features []string) ([]string, error) {
+	parsedFeatures := []string{}
+	for _, feature := range features {
+		for _, f := range strings.Split(feature, ",") {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "" {
+				continue
+			}
+			if _, ok := featureTypes[f]; !ok {
+				return []string{}, fmt.Errorf("invalid feature '%s', supported features are: %s", f, strings.Join(SupportedFeatures(), ", "))
+			}
+			parsedFeatures = append(parsedFeatures, f)
+		}
+	}
+
+	if len(parsedFeatures) == 0 {
+		return []string{}, fmt.Errorf("you must pass at least one feature: %s", strings.Join(SupportedFeatures(), ", "))
+	}
+
+	return parsedFeatures, nil
+}
+
+func collectAnnotateImageData(imageURL string, response *pb.AnnotateImageResponse) AnnotateImageData {
+	aIData := AnnotateImageData{ImageURL: imageURL}
+
+	for _, label := range response.GetLabelAnnotations() {
+		aIData.Labels = append(aIData.Labels, Label{Name: label.GetDescription(), Score: label.GetScore()})
+	}
+
+	for _, object := range response.GetLocalizedObjectAnnotations() {
+		aIData.Objects = append(aIData.Objects, Object{
+			Name:        object.GetName(),
+			Score:       object.GetScore(),
+			BoundingBox: collectVertices(object.GetBoundingPoly()),
+		})
+	}
+
+	for _, face := range response.GetFaceAnnotations() {
+		aIData.Faces = append(aIData.Faces, Face{
+			Confidence:  face.GetDetectionConfidence(),
+			Joy:         face.GetJoyLikelihood().String(),
+			Sorrow:      face.GetSorrowLikelihood().String(),
+			Anger:       face.GetAngerLikelihood().String(),
+			Surprise:    face.GetSurpriseLikelihood().String(),
+			Headwear:    face.GetHeadwearLikelihood().String(),
+			Blurred:     face.GetBlurredLikelihood().String(),
+			BoundingBox: collectVertices(face.GetBoundingPoly()),
+		})
+	}
+
+	for _, text := range response.GetTextAnnotations() {
+		aIData.Texts = append(aIData.Texts, Text{
+			Text:        text.GetDescription(),
+			Locale:      text.GetLocale(),
+			BoundingBox: collectVertices(text.GetBoundingPoly()),
+		})
+	}
+
+	for _, logo := range response.GetLogoAnnotations() {
+		aIData.Logos = append(aIData.Logos, Label{Name: logo.GetDescription(), Score: logo.GetScore()})
+	}
+
+	for _, landmark := range response.GetLandmarkAnnotations() {
+		locations := []Location{}
+		for _, location := range landmark.GetLocations() {
+			locations = append(locations, Location{
+				Latitude:  location.GetLatLng().GetLatitude(),
+				Longitude: location.GetLatLng().GetLongitude(),
+			})
+		}
+		aIData.Landmarks = append(aIData.Landmarks, Landmark{
+			Name:      landmark.GetDescription(),
+			Score:     landmark.GetScore(),
+			Locations: locations,
+		})
+	}
+
+	for _, colorInfo := range response.GetImagePropertiesAnnotation().GetDominantColors().GetColors() {
+		color := colorInfo.GetColor()
+		aIData.Colors = append(aIData.Colors, Color{
+			Hex:           fmt.Sprintf("#%02x%02x%02x", int(color.GetRed()), int(color.GetGreen()), int(color.GetBlue())),
+			Score:         colorInfo.GetScore(),
+			PixelFraction: colorInfo.GetPixelFraction(),
+		})
+	}
+
+	if safeSearch := response.GetSafeSearchAnnotation(); safeSearch != nil {
+		aIData.SafeSearch = &SafeSearch{
+			Adult:    safeSearch.GetAdult().String(),
+			Spoof:    safeSearch.GetSpoof().String(),
+			Medical:  safeSearch.GetMedical().String(),
+			Violence: safeSearch.GetViolence().String(),
+			Racy:     safeSearch.GetRacy().String(),
+		}
+	}
+
+	return aIData
+}
+
+func collectVertices(boundingPoly *pb.BoundingPoly) []Vertex {
+	vertices := []Vertex{}
+	for _, vertex := range boundingPoly.GetVertices() {
+		vertices = append(vertices, Vertex{X: float32(vertex.GetX()), Y: float32(vertex.GetY())})
+	}
+	for _, vertex := range boundingPoly.GetNormalizedVertices() {
+		vertices = append(vertices, Vertex{X: vertex.GetX(), Y: vertex.GetY()})
+	}
+	return vertices
+}
+
+// Private AnnotateImageData
+
+func (aIData AnnotateImageData) filter(classifyFn common.ClassifyFn) AnnotateImageData {
+	filterLabels := func(labels []Label) []Label {
+		filteredLabels := []Label{}
+		for _, label := range labels {
+			if classifyFn.Accept(len(filteredLabels), float64(label.Score)) {
+				filteredLabels = append(filteredLabels, label)
+			}
+		}
+		return filteredLabels
+	}
+
+	aIData.Labels = filterLabels(aIData.Labels)
+	aIData.Logos = filterLabels(aIData.Logos)
+
+	objects := []Object{}
+	for _, object := range aIData.Objects {
+		if classifyFn.Accept(len(objects), float64(object.Score)) {
+			objects = append(objects, object)
+		}
+	}
+	aIData.Objects = objects
+
+	landmarks := []Landmark{}
+	for _, landmark := range aIData.Landmarks {
+		if classifyFn.Accept(len(landmarks), float64(landmark.Score)) {
+			landmarks = append(landmarks, landmark)
+		}
+	}
+	aIData.Landmarks = landmarks
+
+	return aIData
+}
+
+// Public AnnotateImageData
+
+func (aIData AnnotateImageData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+
+	sb.WriteString(fmt.Sprintf("image URL: %s\n", aIData.ImageURL))
+	sb.WriteString("----\n")
+	for _, label := range aIData.Labels {
+		sb.WriteString(fmt.Sprintf("label: %s (%1.3f)\n", label.Name, label.Score))
+	}
+	for _, object := range aIData.Objects {
+		sb.WriteString(fmt.Sprintf("object: %s (%1.3f) at %v\n", object.Name, object.Score, object.BoundingBox))
+	}
+	for _, face := range aIData.Faces {
+		sb.WriteString(fmt.Sprintf("face: (%1.3f) joy: %s, sorrow: %s, anger: %s, surprise: %s, headwear: %s, blurred: %s\n",
+			face.Confidence, face.Joy, face.Sorrow, face.Anger, face.Surprise, face.Headwear, face.Blurred))
+	}
+	for _, text := range aIData.Texts {
+		sb.WriteString(fmt.Sprintf("text: %q\n", text.Text))
+	}
+	for _, logo := range aIData.Logos {
+		sb.WriteString(fmt.Sprintf("logo: %s (%1.3f)\n", logo.Name, logo.Score))
+	}
+	for _, landmark := range aIData.Landmarks {
+		sb.WriteString(fmt.Sprintf("landmark: %s (%1.3f) at %v\n", landmark.Name, landmark.Score, landmark.Locations))
+	}
+	for _, color := range aIData.Colors {
+		sb.WriteString(fmt.Sprintf("color: %s (%1.3f) covering %1.1f%%\n", color.Hex, color.Score, color.PixelFraction*100))
+	}
+	if aIData.SafeSearch != nil {
+		sb.WriteString(fmt.Sprintf("safe search: adult: %s, spoof: %s, medical: %s, violence: %s, racy: %s\n",
+			aIData.SafeSearch.Adult, aIData.SafeSearch.Spoof, aIData.SafeSearch.Medical, aIData.SafeSearch.Violence, aIData.SafeSearch.Racy))
+	}
+	sb.WriteString("----\n")
+
+	return sb.String()
+}
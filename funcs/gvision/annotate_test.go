@@ -0,0 +1,286 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gvision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"google.golang.org/genproto/googleapis/type/color"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gotest.tools/assert"
+
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+)
+
+func TestParseFeatures(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		features []string
+		expected []string
+		err      string
+	}{
+		{name: "single", features: []string{"labels"}, expected: []string{"labels"}},
+		{name: "comma separated", features: []string{"labels, faces,safe-search"}, expected: []string{"labels", "faces", "safe-search"}},
+		{name: "several values", features: []string{"text", "LOGOS"}, expected: []string{"text", "logos"}},
+		{name: "empty values", features: []string{"", " ,colors,"}, expected: []string{"colors"}},
+		{name: "invalid", features: []string{"labels,weather"}, err: "invalid feature 'weather', supported features are: colors, faces, labels, landmarks, logos, objects, safe-search, text"},
+		{name: "none", features: []string{}, err: "you must pass at least one feature"},
+		{name: "blank", features: []string{" , "}, err: "you must pass at least one feature"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			features, err := parseFeatures(test.features)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				assert.Equal(t, len(features), 0)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, features, test.expected)
+		})
+	}
+}
+
+func TestCollectAnnotateImageData(t *testing.T) {
+	aIData := collectAnnotateImageData("http://img/1.jpg", &pb.AnnotateImageResponse{
+		LabelAnnotations: []*pb.EntityAnnotation{
+			&pb.EntityAnnotation{Description: "ball", Score: 0.9},
+		},
+		LocalizedObjectAnnotations: []*pb.LocalizedObjectAnnotation{
+			&pb.LocalizedObjectAnnotation{
+				Name:  "Ball",
+				Score: 0.8,
+				BoundingPoly: &pb.BoundingPoly{
+					NormalizedVertices: []*pb.NormalizedVertex{&pb.NormalizedVertex{X: 0.1, Y: 0.2}},
+				},
+			},
+		},
+		FaceAnnotations: []*pb.FaceAnnotation{
+			&pb.FaceAnnotation{
+				DetectionConfidence: 0.7,
+				JoyLikelihood:       pb.Likelihood_VERY_LIKELY,
+				BoundingPoly:        &pb.BoundingPoly{Vertices: []*pb.Vertex{&pb.Vertex{X: 10, Y: 20}}},
+			},
+		},
+		TextAnnotations: []*pb.EntityAnnotation{
+			&pb.EntityAnnotation{Description: "NBA", Locale: "en"},
+		},
+		LogoAnnotations: []*pb.EntityAnnotation{
+			&pb.EntityAnnotation{Description: "Spalding", Score: 0.6},
+		},
+		LandmarkAnnotations: []*pb.EntityAnnotation{
+			&pb.EntityAnnotation{
+				Description: "Madison Square Garden",
+				Score:       0.5,
+				Locations:   []*pb.LocationInfo{&pb.LocationInfo{LatLng: &latlng.LatLng{Latitude: 40.75, Longitude: -73.99}}},
+			},
+		},
+		ImagePropertiesAnnotation: &pb.ImageProperties{
+			DominantColors: &pb.DominantColorsAnnotation{
+				Colors: []*pb.ColorInfo{
+					&pb.ColorInfo{
+						Color:         &color.Color{Red: 255, Green: 128, Blue: 0, Alpha: wrapperspb.Float(1)},
+						Score:         0.4,
+						PixelFraction: 0.25,
+					},
+				},
+			},
+		},
+		SafeSearchAnnotation: &pb.SafeSearchAnnotation{Adult: pb.Likelihood_VERY_UNLIKELY, Violence: pb.Likelihood_UNLIKELY},
+	})
+
+	assert.DeepEqual(t, aIData, AnnotateImageData{
+		ImageURL: "http://img/1.jpg",
+		Labels:   []Label{Label{Name: "ball", Score: 0.9}},
+		Objects:  []Object{Object{Name: "Ball", Score: 0.8, BoundingBox: []Vertex{Vertex{X: 0.1, Y: 0.2}}}},
+		Faces: []Face{Face{
+			Confidence:  0.7,
+			Joy:         "VERY_LIKELY",
+			Sorrow:      "UNKNOWN",
+			Anger:       "UNKNOWN",
+			Surprise:    "UNKNOWN",
+			Headwear:    "UNKNOWN",
+			Blurred:     "UNKNOWN",
+			BoundingBox: []Vertex{Vertex{X: 10, Y: 20}},
+		}},
+		Texts:      []Text{Text{Text: "NBA", Locale: "en", BoundingBox: []Vertex{}}},
+		Logos:      []Label{Label{Name: "Spalding", Score: 0.6}},
+		Landmarks:  []Landmark{Landmark{Name: "Madison Square Garden", Score: 0.5, Locations: []Location{Location{Latitude: 40.75, Longitude: -73.99}}}},
+		Colors:     []Color{Color{Hex: "#ff8000", Score: 0.4, PixelFraction: 0.25}},
+		SafeSearch: &SafeSearch{Adult: "VERY_UNLIKELY", Spoof: "UNKNOWN", Medical: "UNKNOWN", Violence: "UNLIKELY", Racy: "UNKNOWN"},
+	})
+
+	empty := collectAnnotateImageData("http://img/2.jpg", &pb.AnnotateImageResponse{})
+	assert.DeepEqual(t, empty, AnnotateImageData{ImageURL: "http://img/2.jpg"})
+}
+
+func TestAnnotateImageDataFilter(t *testing.T) {
+	aIData := AnnotateImageData{
+		Labels:    []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "court", Score: 0.5}, Label{Name: "crowd", Score: 0.4}},
+		Logos:     []Label{Label{Name: "Spalding", Score: 0.5}},
+		Objects:   []Object{Object{Name: "Ball", Score: 0.5}, Object{Name: "Shoe", Score: 0.6}},
+		Landmarks: []Landmark{Landmark{Name: "Garden", Score: 0.49}},
+		Colors:    []Color{Color{Hex: "#000000", Score: 0.1}},
+	}
+
+	// the min score is inclusive and a max of 0 keeps all the labels
+	filtered := aIData.filter(common.ClassifyFn{MaxLabels: 0, MinScore: 0.5})
+	assert.DeepEqual(t, filtered.Labels, []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "court", Score: 0.5}})
+	assert.DeepEqual(t, filtered.Logos, []Label{Label{Name: "Spalding", Score: 0.5}})
+	assert.Equal(t, len(filtered.Objects), 2)
+	assert.Equal(t, len(filtered.Landmarks), 0)
+	assert.Equal(t, len(filtered.Colors), 1)
+
+	filtered = aIData.filter(common.ClassifyFn{MaxLabels: 1})
+	assert.DeepEqual(t, filtered.Labels, []Label{Label{Name: "ball", Score: 0.9}})
+	assert.DeepEqual(t, filtered.Objects, []Object{Object{Name: "Ball", Score: 0.5}})
+	assert.Equal(t, len(filtered.Landmarks), 1)
+
+	assert.Equal(t, len(aIData.Labels), 3)
+}
+
+func TestAnnotateHandler(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	log.SetOutput(ioutil.Discard)
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		png.Encode(writer, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	}))
+	defer imageServer.Close()
+
+	annotator := &fakeAnnotator{response: &pb.AnnotateImageResponse{
+		LabelAnnotations: []*pb.EntityAnnotation{
+			&pb.EntityAnnotation{Description: "ball", Score: 0.9},
+			&pb.EntityAnnotation{Description: "court", Score: 0.3},
+		},
+		SafeSearchAnnotation: &pb.SafeSearchAnnotation{Violence: pb.Likelihood_UNLIKELY},
+	}}
+	detectLabelsFn := &DetectLabelsFn{client: &visionClient{annotator: annotator}}
+	handler := detectLabelsFn.handler(detectLabelsFn.ClassifyHandler)
+
+	for _, test := range []struct {
+		path     string
+		features []pb.Feature_Type
+	}{
+		{path: "/annotate?o=json", features: []pb.Feature_Type{pb.Feature_LABEL_DETECTION}},
+		{path: "/annotate?o=json&f=labels,safe-search", features: []pb.Feature_Type{pb.Feature_LABEL_DETECTION, pb.Feature_SAFE_SEARCH_DETECTION}},
+		{path: "/safe-search?o=json", features: []pb.Feature_Type{pb.Feature_SAFE_SEARCH_DETECTION}},
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", test.path+"&q="+imageServer.URL+"/1.png", nil))
+		assert.Equal(t, recorder.Code, http.StatusOK, test.path)
+
+		request := annotator.lastRequest()
+		assert.Assert(t, len(request.GetImage().GetContent()) > 0, test.path)
+		features := []pb.Feature_Type{}
+		for _, feature := range request.Features {
+			features = append(features, feature.Type)
+		}
+		assert.DeepEqual(t, features, test.features)
+
+		aIData := AnnotateImageData{}
+		assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &aIData))
+		assert.Equal(t, aIData.ImageURL, imageServer.URL+"/1.png")
+		assert.Equal(t, aIData.SafeSearch.Violence, "UNLIKELY")
+	}
+
+	// the params of a request never stick to the next ones
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/classify?o=json&max=1&q="+imageServer.URL+"/1.png", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, detectLabelsFn.ImageURL, "")
+	assert.Equal(t, detectLabelsFn.Output, "")
+	assert.Equal(t, detectLabelsFn.MaxLabels, 0)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/annotate?f=labels,weather&q="+imageServer.URL+"/1.png", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "invalid feature 'weather'"))
+
+	// the servers never open their local files
+	for _, query := range []string{"q=%2Fetc%2Fhostname", "q=file%3A%2F%2F%2Fetc%2Fhostname", ""} {
+		for _, path := range []string{"/classify", "/annotate", "/text"} {
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", path+"?"+query, nil))
+			assert.Equal(t, recorder.Code, http.StatusBadRequest, path+"?"+query)
+			assert.Assert(t, strings.Contains(recorder.Body.String(), "expected an http or https URL"), path+"?"+query)
+		}
+	}
+
+	// the annotation stops once the client went away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/annotate?q="+imageServer.URL+"/1.png", nil).WithContext(ctx))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "context canceled"))
+
+	annotator.err = errors.New("quota exceeded")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/annotate?q="+imageServer.URL+"/1.png", nil))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "error annotating image with labels: quota exceeded"))
+
+	detectLabelsFn.closeClient()
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/annotate?q="+imageServer.URL+"/1.png", nil))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "GVision client is not initialized"))
+}
+
+// Private
+
+type fakeAnnotator struct {
+	response *pb.AnnotateImageResponse
+	err      error
+
+	mutex    sync.Mutex
+	requests []*pb.AnnotateImageRequest
+}
+
+func (annotator *fakeAnnotator) annotateImage(ctx context.Context, request *pb.AnnotateImageRequest) (*pb.AnnotateImageResponse, error) {
+	annotator.mutex.Lock()
+	defer annotator.mutex.Unlock()
+
+	annotator.requests = append(annotator.requests, request)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return annotator.response, annotator.err
+}
+
+func (annotator *fakeAnnotator) Close() error {
+	return nil
+}
+
+func (annotator *fakeAnnotator) lastRequest() *pb.AnnotateImageRequest {
+	annotator.mutex.Lock()
+	defer annotator.mutex.Unlock()
+
+	return annotator.requests[len(annotator.requests)-1]
+}
@@ -12,13 +12,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package gvision
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/maximilien/knfun/funcs/common"
 	"github.com/spf13/cobra"
@@ -42,6 +45,7 @@ func NewGVisionCmd() *cobra.Command {
 		Long:  `Various functions over the Google Vision API`,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			detectLabelsFn.initGVisionKeysFlags()
+			detectLabelsFn.InitCORSInputFlags()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
@@ -52,9 +56,10 @@ func NewGVisionCmd() *cobra.Command {
 	}
 
 	detectLabelsCmd := &cobra.Command{
-		Use:   "dl [IMAGE_URL]",
-		Short: "detect labels image",
-		Long:  `detect labels (classify) an image (via its URL) using the GVision APIs`,
+		Use:     "dl [IMAGE_URL]",
+		Aliases: []string{FeatureLabels},
+		Short:   "detect labels image",
+		Long:    `detect labels (classify) an image (via its URL) using the GVision APIs`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			detectLabelsFn.initGVisionKeysFlags()
 			return detectLabelsFn.initDetectLabelsCmdInputFlags(args)
@@ -64,11 +69,54 @@ func NewGVisionCmd() *cobra.Command {
 		},
 	}
 
+	annotateCmd := &cobra.Command{
+		Use:   "annotate [IMAGE_URL]",
+		Short: "annotate image with several features",
+		Long: `annotate an image (via its URL) with several features in one call
+using the GVision APIs, e.g., --features labels,faces,safe-search`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			detectLabelsFn.initGVisionKeysFlags()
+			err := detectLabelsFn.initDetectLabelsCmdInputFlags(args)
+			if err != nil {
+				return err
+			}
+
+			detectLabelsFn.Features, err = parseFeatures(detectLabelsFn.Features)
+			return err
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return detectLabelsFn.annotate(detectLabelsFn.Features)
+		},
+	}
+
 	detectLabelsFn.AddCommonCmdFlags(detectLabelsCmd)
 	detectLabelsFn.addGVisionCmdFlags(gVisionCmd)
+	detectLabelsFn.AddCORSCmdFlags(gVisionCmd)
 	detectLabelsFn.addDetectLabelsCmdFlags(detectLabelsCmd)
+	detectLabelsFn.AddClassifyCmdFlags(detectLabelsCmd)
+
+	detectLabelsFn.AddCommonCmdFlags(annotateCmd)
+	detectLabelsFn.addDetectLabelsCmdFlags(annotateCmd)
+	detectLabelsFn.AddClassifyCmdFlags(annotateCmd)
+	annotateCmd.Flags().StringSliceVarP(&detectLabelsFn.Features, "features", "f", []string{FeatureLabels}, fmt.Sprintf("the features to annotate: %s", strings.Join(SupportedFeatures(), ", ")))
 
 	gVisionCmd.AddCommand(detectLabelsCmd)
+	gVisionCmd.AddCommand(annotateCmd)
+
+	for _, featureCmd := range []struct {
+		feature string
+		short   string
+	}{
+		{FeatureObjects, "localize objects in image"},
+		{FeatureFaces, "detect faces in image"},
+		{FeatureText, "detect text (OCR) in image"},
+		{FeatureLogos, "detect logos in image"},
+		{FeatureLandmarks, "detect landmarks in image"},
+		{FeatureColors, "detect dominant colors of image"},
+		{FeatureSafeSearch, "detect safe search likelihoods of image"},
+	} {
+		gVisionCmd.AddCommand(newFeatureCmd(featureCmd.feature, featureCmd.short))
+	}
 
 	return gVisionCmd
 }
@@ -77,28 +125,165 @@ func Execute() error {
 	return NewGVisionCmd().Execute()
 }
 
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process, closing its client when the context
+// is done
+func Handler(ctx context.Context) (http.Handler, error) {
+	if detectLabelsFn == nil {
+		NewGVisionCmd()
+	}
+
+	detectLabelsFn.initGVisionKeysFlags()
+	detectLabelsFn.InitCORSInputFlags()
+
+	err := detectLabelsFn.initClient(ctx)
+	if err != nil {
+		log.Printf("GVision client not ready, /healthz will fail: %s", err.Error())
+	}
+
+	go func() {
+		<-ctx.Done()
+		detectLabelsFn.closeClient()
+	}()
+
+	return detectLabelsFn.handler(detectLabelsFn.ClassifyHandler), nil
+}
+
 // Private
 
+func newFeatureCmd(feature string, short string) *cobra.Command {
+	featureCmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s [IMAGE_URL]", feature),
+		Short: short,
+		Long:  fmt.Sprintf("%s (via its URL) using the GVision APIs", short),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			detectLabelsFn.initGVisionKeysFlags()
+			return detectLabelsFn.initDetectLabelsCmdInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return detectLabelsFn.annotate([]string{feature})
+		},
+	}
+
+	detectLabelsFn.AddCommonCmdFlags(featureCmd)
+	detectLabelsFn.addDetectLabelsCmdFlags(featureCmd)
+	detectLabelsFn.AddClassifyCmdFlags(featureCmd)
+
+	return featureCmd
+}
+
 func (detectLabelsFn *DetectLabelsFn) detectLabels(cmd *cobra.Command, args []string) error {
 	if detectLabelsFn.StartServer {
-		http.HandleFunc("/", detectLabelsFn.ClassifyHandler)
-		return http.ListenAndServe(fmt.Sprintf(":%d", detectLabelsFn.Port), nil)
+		return detectLabelsFn.startServer(detectLabelsFn.ClassifyHandler)
+	} else {
+		err := detectLabelsFn.initClient(context.Background())
+		if err != nil {
+			return err
+		}
+		defer detectLabelsFn.closeClient()
+
+		if detectLabelsFn.Stdin {
+			return detectLabelsFn.ProcessLines(os.Stdin, os.Stdout, detectLabelsFn.detectLabelsLine)
+		}
+
+		classifyData, err := detectLabelsFn.ClassifyImage(context.Background())
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &classifyData, detectLabelsFn.Output, classifyData.ToText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (detectLabelsFn *DetectLabelsFn) annotate(features []string) error {
+	if detectLabelsFn.StartServer {
+		return detectLabelsFn.startServer(detectLabelsFn.AnnotateHandler(features))
 	} else {
-		classifyData, err := detectLabelsFn.ClassifyImage()
+		err := detectLabelsFn.initClient(context.Background())
 		if err != nil {
 			return err
 		}
+		defer detectLabelsFn.closeClient()
 
-		fmt.Printf("%s\n", common.Flatten(&classifyData, detectLabelsFn.Output, classifyData.ToText))
+		if detectLabelsFn.Stdin {
+			return detectLabelsFn.ProcessLines(os.Stdin, os.Stdout, detectLabelsFn.annotateLine(features))
+		}
+
+		annotateData, err := detectLabelsFn.AnnotateImage(context.Background(), features)
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &annotateData, detectLabelsFn.Output, annotateData.ToText)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// detectLabelsLine detects the labels of a line of the standard input as image URL
+func (detectLabelsFn *DetectLabelsFn) detectLabelsLine(line string) (string, error) {
+	lineFn := *detectLabelsFn
+	lineFn.ImageURL = line
+
+	classifyData, err := lineFn.ClassifyImage(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&classifyData, lineFn.Output, classifyData.ToText)
+}
+
+// annotateLine annotates a line of the standard input as image URL with the features
+func (detectLabelsFn *DetectLabelsFn) annotateLine(features []string) common.ProcessFunc {
+	return func(line string) (string, error) {
+		lineFn := *detectLabelsFn
+		lineFn.ImageURL = line
+
+		annotateData, err := lineFn.AnnotateImage(context.Background(), features)
+		if err != nil {
+			return "", err
+		}
+
+		return common.Flatten(&annotateData, lineFn.Output, annotateData.ToText)
+	}
+}
+
+func (detectLabelsFn *DetectLabelsFn) startServer(rootHandler http.HandlerFunc) error {
+	err := detectLabelsFn.initClient(context.Background())
+	if err != nil {
+		log.Printf("GVision client not ready, /healthz will fail: %s", err.Error())
+	}
+	defer detectLabelsFn.closeClient()
+
+	return detectLabelsFn.ListenAndServe(detectLabelsFn.handler(rootHandler))
+}
+
+func (detectLabelsFn *DetectLabelsFn) handler(rootHandler http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", detectLabelsFn.HealthzHandler)
+	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/annotate", detectLabelsFn.AnnotateHandler([]string{FeatureLabels}))
+	for _, feature := range SupportedFeatures() {
+		mux.HandleFunc(fmt.Sprintf("/%s", feature), detectLabelsFn.AnnotateHandler([]string{feature}))
+	}
+	return mux
+}
+
 func (detectLabelsFn *DetectLabelsFn) addGVisionCmdFlags(cmd *cobra.Command) {
-	cmd.PersistentFlags().StringVar(&detectLabelsFn.keys.gVisionAPIJSON, "gvision-api-json", "", "GVision API JSON")
+	cmd.PersistentFlags().StringVar(&detectLabelsFn.keys.gVisionAPIJSON, "gvision-api-json", "", "GVision API JSON, inline or as a file path")
+	cmd.PersistentFlags().StringVar(&detectLabelsFn.keys.gVisionCredentialsDir, "gvision-credentials-dir", "", "directory with the GVision API JSON, e.g., a mounted Kubernetes secret")
 
 	viper.BindPFlag("gvision-api-json", cmd.PersistentFlags().Lookup("gvision-api-json"))
+	viper.BindPFlag("gvision-credentials-dir", cmd.PersistentFlags().Lookup("gvision-credentials-dir"))
 }
 
 func (detectLabelsFn *DetectLabelsFn) addDetectLabelsCmdFlags(cmd *cobra.Command) {
@@ -110,7 +295,7 @@ func (detectLabelsFn *DetectLabelsFn) initDetectLabelsCmdInputFlags(args []strin
 		detectLabelsFn.ImageURL = args[0]
 	}
 
-	if detectLabelsFn.ImageURL == "" {
+	if detectLabelsFn.ImageURL == "" && !detectLabelsFn.Stdin {
 		return errors.New("you must pass an image URL to detect labels")
 	}
 
@@ -121,4 +306,8 @@ func (detectLabelsFn *DetectLabelsFn) initGVisionKeysFlags() {
 	if detectLabelsFn.keys.gVisionAPIJSON == "" {
 		detectLabelsFn.keys.gVisionAPIJSON = viper.GetString("gvision-api-json")
 	}
+
+	if detectLabelsFn.keys.gVisionCredentialsDir == "" {
+		detectLabelsFn.keys.gVisionCredentialsDir = viper.GetString("gvision-credentials-dir")
+	}
 }
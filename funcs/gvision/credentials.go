@@ -0,0 +1,260 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gvision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/maximilien/knfun/funcs/common"
+
+	vision "cloud.google.com/go/vision/apiv1"
+	"google.golang.org/api/option"
+
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+)
+
+var credentialsFilenames = []string{"gvision-api-json", "key.json", "credentials.json", "service-account.json"}
+
+type googleCredentials struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	ClientID     string `json:"client_id"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// visionClient is the GVision client shared by the requests and the copies of
+// the DetectLabelsFn, guarded since a server closes it once its context is done
+type visionClient struct {
+	mutex     sync.RWMutex
+	annotator imageAnnotator
+	err       error
+}
+
+func (detectLabelsFn *DetectLabelsFn) HealthzHandler(writer http.ResponseWriter, request *http.Request) {
+	if err := detectLabelsFn.clientError(); err != nil {
+		http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintf(writer, "ok\n")
+}
+
+// Private DetectLabelsFn
+
+// initClient creates the client once, before the requests share it
+func (detectLabelsFn *DetectLabelsFn) initClient(ctx context.Context) error {
+	if detectLabelsFn.client == nil {
+		detectLabelsFn.client = &visionClient{}
+	}
+
+	client := detectLabelsFn.client
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.annotator != nil {
+		return nil
+	}
+
+	client.annotator, client.err = detectLabelsFn.createClient(ctx)
+	return client.err
+}
+
+// clientError is why the client is not ready, nil when it is
+func (detectLabelsFn *DetectLabelsFn) clientError() error {
+	client := detectLabelsFn.client
+	if client == nil {
+		return errors.New("GVision client is not initialized")
+	}
+
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	return client.error()
+}
+
+func (detectLabelsFn *DetectLabelsFn) createClient(ctx context.Context) (imageAnnotator, error) {
+	credentialsJSON, source, err := detectLabelsFn.loadCredentialsJSON()
+	if err != nil {
+		return nil, fmt.Errorf("error loading GVision credentials: %s", err.Error())
+	}
+
+	err = validateCredentialsJSON(credentialsJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GVision credentials from %s: %s", source, err.Error())
+	}
+
+	gVisionClient, err := vision.NewImageAnnotatorClient(ctx, option.WithCredentialsJSON(credentialsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("error creating GVision client with credentials from %s: %s", source, err.Error())
+	}
+
+	log.Printf("GVision client created with credentials from %s", source)
+	return visionAnnotator{gVisionClient}, nil
+}
+
+// closeClient closes the client once its pending annotations are done
+func (detectLabelsFn *DetectLabelsFn) closeClient() {
+	client := detectLabelsFn.client
+	if client == nil {
+		return
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.annotator == nil {
+		return
+	}
+
+	err := client.annotator.Close()
+	if err != nil {
+		log.Printf("Error closing GVision client: %s", err.Error())
+	}
+	client.annotator = nil
+}
+
+// loadCredentialsJSON looks for the credentials in the --gvision-api-json value
+// (inline JSON or file path), the --gvision-credentials-dir mounted secret, and
+// finally the GVISION_API_JSON and GOOGLE_APPLICATION_CREDENTIALS env variables
+func (detectLabelsFn *DetectLabelsFn) loadCredentialsJSON() ([]byte, string, error) {
+	if detectLabelsFn.keys.gVisionAPIJSON != "" {
+		return readCredentialsValue(detectLabelsFn.keys.gVisionAPIJSON, "--gvision-api-json")
+	}
+
+	if detectLabelsFn.keys.gVisionCredentialsDir != "" {
+		return readCredentialsDir(detectLabelsFn.keys.gVisionCredentialsDir)
+	}
+
+	for _, envName := range []string{"GVISION_API_JSON", "GOOGLE_APPLICATION_CREDENTIALS"} {
+		if value := os.Getenv(envName); value != "" {
+			return readCredentialsValue(value, envName)
+		}
+	}
+
+	return []byte{}, "", errors.New("no credentials found, use --gvision-api-json, --gvision-credentials-dir, or the GOOGLE_APPLICATION_CREDENTIALS env variable")
+}
+
+// Private functions
+
+func readCredentialsValue(value string, source string) ([]byte, string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return []byte(value), source, nil
+	}
+
+	if !common.FileExists(value) {
+		return []byte{}, source, fmt.Errorf("%s is neither inline JSON nor an existing file", source)
+	}
+
+	credentialsJSON, err := ioutil.ReadFile(value)
+	return credentialsJSON, fmt.Sprintf("file '%s'", value), err
+}
+
+func readCredentialsDir(dir string) ([]byte, string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return []byte{}, "", err
+	}
+
+	filenames := []string{}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), ".") && common.FileExists(filepath.Join(dir, file.Name())) {
+			filenames = append(filenames, file.Name())
+		}
+	}
+	sort.Strings(filenames)
+
+	filename := ""
+	if len(filenames) == 1 {
+		filename = filenames[0]
+	}
+
+	for _, credentialsFilename := range credentialsFilenames {
+		for _, f := range filenames {
+			if filename == "" && f == credentialsFilename {
+				filename = f
+			}
+		}
+	}
+
+	for _, f := range filenames {
+		if filename == "" && strings.HasSuffix(f, ".json") {
+			filename = f
+		}
+	}
+
+	if filename == "" {
+		return []byte{}, "", fmt.Errorf("no credentials file found in directory '%s'", dir)
+	}
+
+	return readCredentialsValue(filepath.Join(dir, filename), fmt.Sprintf("directory '%s'", dir))
+}
+
+func validateCredentialsJSON(credentialsJSON []byte) error {
+	credentials := googleCredentials{}
+	err := json.Unmarshal(credentialsJSON, &credentials)
+	if err != nil {
+		return fmt.Errorf("error parsing JSON: %s", err.Error())
+	}
+
+	switch credentials.Type {
+	case "service_account":
+		if credentials.ClientEmail == "" || credentials.PrivateKey == "" {
+			return errors.New("service account credentials must have a `client_email` and a `private_key`")
+		}
+	case "authorized_user":
+		if credentials.ClientID == "" || credentials.RefreshToken == "" {
+			return errors.New("authorized user credentials must have a `client_id` and a `refresh_token`")
+		}
+	case "":
+		return errors.New("missing credentials `type`")
+	}
+
+	return nil
+}
+
+// Private visionClient
+
+// annotateImage annotates with the annotator, which is not closed meanwhile
+func (client *visionClient) annotateImage(ctx context.Context, request *pb.AnnotateImageRequest) (*pb.AnnotateImageResponse, error) {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	if client.annotator == nil {
+		return nil, client.error()
+	}
+	return client.annotator.annotateImage(ctx, request)
+}
+
+func (client *visionClient) error() error {
+	if client.annotator != nil {
+		return nil
+	}
+	if client.err != nil {
+		return client.err
+	}
+	return errors.New("GVision client is not initialized")
+}
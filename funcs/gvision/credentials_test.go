@@ -0,0 +1,125 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gvision
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const serviceAccountJSON = `{"type": "service_account", "client_email": "fn@knfun.iam.gserviceaccount.com", "private_key": "key"}`
+
+func TestLoadCredentialsJSONInline(t *testing.T) {
+	detectLabelsFn := &DetectLabelsFn{keys: keys{gVisionAPIJSON: serviceAccountJSON}}
+
+	credentialsJSON, source, err := detectLabelsFn.loadCredentialsJSON()
+	assert.NilError(t, err)
+	assert.Equal(t, source, "--gvision-api-json")
+	assert.Equal(t, string(credentialsJSON), serviceAccountJSON)
+}
+
+func TestLoadCredentialsJSONFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gvision")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "key.json")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(serviceAccountJSON), 0600))
+
+	detectLabelsFn := &DetectLabelsFn{keys: keys{gVisionAPIJSON: path}}
+
+	credentialsJSON, _, err := detectLabelsFn.loadCredentialsJSON()
+	assert.NilError(t, err)
+	assert.Equal(t, string(credentialsJSON), serviceAccountJSON)
+}
+
+func TestLoadCredentialsJSONDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gvision")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Kubernetes secret mounts contain hidden ..data entries next to the keys
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "..data"), 0700))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not credentials"), 0600))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "credentials.json"), []byte(serviceAccountJSON), 0600))
+
+	detectLabelsFn := &DetectLabelsFn{keys: keys{gVisionCredentialsDir: dir}}
+
+	credentialsJSON, _, err := detectLabelsFn.loadCredentialsJSON()
+	assert.NilError(t, err)
+	assert.Equal(t, string(credentialsJSON), serviceAccountJSON)
+}
+
+func TestLoadCredentialsJSONMissing(t *testing.T) {
+	for _, envName := range []string{"GVISION_API_JSON", "GOOGLE_APPLICATION_CREDENTIALS"} {
+		if value, ok := os.LookupEnv(envName); ok {
+			os.Unsetenv(envName)
+			defer os.Setenv(envName, value)
+		}
+	}
+
+	_, _, err := (&DetectLabelsFn{}).loadCredentialsJSON()
+	assert.ErrorContains(t, err, "no credentials found")
+
+	_, _, err = (&DetectLabelsFn{keys: keys{gVisionAPIJSON: "/does/not/exist.json"}}).loadCredentialsJSON()
+	assert.ErrorContains(t, err, "neither inline JSON nor an existing file")
+}
+
+func TestValidateCredentialsJSON(t *testing.T) {
+	assert.NilError(t, validateCredentialsJSON([]byte(serviceAccountJSON)))
+	assert.ErrorContains(t, validateCredentialsJSON([]byte(`{"type": "service_account"}`)), "client_email")
+	assert.ErrorContains(t, validateCredentialsJSON([]byte(`{"client_email": "fn"}`)), "missing credentials `type`")
+	assert.ErrorContains(t, validateCredentialsJSON([]byte(`not json`)), "error parsing JSON")
+}
+
+func TestHealthzHandlerNotReady(t *testing.T) {
+	detectLabelsFn := &DetectLabelsFn{keys: keys{gVisionAPIJSON: `{"type": "service_account"}`}}
+	assert.ErrorContains(t, detectLabelsFn.initClient(context.Background()), "invalid GVision credentials")
+
+	recorder := httptest.NewRecorder()
+	detectLabelsFn.HealthzHandler(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "invalid GVision credentials"))
+}
+
+func TestCloseClientWhileServing(t *testing.T) {
+	detectLabelsFn := &DetectLabelsFn{client: &visionClient{annotator: &fakeAnnotator{}}}
+	handler := detectLabelsFn.handler(detectLabelsFn.ClassifyHandler)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+		}()
+	}
+	detectLabelsFn.closeClient()
+	wg.Wait()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "GVision client is not initialized"))
+}
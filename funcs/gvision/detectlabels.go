@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package gvision
 
 import (
 	"bytes"
@@ -26,15 +26,30 @@ import (
 	"github.com/maximilien/knfun/funcs/common"
 
 	vision "cloud.google.com/go/vision/apiv1"
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
 )
 
+const defaultMaxResults = 10
+
 type keys struct {
-	gVisionAPIJSON string
+	gVisionAPIJSON        string
+	gVisionCredentialsDir string
+}
+
+// imageAnnotator annotates an image with several features in one call
+type imageAnnotator interface {
+	annotateImage(ctx context.Context, request *pb.AnnotateImageRequest) (*pb.AnnotateImageResponse, error)
+	Close() error
+}
+
+// visionAnnotator is the imageAnnotator of the GVision client
+type visionAnnotator struct {
+	*vision.ImageAnnotatorClient
 }
 
 type Label struct {
-	Name  string
-	Score float32
+	Name  string  `yaml:"name" json:"name"`
+	Score float32 `yaml:"score" json:"score"`
 }
 
 type ClassifyImageData struct {
@@ -44,107 +59,166 @@ type ClassifyImageData struct {
 
 type DetectLabelsFn struct {
 	common.CommonFn
+	common.ClassifyFn
+	common.CORSFn
 
-	client *vision.ImageAnnotatorClient
+	client *visionClient
 
 	ImageURL string
+	Features []string
 
 	keys keys
 }
 
-func (detectLabelsFn *DetectLabelsFn) ClassifyImage() (ClassifyImageData, error) {
-	ctx := context.Background()
-
-	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
-		err := os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", detectLabelsFn.keys.gVisionAPIJSON)
-		if err != nil {
-			return ClassifyImageData{}, fmt.Errorf("error finding GOOGLE_APPLICATION_CREDENTIALS enviroment variable: %s", err.Error())
-		}
+func (detectLabelsFn *DetectLabelsFn) ClassifyImage(ctx context.Context) (ClassifyImageData, error) {
+	aIData, err := detectLabelsFn.AnnotateImage(ctx, []string{FeatureLabels})
+	if err != nil {
+		return ClassifyImageData{}, err
 	}
 
-	googleCredentials := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
-	if !common.FileExists(googleCredentials) {
-		googleCredentialsFilepath, err := common.CreateTmpFile(googleCredentials)
-		if err != nil {
-			return ClassifyImageData{}, fmt.Errorf("error creating GOOGLE_APPLICATION_CREDENTIALS file from enviroment variable: %s", err.Error())
-		}
+	return ClassifyImageData{
+		ImageURL: aIData.ImageURL,
+		Labels:   aIData.Labels,
+	}, nil
+}
 
-		err = os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", googleCredentialsFilepath)
-		if err != nil {
-			return ClassifyImageData{}, fmt.Errorf("error setting GOOGLE_APPLICATION_CREDENTIALS enviroment variable: %s", err.Error())
-		}
+func (detectLabelsFn *DetectLabelsFn) AnnotateImage(ctx context.Context, features []string) (AnnotateImageData, error) {
+	if err := detectLabelsFn.clientError(); err != nil {
+		return AnnotateImageData{}, err
 	}
 
-	var err error
-	if detectLabelsFn.client == nil {
-		gVisionClient, err := vision.NewImageAnnotatorClient(ctx)
-		if err != nil {
-			return ClassifyImageData{}, fmt.Errorf("error creating client: %s", err.Error())
-		}
-		detectLabelsFn.client = gVisionClient
+	image, err := detectLabelsFn.loadImage()
+	if err != nil {
+		return AnnotateImageData{}, err
 	}
 
-	filepath := detectLabelsFn.ImageURL
-	if strings.HasPrefix(detectLabelsFn.ImageURL, "http") {
-		filepath, err = common.DownloadTmpFile(detectLabelsFn.ImageURL)
-		if err != nil {
-			return ClassifyImageData{}, fmt.Errorf("error creating tmp file for image: %s", err.Error())
-		}
+	request := &pb.AnnotateImageRequest{Image: image}
+	for _, feature := range features {
+		request.Features = append(request.Features, &pb.Feature{
+			Type:       featureTypes[feature],
+			MaxResults: detectLabelsFn.maxResults(),
+		})
 	}
 
-	file, err := os.Open(filepath)
+	response, err := detectLabelsFn.client.annotateImage(ctx, request)
 	if err != nil {
-		return ClassifyImageData{}, fmt.Errorf("error loading image: %s", err.Error())
+		return AnnotateImageData{}, fmt.Errorf("error annotating image with %s: %s", strings.Join(features, ", "), err.Error())
 	}
-	defer file.Close()
-	defer os.Remove(filepath)
 
-	image, err := vision.NewImageFromReader(file)
-	if err != nil {
-		return ClassifyImageData{}, fmt.Errorf("error reading image: %s", err.Error())
+	if response.GetError() != nil {
+		return AnnotateImageData{}, fmt.Errorf("error annotating image with %s: %s", strings.Join(features, ", "), response.GetError().GetMessage())
 	}
 
-	labels, err := detectLabelsFn.client.DetectLabels(ctx, image, nil, 10)
-	if err != nil {
-		return ClassifyImageData{}, fmt.Errorf("error detecting labels for image: %s", err.Error())
+	aIData := collectAnnotateImageData(detectLabelsFn.ImageURL, response)
+	return aIData.filter(detectLabelsFn.ClassifyFn), nil
+}
+
+func (detectLabelsFn *DetectLabelsFn) ClassifyHandler(writer http.ResponseWriter, request *http.Request) {
+	if detectLabelsFn.WriteCORSHeaders(writer, request) {
+		return
 	}
 
-	cImageData := ClassifyImageData{
-		ImageURL: detectLabelsFn.ImageURL,
+	fn := detectLabelsFn.requestFn(request)
+	if err := common.ValidateHTTPURL(fn.ImageURL); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
 	}
-	for _, label := range labels {
-		l := Label{
-			Name:  label.Description,
-			Score: label.Score,
+	log.Printf("GVisionFn.DetectLabels: q=\"%s\", max=\"%d\", threshold=\"%1.3f\", o=\"%s\"", fn.ImageURL, fn.MaxLabels, fn.MinScore, fn.Output)
+
+	classifiedImageData, err := fn.ClassifyImage(request.Context())
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, &classifiedImageData, fn.Output, classifiedImageData.ToText)
+}
+
+func (detectLabelsFn *DetectLabelsFn) AnnotateHandler(features []string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if detectLabelsFn.WriteCORSHeaders(writer, request) {
+			return
+		}
+
+		fn := detectLabelsFn.requestFn(request)
+		if err := common.ValidateHTTPURL(fn.ImageURL); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		requestFeatures := features
+		if featuresParam := fn.ExtractQueryStringParam(request, []string{"features", "f"}, ""); featuresParam != "" {
+			parsedFeatures, err := parseFeatures([]string{featuresParam})
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			}
+			requestFeatures = parsedFeatures
+		}
+		log.Printf("GVisionFn.Annotate: q=\"%s\", features=\"%s\", o=\"%s\"", fn.ImageURL, strings.Join(requestFeatures, ","), fn.Output)
+
+		annotateImageData, err := fn.AnnotateImage(request.Context(), requestFeatures)
+		if err != nil {
+			log.Print(err.Error())
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		cImageData.Labels = append(cImageData.Labels, l)
+
+		common.RespondOutput(writer, http.StatusOK, &annotateImageData, fn.Output, annotateImageData.ToText)
 	}
+}
 
-	return cImageData, nil
+// Private classifyImageFn
+
+// requestFn returns a copy of the flag values with the request query params applied
+func (detectLabelsFn *DetectLabelsFn) requestFn(request *http.Request) *DetectLabelsFn {
+	fn := *detectLabelsFn
+	fn.ImageURL = fn.ExtractQueryStringParam(request, []string{"query", "q", "image-url", "u"}, fn.ImageURL)
+	fn.Output = fn.ExtractQueryStringParam(request, []string{"o", "output"}, fn.Output)
+	fn.InitClassifyQueryParams(request)
+	return &fn
 }
 
-func (detectLabelsFn *DetectLabelsFn) ClassifyHandler(writer http.ResponseWriter, request *http.Request) {
-	detectLabelsFn.initQueryParams(request)
-	log.Printf("GVisionFn.DetectLabels: q=\"%s\", o=\"%s\"", detectLabelsFn.ImageURL, detectLabelsFn.Output)
+func (detectLabelsFn *DetectLabelsFn) maxResults() int32 {
+	if detectLabelsFn.MaxLabels > 0 {
+		return int32(detectLabelsFn.MaxLabels)
+	}
+	return defaultMaxResults
+}
 
-	classifiedImageData, err := detectLabelsFn.ClassifyImage()
+func (detectLabelsFn *DetectLabelsFn) loadImage() (*pb.Image, error) {
+	var err error
+
+	filepath := detectLabelsFn.ImageURL
+	if strings.HasPrefix(detectLabelsFn.ImageURL, "http") {
+		filepath, err = common.DownloadTmpFile(detectLabelsFn.ImageURL)
+		if filepath != "" {
+			defer os.Remove(filepath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error creating tmp file for image: %s", err.Error())
+		}
+	}
+
+	file, err := os.Open(filepath)
 	if err != nil {
-		log.Print(err.Error())
-		return
+		return nil, fmt.Errorf("error loading image: %s", err.Error())
 	}
+	defer file.Close()
 
-	writer.Header().Add("Access-Control-Allow-Origin", "*")
-	writer.Header().Add("Access-Control-Allow-Headers", "x-requested-with")
+	image, err := vision.NewImageFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %s", err.Error())
+	}
 
-	writer.Header().Add("Content-Type", detectLabelsFn.OutputContentType(detectLabelsFn.Output))
-	fmt.Fprintf(writer, "%s\n", common.Flatten(&classifiedImageData, detectLabelsFn.Output, classifiedImageData.ToText))
+	return image, nil
 }
 
-// Private classifyImageFn
+// Private visionAnnotator
 
-func (classifyImageFn *DetectLabelsFn) initQueryParams(request *http.Request) {
-	detectLabelsFn.ImageURL = classifyImageFn.ExtractQueryStringParam(request, []string{"query", "q", "image-url", "u"}, classifyImageFn.ImageURL)
-	detectLabelsFn.Output = classifyImageFn.ExtractQueryStringParam(request, []string{"o", "output"}, classifyImageFn.Output)
+func (annotator visionAnnotator) annotateImage(ctx context.Context, request *pb.AnnotateImageRequest) (*pb.AnnotateImageResponse, error) {
+	return annotator.AnnotateImage(ctx, request)
 }
 
 // Public ClassifyImageData
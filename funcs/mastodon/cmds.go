@@ -0,0 +1,154 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mastodon
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	searchFn *SearchFn
+)
+
+func NewMastodonCmd() *cobra.Command {
+	searchFn = &SearchFn{
+		keys: keys{},
+	}
+
+	cobra.OnInitialize(searchFn.InitConfig)
+
+	mastodonCmd := &cobra.Command{
+		Use:   "mastodon",
+		Short: "Mastodon root function",
+		Long:  `Various functions over the Mastodon API`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			searchFn.initMastodonKeysFlags()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	searchCmd := &cobra.Command{
+		Use:   "search [SEARCH_STRING]",
+		Short: "Search for statuses and extract contents",
+		Long: `Searches a Mastodon instance for statuses matching some string criteria
+and responds with the content of these statuses. Search strings starting
+with '#' list the public hashtag timeline, others use the search API`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			searchFn.initMastodonKeysFlags()
+			return searchFn.InitCommonInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchFn.search(cmd, args)
+		},
+	}
+
+	searchFn.AddCommonCmdFlags(searchCmd)
+	searchFn.addMastodonCmdFlags(mastodonCmd)
+
+	mastodonCmd.AddCommand(searchCmd)
+
+	return mastodonCmd
+}
+
+func Execute() error {
+	return NewMastodonCmd().Execute()
+}
+
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if searchFn == nil {
+		NewMastodonCmd()
+	}
+
+	searchFn.initMastodonKeysFlags()
+	return searchFn.handler(), nil
+}
+
+// Private
+
+func (searchFn *SearchFn) search(cmd *cobra.Command, args []string) error {
+	if searchFn.StartServer {
+		return searchFn.ListenAndServe(searchFn.handler())
+	} else if searchFn.Stdin {
+		return searchFn.ProcessLines(os.Stdin, os.Stdout, searchFn.searchLine)
+	} else {
+		statusesData, err := searchFn.Search()
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &statusesData, searchFn.Output, statusesData.ToText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchLine searches for a line of the standard input as search string
+func (searchFn *SearchFn) searchLine(line string) (string, error) {
+	lineFn := *searchFn
+	lineFn.SearchString = line
+
+	statusesData, err := lineFn.Search()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&statusesData, lineFn.Output, statusesData.ToText)
+}
+
+func (searchFn *SearchFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", searchFn.HealthzHandler)
+	mux.HandleFunc("/", searchFn.SearchHandler)
+	return mux
+}
+
+func (searchFn *SearchFn) addMastodonCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&searchFn.InstanceURL, "mastodon-instance-url", "", "mastodon instance URL (default https://mastodon.social)")
+	cmd.PersistentFlags().StringVar(&searchFn.keys.mastodonAccessToken, "mastodon-access-token", "", "mastodon access token")
+
+	viper.BindPFlag("mastodon-instance-url", cmd.PersistentFlags().Lookup("mastodon-instance-url"))
+	viper.BindPFlag("mastodon-access-token", cmd.PersistentFlags().Lookup("mastodon-access-token"))
+}
+
+func (searchFn *SearchFn) initMastodonKeysFlags() {
+	if searchFn.InstanceURL == "" {
+		searchFn.InstanceURL = viper.GetString("mastodon-instance-url")
+	}
+
+	if searchFn.InstanceURL == "" {
+		searchFn.InstanceURL = "https://mastodon.social"
+	}
+
+	if searchFn.keys.mastodonAccessToken == "" {
+		searchFn.keys.mastodonAccessToken = viper.GetString("mastodon-access-token")
+	}
+}
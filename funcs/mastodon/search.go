@@ -0,0 +1,180 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mastodon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const maxMastodonLimit = 40
+
+type keys struct {
+	mastodonAccessToken string
+}
+
+type StatusData struct {
+	ID        string   `yaml:"id,omitempty" json:"id,omitempty"`
+	Text      string   `yaml:"text" json:"text"`
+	ImageURLs []string `yaml:"image-urls" json:"image-urls"`
+	CreatedAt string   `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+}
+
+type StatusesData []StatusData
+
+type SearchFn struct {
+	common.CommonFn
+
+	InstanceURL string
+
+	keys keys
+}
+
+type mastodonMediaAttachment struct {
+	Type       string `json:"type"`
+	URL        string `json:"url"`
+	PreviewURL string `json:"preview_url"`
+}
+
+type mastodonStatus struct {
+	ID               string                    `json:"id"`
+	Content          string                    `json:"content"`
+	CreatedAt        string                    `json:"created_at"`
+	MediaAttachments []mastodonMediaAttachment `json:"media_attachments"`
+	Reblog           *mastodonStatus           `json:"reblog"`
+}
+
+type mastodonSearchResults struct {
+	Statuses []mastodonStatus `json:"statuses"`
+}
+
+func (searchFn *SearchFn) Search() (StatusesData, error) {
+	body, err := common.FetchURL(searchFn.searchURL(), searchFn.headers(), searchFn.Timeout)
+	if err != nil {
+		return StatusesData{}, err
+	}
+
+	statuses := []mastodonStatus{}
+	if searchFn.isHashtagSearch() {
+		err = json.Unmarshal(body, &statuses)
+	} else {
+		results := mastodonSearchResults{}
+		err = json.Unmarshal(body, &results)
+		statuses = results.Statuses
+	}
+	if err != nil {
+		return StatusesData{}, fmt.Errorf("error decoding Mastodon statuses: %s", err.Error())
+	}
+
+	return searchFn.collectStatusesData(statuses), nil
+}
+
+func (searchFn *SearchFn) SearchHandler(writer http.ResponseWriter, request *http.Request) {
+	searchFn.InitCommonQueryParams(request)
+	log.Printf("MastodonFn.Search: q=\"%s\", c=\"%d\", o=\"%s\"", searchFn.SearchString, searchFn.Count, searchFn.Output)
+
+	statusesData, err := searchFn.Search()
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, &statusesData, searchFn.Output, statusesData.ToText)
+}
+
+// Private SearchFn
+
+func (searchFn *SearchFn) isHashtagSearch() bool {
+	return strings.HasPrefix(searchFn.SearchString, "#")
+}
+
+func (searchFn *SearchFn) limit() int {
+	if searchFn.Count <= 0 || searchFn.Count > maxMastodonLimit {
+		return maxMastodonLimit
+	}
+	return searchFn.Count
+}
+
+func (searchFn *SearchFn) searchURL() string {
+	instanceURL := strings.TrimSuffix(searchFn.InstanceURL, "/")
+	if searchFn.isHashtagSearch() {
+		hashtag := strings.TrimPrefix(searchFn.SearchString, "#")
+		return fmt.Sprintf("%s/api/v1/timelines/tag/%s?limit=%d", instanceURL, url.PathEscape(hashtag), searchFn.limit())
+	}
+
+	params := url.Values{}
+	params.Set("q", searchFn.SearchString)
+	params.Set("type", "statuses")
+	params.Set("limit", fmt.Sprintf("%d", searchFn.limit()))
+	return fmt.Sprintf("%s/api/v2/search?%s", instanceURL, params.Encode())
+}
+
+func (searchFn *SearchFn) headers() map[string]string {
+	headers := map[string]string{"Accept": "application/json"}
+	if searchFn.keys.mastodonAccessToken != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", searchFn.keys.mastodonAccessToken)
+	}
+	return headers
+}
+
+func (searchFn *SearchFn) collectStatusesData(statuses []mastodonStatus) StatusesData {
+	statusesData := StatusesData{}
+	for _, status := range statuses {
+		if status.Reblog != nil {
+			status = *status.Reblog
+		}
+
+		statusData := StatusData{ID: status.ID, Text: common.StripHTML(status.Content), CreatedAt: status.CreatedAt}
+		imageURLs := []string{}
+		for _, media := range status.MediaAttachments {
+			if media.Type == "image" && media.URL != "" {
+				imageURLs = append(imageURLs, media.URL)
+			}
+		}
+		statusData.ImageURLs = imageURLs
+		statusesData = append(statusesData, statusData)
+	}
+	return statusesData
+}
+
+// Private StatusesData
+
+func (status StatusData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("\n🐘 %s\n", status.Text))
+	if len(status.ImageURLs) > 0 {
+		for _, imageUrl := range status.ImageURLs {
+			sb.WriteString(fmt.Sprintf("- 📸 %s\n", imageUrl))
+		}
+	}
+	return sb.String()
+}
+
+func (statuses StatusesData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, statusData := range statuses {
+		sb.WriteString(statusData.ToText(statusData))
+		sb.WriteString("------\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,121 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mastodon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"gotest.tools/assert"
+)
+
+func TestSearchHashtag(t *testing.T) {
+	var request *http.Request
+	server := newFixtureServer(t, "testdata/tag_timeline.json", &request)
+	defer server.Close()
+
+	searchFn := &SearchFn{
+		CommonFn:    common.CommonFn{SearchString: "#NBA", Count: 5},
+		InstanceURL: server.URL,
+	}
+
+	statusesData, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, request.URL.Path, "/api/v1/timelines/tag/NBA")
+	assert.Equal(t, request.URL.Query().Get("limit"), "5")
+	assert.Equal(t, request.Header.Get("Authorization"), "")
+
+	assert.Equal(t, len(statusesData), 2)
+	assert.Equal(t, statusesData[0].Text, "Game night! # NBA & friends")
+	assert.DeepEqual(t, statusesData[0].ImageURLs, []string{"https://files.mastodon.example/1.jpg"})
+	assert.Equal(t, statusesData[0].ID, "1")
+	assert.Equal(t, statusesData[0].CreatedAt, "2019-11-21T19:00:00.000Z")
+	assert.Equal(t, statusesData[1].Text, "Boosted dunk")
+	assert.DeepEqual(t, statusesData[1].ImageURLs, []string{"https://files.mastodon.example/3.png"})
+}
+
+func TestSearchStatuses(t *testing.T) {
+	var request *http.Request
+	server := newFixtureServer(t, "testdata/search.json", &request)
+	defer server.Close()
+
+	searchFn := &SearchFn{
+		CommonFn:    common.CommonFn{SearchString: "knative & friends", Count: 100},
+		InstanceURL: server.URL + "/",
+		keys:        keys{mastodonAccessToken: "token"},
+	}
+
+	statusesData, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, request.URL.Path, "/api/v2/search")
+	assert.Equal(t, request.URL.Query().Get("q"), "knative & friends")
+	assert.Equal(t, request.URL.Query().Get("type"), "statuses")
+	assert.Equal(t, request.URL.Query().Get("limit"), "40")
+	assert.Equal(t, request.Header.Get("Authorization"), "Bearer token")
+
+	assert.Equal(t, len(statusesData), 1)
+	assert.Equal(t, statusesData[0].Text, "Knative on a Friday")
+	assert.Equal(t, len(statusesData[0].ImageURLs), 0)
+}
+
+func TestSearchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	searchFn := &SearchFn{
+		CommonFn:    common.CommonFn{SearchString: "#NBA"},
+		InstanceURL: server.URL,
+	}
+
+	_, err := searchFn.Search()
+	assert.ErrorContains(t, err, "429")
+}
+
+func TestSearchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	searchFn := &SearchFn{
+		CommonFn:    common.CommonFn{SearchString: "#NBA", Timeout: 1},
+		InstanceURL: server.URL,
+	}
+
+	_, err := searchFn.Search()
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+// Private
+
+func newFixtureServer(t *testing.T, fixture string, request **http.Request) *httptest.Server {
+	body, err := ioutil.ReadFile(fixture)
+	assert.NilError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		*request = r
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(body)
+	}))
+}
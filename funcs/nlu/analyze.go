@@ -0,0 +1,171 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	BackendLexicon = "lexicon"
+	BackendWatson  = "watson"
+
+	SentimentPositive = "positive"
+	SentimentNegative = "negative"
+	SentimentNeutral  = "neutral"
+)
+
+type keys struct {
+	watsonNLUAPIKey     string
+	watsonNLUAPIURL     string
+	watsonNLUAPIVersion string
+}
+
+type Sentiment struct {
+	Label string  `yaml:"label" json:"label"`
+	Score float64 `yaml:"score" json:"score"`
+}
+
+type Keyword struct {
+	Text      string  `yaml:"text" json:"text"`
+	Relevance float64 `yaml:"relevance" json:"relevance"`
+}
+
+type Entity struct {
+	Text      string  `yaml:"text" json:"text"`
+	Type      string  `yaml:"type" json:"type"`
+	Relevance float64 `yaml:"relevance" json:"relevance"`
+}
+
+type AnalysisData struct {
+	Text      string    `yaml:"text" json:"text"`
+	Backend   string    `yaml:"backend" json:"backend"`
+	Sentiment Sentiment `yaml:"sentiment" json:"sentiment"`
+	Keywords  []Keyword `yaml:"keywords" json:"keywords"`
+	Entities  []Entity  `yaml:"entities" json:"entities"`
+}
+
+// Analyzer scores a text for sentiment, keywords and named entities
+type Analyzer interface {
+	Analyze(text string, limit int) (AnalysisData, error)
+}
+
+type AnalyzeFn struct {
+	common.CommonFn
+	common.CORSFn
+
+	Text    string
+	Backend string
+
+	keys keys
+}
+
+func SupportedBackends() []string {
+	return []string{BackendLexicon, BackendWatson}
+}
+
+func (analyzeFn *AnalyzeFn) Analyze() (AnalysisData, error) {
+	analyzer, err := analyzeFn.newAnalyzer()
+	if err != nil {
+		return AnalysisData{}, err
+	}
+
+	return analyzer.Analyze(analyzeFn.Text, analyzeFn.Count)
+}
+
+func (analyzeFn *AnalyzeFn) AnalyzeHandler(writer http.ResponseWriter, request *http.Request) {
+	if analyzeFn.WriteCORSHeaders(writer, request) {
+		return
+	}
+
+	analyzeFn.initQueryParams(request)
+	log.Printf("NLUFn.Analyze: q=\"%s\", backend=\"%s\", c=\"%d\", o=\"%s\"", analyzeFn.Text, analyzeFn.Backend, analyzeFn.Count, analyzeFn.Output)
+
+	analysisData, err := analyzeFn.Analyze()
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, &analysisData, analyzeFn.Output, analysisData.ToText)
+}
+
+// Private AnalyzeFn
+
+func (analyzeFn *AnalyzeFn) initQueryParams(request *http.Request) {
+	analyzeFn.Text = analyzeFn.ExtractQueryStringParam(request, []string{"q", "query", "text", "t"}, analyzeFn.Text)
+	analyzeFn.Backend = analyzeFn.ExtractQueryStringParam(request, []string{"backend", "b"}, analyzeFn.Backend)
+	analyzeFn.Count = analyzeFn.ExtractQueryIntParam(request, []string{"c", "count"}, analyzeFn.Count)
+	analyzeFn.Output = analyzeFn.ExtractQueryStringParam(request, []string{"o", "output"}, analyzeFn.Output)
+}
+
+func (analyzeFn *AnalyzeFn) newAnalyzer() (Analyzer, error) {
+	switch strings.ToLower(analyzeFn.Backend) {
+	case "", BackendLexicon:
+		return NewLexiconAnalyzer(), nil
+	case BackendWatson:
+		return NewWatsonAnalyzer(analyzeFn.keys.watsonNLUAPIURL, analyzeFn.keys.watsonNLUAPIKey, analyzeFn.keys.watsonNLUAPIVersion, analyzeFn.Timeout)
+	}
+
+	return nil, fmt.Errorf("invalid backend '%s', supported backends are: %s", analyzeFn.Backend, strings.Join(SupportedBackends(), ", "))
+}
+
+// Private functions
+
+func sentimentLabel(score float64) string {
+	switch {
+	case score > 0.05:
+		return SentimentPositive
+	case score < -0.05:
+		return SentimentNegative
+	}
+	return SentimentNeutral
+}
+
+func sentimentEmoji(label string) string {
+	switch label {
+	case SentimentPositive:
+		return "🙂"
+	case SentimentNegative:
+		return "🙁"
+	}
+	return "😐"
+}
+
+// Public AnalysisData
+
+func (aData AnalysisData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+
+	sb.WriteString(fmt.Sprintf("text: %s\n", aData.Text))
+	sb.WriteString(fmt.Sprintf("sentiment: %s %s (%1.3f)\n", sentimentEmoji(aData.Sentiment.Label), aData.Sentiment.Label, aData.Sentiment.Score))
+	sb.WriteString("----\n")
+	for _, keyword := range aData.Keywords {
+		sb.WriteString(fmt.Sprintf("keyword: %s (%1.3f)\n", keyword.Text, keyword.Relevance))
+	}
+	for _, entity := range aData.Entities {
+		sb.WriteString(fmt.Sprintf("entity: %s [%s] (%1.3f)\n", entity.Text, entity.Type, entity.Relevance))
+	}
+	sb.WriteString("----\n")
+
+	return sb.String()
+}
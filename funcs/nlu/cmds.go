@@ -0,0 +1,192 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	analyzeFn *AnalyzeFn
+)
+
+func NewNLUCmd() *cobra.Command {
+	analyzeFn = &AnalyzeFn{
+		keys: keys{},
+	}
+
+	cobra.OnInitialize(analyzeFn.InitConfig)
+
+	nluCmd := &cobra.Command{
+		Use:   "nlu",
+		Short: "natural language understanding root function",
+		Long:  `Various natural language understanding functions over text`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			analyzeFn.initNLUFlags()
+			analyzeFn.InitCORSInputFlags()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze [TEXT]",
+		Short: "analyze text",
+		Long: `analyze the sentiment, keywords and named entities of TEXT with the
+offline lexicon backend or the Watson NLU backend`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			analyzeFn.initNLUFlags()
+			return analyzeFn.initAnalyzeCmdInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return analyzeFn.analyze(cmd, args)
+		},
+	}
+
+	analyzeFn.AddCommonCmdFlags(analyzeCmd)
+	analyzeFn.addNLUCmdFlags(nluCmd)
+	analyzeFn.AddCORSCmdFlags(nluCmd)
+	analyzeFn.addAnalyzeCmdFlags(analyzeCmd)
+
+	nluCmd.AddCommand(analyzeCmd)
+
+	return nluCmd
+}
+
+func Execute() error {
+	return NewNLUCmd().Execute()
+}
+
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if analyzeFn == nil {
+		NewNLUCmd()
+	}
+
+	analyzeFn.initNLUFlags()
+	analyzeFn.InitCORSInputFlags()
+	return analyzeFn.handler(), nil
+}
+
+// Private
+
+func (analyzeFn *AnalyzeFn) analyze(cmd *cobra.Command, args []string) error {
+	if analyzeFn.StartServer {
+		return analyzeFn.ListenAndServe(analyzeFn.handler())
+	} else if analyzeFn.Stdin {
+		return analyzeFn.ProcessLines(os.Stdin, os.Stdout, analyzeFn.analyzeLine)
+	} else {
+		analysisData, err := analyzeFn.Analyze()
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &analysisData, analyzeFn.Output, analysisData.ToText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// analyzeLine analyzes a line of the standard input as text
+func (analyzeFn *AnalyzeFn) analyzeLine(line string) (string, error) {
+	lineFn := *analyzeFn
+	lineFn.Text = line
+
+	analysisData, err := lineFn.Analyze()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&analysisData, lineFn.Output, analysisData.ToText)
+}
+
+func (analyzeFn *AnalyzeFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", analyzeFn.HealthzHandler)
+	mux.HandleFunc("/", analyzeFn.AnalyzeHandler)
+	return mux
+}
+
+func (analyzeFn *AnalyzeFn) addNLUCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&analyzeFn.Backend, "nlu-backend", "", fmt.Sprintf("the analyzer backend: %s (default %s)", strings.Join(SupportedBackends(), ", "), BackendLexicon))
+	cmd.PersistentFlags().StringVar(&analyzeFn.keys.watsonNLUAPIKey, "watson-nlu-api-key", "", "watson NLU API key")
+	cmd.PersistentFlags().StringVar(&analyzeFn.keys.watsonNLUAPIURL, "watson-nlu-api-url", "", "watson NLU API URL")
+	cmd.PersistentFlags().StringVar(&analyzeFn.keys.watsonNLUAPIVersion, "watson-nlu-api-version", "", fmt.Sprintf("watson NLU API version (default %s)", defaultWatsonNLUAPIVersion))
+
+	viper.BindPFlag("nlu-backend", cmd.PersistentFlags().Lookup("nlu-backend"))
+	viper.BindPFlag("watson-nlu-api-key", cmd.PersistentFlags().Lookup("watson-nlu-api-key"))
+	viper.BindPFlag("watson-nlu-api-url", cmd.PersistentFlags().Lookup("watson-nlu-api-url"))
+	viper.BindPFlag("watson-nlu-api-version", cmd.PersistentFlags().Lookup("watson-nlu-api-version"))
+}
+
+func (analyzeFn *AnalyzeFn) addAnalyzeCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&analyzeFn.Text, "text", "t", "", "the text to analyze")
+}
+
+func (analyzeFn *AnalyzeFn) initAnalyzeCmdInputFlags(args []string) error {
+	if len(args) == 1 {
+		analyzeFn.Text = args[0]
+	}
+
+	if analyzeFn.Text == "" && !analyzeFn.StartServer && !analyzeFn.Stdin {
+		return errors.New("you must pass a text to analyze")
+	}
+
+	return nil
+}
+
+// initNLUFlags reads the flags not set from the config file and the
+// environment, and only then applies their defaults
+func (analyzeFn *AnalyzeFn) initNLUFlags() {
+	if analyzeFn.Backend == "" {
+		analyzeFn.Backend = viper.GetString("nlu-backend")
+	}
+	if analyzeFn.Backend == "" {
+		analyzeFn.Backend = BackendLexicon
+	}
+
+	if analyzeFn.keys.watsonNLUAPIKey == "" {
+		analyzeFn.keys.watsonNLUAPIKey = viper.GetString("watson-nlu-api-key")
+	}
+
+	if analyzeFn.keys.watsonNLUAPIURL == "" {
+		analyzeFn.keys.watsonNLUAPIURL = viper.GetString("watson-nlu-api-url")
+	}
+
+	if analyzeFn.keys.watsonNLUAPIVersion == "" {
+		analyzeFn.keys.watsonNLUAPIVersion = viper.GetString("watson-nlu-api-version")
+	}
+	if analyzeFn.keys.watsonNLUAPIVersion == "" {
+		analyzeFn.keys.watsonNLUAPIVersion = defaultWatsonNLUAPIVersion
+	}
+}
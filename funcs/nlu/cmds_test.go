@@ -0,0 +1,63 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gotest.tools/assert"
+)
+
+func TestInitNLUFlagsDefaults(t *testing.T) {
+	defer viper.Reset()
+
+	NewNLUCmd()
+	analyzeFn.initNLUFlags()
+
+	assert.Equal(t, analyzeFn.Backend, BackendLexicon)
+	assert.Equal(t, analyzeFn.keys.watsonNLUAPIVersion, defaultWatsonNLUAPIVersion)
+}
+
+func TestInitNLUFlagsConfigFile(t *testing.T) {
+	defer viper.Reset()
+
+	configFile, err := ioutil.TempFile("", "knfun*.yaml")
+	assert.NilError(t, err)
+	defer os.Remove(configFile.Name())
+
+	_, err = configFile.WriteString("nlu-backend: watson\nwatson-nlu-api-url: http://nlu.example\nwatson-nlu-api-version: 2022-04-07\n")
+	assert.NilError(t, err)
+	configFile.Close()
+
+	NewNLUCmd()
+	viper.SetConfigFile(configFile.Name())
+	assert.NilError(t, viper.ReadInConfig())
+	analyzeFn.initNLUFlags()
+
+	assert.Equal(t, analyzeFn.Backend, BackendWatson)
+	assert.Equal(t, analyzeFn.keys.watsonNLUAPIURL, "http://nlu.example")
+	assert.Equal(t, analyzeFn.keys.watsonNLUAPIVersion, "2022-04-07")
+
+	// the flags override the config file
+	nluCmd := NewNLUCmd()
+	assert.NilError(t, nluCmd.PersistentFlags().Set("nlu-backend", BackendLexicon))
+	analyzeFn.initNLUFlags()
+
+	assert.Equal(t, analyzeFn.Backend, BackendLexicon)
+	assert.Equal(t, analyzeFn.keys.watsonNLUAPIVersion, "2022-04-07")
+}
@@ -0,0 +1,313 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	EntityMention    = "Mention"
+	EntityHashtag    = "Hashtag"
+	EntityURL        = "URL"
+	EntityProperNoun = "ProperNoun"
+
+	// normalizationAlpha bounds the summed word scores to [-1, 1] the same way
+	// rule-based analyzers like VADER do
+	normalizationAlpha = 15.0
+	intensifierFactor  = 1.5
+	negationWindow     = 3
+)
+
+var (
+	tokenRegexp = regexp.MustCompile(`https?://\S+|[@#]?[\p{L}\p{N}][\p{L}\p{N}'’_-]*|[.!?]+|[^\s\p{L}\p{N}]`)
+
+	sentimentLexicon = map[string]float64{
+		"amazing": 3, "awesome": 3, "beautiful": 3, "best": 3, "brilliant": 3, "excellent": 3, "fantastic": 3,
+		"incredible": 3, "love": 3, "loved": 3, "loving": 3, "outstanding": 3, "perfect": 3, "superb": 3, "wonderful": 3,
+		"congrats": 2, "congratulations": 2, "cool": 2, "enjoy": 2, "enjoyed": 2, "excited": 2, "exciting": 2, "fun": 2,
+		"glad": 2, "good": 2, "great": 2, "happy": 2, "impressive": 2, "like": 1, "liked": 1, "lol": 2, "nice": 2,
+		"proud": 2, "thanks": 2, "thank": 2, "win": 2, "wins": 2, "won": 2, "winning": 2, "yay": 2, "wow": 2,
+		"agree": 1, "better": 1, "clean": 1, "easy": 1, "fair": 1, "fine": 1, "fresh": 1, "help": 1, "helpful": 1,
+		"hope": 1, "interesting": 1, "okay": 1, "ok": 1, "safe": 1, "smart": 1, "strong": 1, "support": 1, "welcome": 1,
+		"awful": -3, "disaster": -3, "disgusting": -3, "hate": -3, "hated": -3, "horrible": -3, "terrible": -3,
+		"worst": -3, "pathetic": -3, "angry": -2, "annoying": -2, "bad": -2, "boring": -2, "broken": -2, "crash": -2,
+		"cry": -2, "dead": -2, "disappointed": -2, "disappointing": -2, "fail": -2, "failed": -2, "fails": -2,
+		"lose": -2, "loses": -2, "losing": -2, "lost": -2, "mad": -2, "poor": -2, "sad": -2, "scary": -2, "sick": -2,
+		"stupid": -2, "ugly": -2, "upset": -2, "wrong": -2, "injured": -2, "injury": -2, "killed": -3, "war": -2,
+		"afraid": -1, "bug": -1, "concern": -1, "difficult": -1, "doubt": -1, "hard": -1, "miss": -1, "missed": -1,
+		"problem": -1, "slow": -1, "sorry": -1, "tired": -1, "weak": -1, "worse": -1, "worried": -1, "wtf": -2,
+		"🙂": 2, "😀": 2, "😃": 2, "😄": 2, "😁": 2, "😊": 2, "😍": 3, "🥰": 3, "❤": 3, "❤️": 3, "👍": 2, "🎉": 2,
+		"🔥": 1, "💯": 2, "😂": 1, "🙁": -2, "☹": -2, "😞": -2, "😢": -2, "😭": -2, "😡": -3, "😠": -2, "👎": -2, "💔": -2,
+	}
+
+	intensifiers = map[string]bool{
+		"very": true, "really": true, "so": true, "extremely": true, "super": true, "totally": true,
+		"absolutely": true, "incredibly": true, "most": true,
+	}
+
+	negations = map[string]bool{
+		"not": true, "no": true, "never": true, "none": true, "nobody": true, "nothing": true, "neither": true,
+		"nor": true, "without": true, "cannot": true, "can't": true, "don't": true, "doesn't": true, "didn't": true,
+		"isn't": true, "wasn't": true, "aren't": true, "won't": true, "wouldn't": true, "shouldn't": true, "ain't": true,
+	}
+
+	stopWords = map[string]bool{
+		"a": true, "about": true, "after": true, "all": true, "also": true, "am": true, "an": true, "and": true,
+		"any": true, "are": true, "as": true, "at": true, "be": true, "been": true, "before": true, "being": true,
+		"but": true, "by": true, "can": true, "could": true, "did": true, "do": true, "does": true, "for": true,
+		"from": true, "get": true, "got": true, "had": true, "has": true, "have": true, "he": true, "her": true,
+		"here": true, "him": true, "his": true, "how": true, "i": true, "i'm": true, "if": true, "in": true,
+		"into": true, "is": true, "it": true, "it's": true, "its": true, "just": true, "me": true, "more": true,
+		"my": true, "now": true, "of": true, "on": true, "one": true, "only": true, "or": true, "our": true,
+		"out": true, "over": true, "rt": true, "she": true, "should": true, "some": true, "than": true, "that": true,
+		"the": true, "their": true, "them": true, "then": true, "there": true, "these": true, "they": true,
+		"this": true, "those": true, "to": true, "too": true, "up": true, "us": true, "via": true, "was": true,
+		"we": true, "were": true, "what": true, "when": true, "where": true, "which": true, "while": true,
+		"who": true, "why": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+	}
+)
+
+// LexiconAnalyzer is an offline rule-based analyzer. Sentiment sums the scores
+// of lexicon words and emojis, handling negations and intensifiers. Keywords
+// are the most frequent non-stop words and entities are mentions, hashtags,
+// URLs and capitalized proper nouns
+type LexiconAnalyzer struct {
+	Lexicon map[string]float64
+}
+
+type token struct {
+	text  string
+	lower string
+	start bool
+}
+
+func NewLexiconAnalyzer() *LexiconAnalyzer {
+	return &LexiconAnalyzer{Lexicon: sentimentLexicon}
+}
+
+func (lexiconAnalyzer *LexiconAnalyzer) Analyze(text string, limit int) (AnalysisData, error) {
+	tokens := tokenize(text)
+
+	score := lexiconAnalyzer.sentimentScore(tokens)
+	return AnalysisData{
+		Text:    text,
+		Backend: BackendLexicon,
+		Sentiment: Sentiment{
+			Label: sentimentLabel(score),
+			Score: score,
+		},
+		Keywords: lexiconAnalyzer.keywords(tokens, limit),
+		Entities: lexiconAnalyzer.entities(tokens, limit),
+	}, nil
+}
+
+// Private LexiconAnalyzer
+
+func (lexiconAnalyzer *LexiconAnalyzer) sentimentScore(tokens []token) float64 {
+	sum := 0.0
+	for i, t := range tokens {
+		wordScore, ok := lexiconAnalyzer.Lexicon[strings.TrimPrefix(t.lower, "#")]
+		if !ok {
+			continue
+		}
+
+		for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+			if isSentenceEnd(tokens[j].lower) {
+				break
+			}
+			if intensifiers[tokens[j].lower] && j == i-1 {
+				wordScore *= intensifierFactor
+			}
+			if negations[normalizeApostrophe(tokens[j].lower)] {
+				wordScore *= -0.5
+				break
+			}
+		}
+		sum += wordScore
+	}
+
+	return sum / math.Sqrt(sum*sum+normalizationAlpha)
+}
+
+func (lexiconAnalyzer *LexiconAnalyzer) keywords(tokens []token, limit int) []Keyword {
+	counter := newCounter()
+	for _, t := range tokens {
+		if strings.HasPrefix(t.lower, "@") || isURL(t.lower) {
+			continue
+		}
+
+		word := strings.TrimPrefix(t.lower, "#")
+		if len([]rune(word)) < 3 || stopWords[word] || negations[normalizeApostrophe(word)] || intensifiers[word] || !isWord(word) {
+			continue
+		}
+		counter.add(word)
+	}
+
+	keywords := []Keyword{}
+	for _, item := range counter.top(limit) {
+		keywords = append(keywords, Keyword{Text: item.text, Relevance: item.relevance})
+	}
+	return keywords
+}
+
+func (lexiconAnalyzer *LexiconAnalyzer) entities(tokens []token, limit int) []Entity {
+	counter := newCounter()
+	types := map[string]string{}
+	add := func(text string, entityType string) {
+		counter.add(text)
+		types[text] = entityType
+	}
+
+	properNoun := []string{}
+	flushProperNoun := func() {
+		if len(properNoun) > 0 {
+			add(strings.Join(properNoun, " "), EntityProperNoun)
+			properNoun = []string{}
+		}
+	}
+
+	for _, t := range tokens {
+		switch {
+		case isURL(t.lower):
+			flushProperNoun()
+			add(t.text, EntityURL)
+		case strings.HasPrefix(t.text, "@") && len(t.text) > 1:
+			flushProperNoun()
+			add(t.text, EntityMention)
+		case strings.HasPrefix(t.text, "#") && len(t.text) > 1:
+			flushProperNoun()
+			add(t.text, EntityHashtag)
+		case lexiconAnalyzer.isProperNoun(t):
+			properNoun = append(properNoun, t.text)
+		default:
+			flushProperNoun()
+		}
+	}
+	flushProperNoun()
+
+	entities := []Entity{}
+	for _, item := range counter.top(limit) {
+		entities = append(entities, Entity{Text: item.text, Type: types[item.text], Relevance: item.relevance})
+	}
+	return entities
+}
+
+func (lexiconAnalyzer *LexiconAnalyzer) isProperNoun(t token) bool {
+	runes := []rune(t.text)
+	if len(runes) < 2 || !unicode.IsUpper(runes[0]) || !isWord(t.lower) {
+		return false
+	}
+
+	if stopWords[t.lower] || negations[normalizeApostrophe(t.lower)] || intensifiers[t.lower] {
+		return false
+	}
+
+	if _, ok := lexiconAnalyzer.Lexicon[t.lower]; ok {
+		return false
+	}
+
+	// all caps words at the start of sentences are usually shouting, not names
+	return !(t.start && strings.ToUpper(t.text) == t.text && len(runes) > 4)
+}
+
+// Private functions
+
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := true
+	for _, match := range tokenRegexp.FindAllString(text, -1) {
+		tokens = append(tokens, token{text: match, lower: strings.ToLower(match), start: start})
+		start = isSentenceEnd(match)
+	}
+	return tokens
+}
+
+func isSentenceEnd(text string) bool {
+	return strings.Trim(text, ".!?") == ""
+}
+
+func isURL(text string) bool {
+	return strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")
+}
+
+func isWord(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeApostrophe(text string) string {
+	return strings.Replace(text, "’", "'", -1)
+}
+
+type counterItem struct {
+	text      string
+	count     int
+	index     int
+	relevance float64
+}
+
+type counter struct {
+	items map[string]*counterItem
+}
+
+func newCounter() *counter {
+	return &counter{items: map[string]*counterItem{}}
+}
+
+func (c *counter) add(text string) {
+	if item, ok := c.items[text]; ok {
+		item.count++
+		return
+	}
+	c.items[text] = &counterItem{text: text, count: 1, index: len(c.items)}
+}
+
+// top returns the items sorted by count then first occurrence, with relevance
+// relative to the most frequent item
+func (c *counter) top(limit int) []counterItem {
+	items := []counterItem{}
+	maxCount := 0
+	for _, item := range c.items {
+		items = append(items, *item)
+		if item.count > maxCount {
+			maxCount = item.count
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].count != items[j].count {
+			return items[i].count > items[j].count
+		}
+		return items[i].index < items[j].index
+	})
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	for i := range items {
+		items[i].relevance = float64(items[i].count) / float64(maxCount)
+	}
+	return items
+}
@@ -0,0 +1,67 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestLexiconSentiment(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		label string
+	}{
+		{"What an amazing game, I love this team! 🎉", SentimentPositive},
+		{"Worst call ever, the refs are terrible 😡", SentimentNegative},
+		{"The game starts at 8pm tonight", SentimentNeutral},
+		{"This is not good", SentimentNegative},
+		{"Not bad at all", SentimentPositive},
+	} {
+		aData, err := NewLexiconAnalyzer().Analyze(tc.text, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, aData.Sentiment.Label, tc.label, tc.text)
+		assert.Assert(t, aData.Sentiment.Score >= -1 && aData.Sentiment.Score <= 1)
+	}
+}
+
+func TestLexiconIntensifier(t *testing.T) {
+	good, _ := NewLexiconAnalyzer().Analyze("good", 10)
+	veryGood, _ := NewLexiconAnalyzer().Analyze("very good", 10)
+	assert.Assert(t, veryGood.Sentiment.Score > good.Sentiment.Score)
+}
+
+func TestLexiconKeywords(t *testing.T) {
+	aData, err := NewLexiconAnalyzer().Analyze("Knative functions are fun. Knative scales functions to zero! #knative", 2)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, aData.Keywords, []Keyword{
+		Keyword{Text: "knative", Relevance: 1},
+		Keyword{Text: "functions", Relevance: 2.0 / 3.0},
+	})
+}
+
+func TestLexiconEntities(t *testing.T) {
+	aData, err := NewLexiconAnalyzer().Analyze("Great dunk by LeBron James tonight @Lakers #NBA https://t.co/abc", 0)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, aData.Entities, []Entity{
+		Entity{Text: "LeBron James", Type: EntityProperNoun, Relevance: 1},
+		Entity{Text: "@Lakers", Type: EntityMention, Relevance: 1},
+		Entity{Text: "#NBA", Type: EntityHashtag, Relevance: 1},
+		Entity{Text: "https://t.co/abc", Type: EntityURL, Relevance: 1},
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatsonNLUAPIVersion = "2021-08-01"
+	defaultWatsonNLUTimeout    = 30
+)
+
+// WatsonAnalyzer calls the Watson Natural Language Understanding `analyze` API
+type WatsonAnalyzer struct {
+	APIURL     string
+	APIKey     string
+	APIVersion string
+
+	client http.Client
+}
+
+type watsonFeatureOptions struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+type watsonAnalyzeRequest struct {
+	Text     string `json:"text"`
+	Features struct {
+		Sentiment struct{}             `json:"sentiment"`
+		Keywords  watsonFeatureOptions `json:"keywords"`
+		Entities  watsonFeatureOptions `json:"entities"`
+	} `json:"features"`
+}
+
+type watsonAnalyzeResponse struct {
+	Sentiment struct {
+		Document struct {
+			Label string  `json:"label"`
+			Score float64 `json:"score"`
+		} `json:"document"`
+	} `json:"sentiment"`
+	Keywords []struct {
+		Text      string  `json:"text"`
+		Relevance float64 `json:"relevance"`
+	} `json:"keywords"`
+	Entities []struct {
+		Text      string  `json:"text"`
+		Type      string  `json:"type"`
+		Relevance float64 `json:"relevance"`
+	} `json:"entities"`
+	Error string `json:"error"`
+}
+
+// NewWatsonAnalyzer calls the API, timing out after the timeout in seconds, or
+// defaultWatsonNLUTimeout when not set
+func NewWatsonAnalyzer(apiURL string, apiKey string, apiVersion string, timeout int) (*WatsonAnalyzer, error) {
+	if apiURL == "" || apiKey == "" {
+		return nil, errors.New("the watson backend requires --watson-nlu-api-url and --watson-nlu-api-key")
+	}
+
+	if apiVersion == "" {
+		apiVersion = defaultWatsonNLUAPIVersion
+	}
+
+	if timeout <= 0 {
+		timeout = defaultWatsonNLUTimeout
+	}
+
+	return &WatsonAnalyzer{
+		APIURL:     apiURL,
+		APIKey:     apiKey,
+		APIVersion: apiVersion,
+		client: http.Client{
+			Timeout: time.Second * time.Duration(timeout),
+		},
+	}, nil
+}
+
+func (watsonAnalyzer *WatsonAnalyzer) Analyze(text string, limit int) (AnalysisData, error) {
+	analyzeRequest := watsonAnalyzeRequest{Text: text}
+	analyzeRequest.Features.Keywords.Limit = limit
+	analyzeRequest.Features.Entities.Limit = limit
+
+	body, err := json.Marshal(&analyzeRequest)
+	if err != nil {
+		return AnalysisData{}, err
+	}
+
+	analyzeURL, err := url.Parse(strings.TrimSuffix(watsonAnalyzer.APIURL, "/") + "/v1/analyze")
+	if err != nil {
+		return AnalysisData{}, err
+	}
+	query := analyzeURL.Query()
+	query.Set("version", watsonAnalyzer.APIVersion)
+	analyzeURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, analyzeURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return AnalysisData{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth("apikey", watsonAnalyzer.APIKey)
+
+	res, err := watsonAnalyzer.client.Do(req)
+	if err != nil {
+		return AnalysisData{}, fmt.Errorf("error calling Watson NLU: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return AnalysisData{}, err
+	}
+
+	analyzeResponse := watsonAnalyzeResponse{}
+	err = json.Unmarshal(resBody, &analyzeResponse)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if err == nil && analyzeResponse.Error != "" {
+			return AnalysisData{}, fmt.Errorf("error calling Watson NLU: %s", analyzeResponse.Error)
+		}
+		return AnalysisData{}, fmt.Errorf("error calling Watson NLU: %s", res.Status)
+	}
+	if err != nil {
+		return AnalysisData{}, fmt.Errorf("error decoding Watson NLU response: %s", err.Error())
+	}
+
+	return analyzeResponse.analysisData(text), nil
+}
+
+// Private watsonAnalyzeResponse
+
+func (analyzeResponse watsonAnalyzeResponse) analysisData(text string) AnalysisData {
+	aData := AnalysisData{
+		Text:    text,
+		Backend: BackendWatson,
+		Sentiment: Sentiment{
+			Label: analyzeResponse.Sentiment.Document.Label,
+			Score: analyzeResponse.Sentiment.Document.Score,
+		},
+		Keywords: []Keyword{},
+		Entities: []Entity{},
+	}
+
+	if aData.Sentiment.Label == "" {
+		aData.Sentiment.Label = sentimentLabel(aData.Sentiment.Score)
+	}
+
+	for _, keyword := range analyzeResponse.Keywords {
+		aData.Keywords = append(aData.Keywords, Keyword{Text: keyword.Text, Relevance: keyword.Relevance})
+	}
+
+	for _, entity := range analyzeResponse.Entities {
+		aData.Entities = append(aData.Entities, Entity{Text: entity.Text, Type: entity.Type, Relevance: entity.Relevance})
+	}
+
+	return aData
+}
@@ -0,0 +1,91 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nlu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestWatsonAnalyze(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		username, password, _ := request.BasicAuth()
+		if username != "apikey" || password != "secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(writer, `{"error": "Unauthorized", "code": 401}`)
+			return
+		}
+
+		analyzeRequest := watsonAnalyzeRequest{}
+		json.NewDecoder(request.Body).Decode(&analyzeRequest)
+
+		assert.Equal(t, request.URL.Path, "/v1/analyze")
+		assert.Equal(t, request.URL.Query().Get("version"), "2021-08-01")
+		assert.Equal(t, analyzeRequest.Text, "IBM loves Knative")
+		assert.Equal(t, analyzeRequest.Features.Keywords.Limit, 5)
+
+		fmt.Fprint(writer, `{
+			"sentiment": {"document": {"score": 0.85, "label": "positive"}},
+			"keywords": [{"text": "Knative", "relevance": 0.9}],
+			"entities": [{"type": "Company", "text": "IBM", "relevance": 0.95}]
+		}`)
+	}))
+	defer server.Close()
+
+	watsonAnalyzer, err := NewWatsonAnalyzer(server.URL+"/", "secret", "", 10)
+	assert.NilError(t, err)
+
+	aData, err := watsonAnalyzer.Analyze("IBM loves Knative", 5)
+	assert.NilError(t, err)
+	assert.Equal(t, aData.Backend, BackendWatson)
+	assert.DeepEqual(t, aData.Sentiment, Sentiment{Label: SentimentPositive, Score: 0.85})
+	assert.DeepEqual(t, aData.Keywords, []Keyword{Keyword{Text: "Knative", Relevance: 0.9}})
+	assert.DeepEqual(t, aData.Entities, []Entity{Entity{Text: "IBM", Type: "Company", Relevance: 0.95}})
+
+	watsonAnalyzer.APIKey = "invalid"
+	_, err = watsonAnalyzer.Analyze("IBM loves Knative", 5)
+	assert.ErrorContains(t, err, "Unauthorized")
+}
+
+func TestNewWatsonAnalyzerTimeout(t *testing.T) {
+	watsonAnalyzer, err := NewWatsonAnalyzer("https://nlu.example", "secret", "", 0)
+	assert.NilError(t, err)
+	assert.Equal(t, watsonAnalyzer.client.Timeout, defaultWatsonNLUTimeout*time.Second)
+
+	analyzeFn := &AnalyzeFn{Backend: BackendWatson, keys: keys{watsonNLUAPIURL: "https://nlu.example", watsonNLUAPIKey: "secret"}}
+	analyzeFn.Timeout = 5
+	analyzer, err := analyzeFn.newAnalyzer()
+	assert.NilError(t, err)
+	assert.Equal(t, analyzer.(*WatsonAnalyzer).client.Timeout, 5*time.Second)
+}
+
+func TestNewAnalyzer(t *testing.T) {
+	_, err := (&AnalyzeFn{Backend: BackendWatson}).newAnalyzer()
+	assert.ErrorContains(t, err, "--watson-nlu-api-url")
+
+	_, err = (&AnalyzeFn{Backend: "unknown"}).newAnalyzer()
+	assert.ErrorContains(t, err, "invalid backend")
+
+	analyzer, err := (&AnalyzeFn{}).newAnalyzer()
+	assert.NilError(t, err)
+	_, ok := analyzer.(*LexiconAnalyzer)
+	assert.Assert(t, ok)
+}
@@ -0,0 +1,172 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reddit
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	searchFn *SearchFn
+)
+
+func NewRedditCmd() *cobra.Command {
+	searchFn = &SearchFn{}
+
+	cobra.OnInitialize(searchFn.InitConfig)
+
+	redditCmd := &cobra.Command{
+		Use:   "reddit",
+		Short: "Reddit root function",
+		Long:  `Various functions over the Reddit API`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			searchFn.initRedditFlags()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	searchCmd := &cobra.Command{
+		Use:   "search [SEARCH_STRING]",
+		Short: "Search for posts and extract contents",
+		Long: `Searches Reddit for posts matching some string criteria
+and responds with the content of these posts. Search strings of the form
+'r/SUBREDDIT' list the subreddit, others use the search API`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			searchFn.initRedditFlags()
+			return searchFn.InitCommonInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchFn.search(cmd, args)
+		},
+	}
+
+	searchFn.AddCommonCmdFlags(searchCmd)
+	searchFn.addRedditCmdFlags(redditCmd)
+
+	redditCmd.AddCommand(searchCmd)
+
+	return redditCmd
+}
+
+func Execute() error {
+	return NewRedditCmd().Execute()
+}
+
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if searchFn == nil {
+		NewRedditCmd()
+	}
+
+	searchFn.initRedditFlags()
+	return searchFn.handler(), nil
+}
+
+// Private
+
+func (searchFn *SearchFn) search(cmd *cobra.Command, args []string) error {
+	if searchFn.StartServer {
+		return searchFn.ListenAndServe(searchFn.handler())
+	} else if searchFn.Stdin {
+		return searchFn.ProcessLines(os.Stdin, os.Stdout, searchFn.searchLine)
+	} else {
+		postsData, err := searchFn.Search()
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &postsData, searchFn.Output, postsData.ToText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchLine searches for a line of the standard input as search string
+func (searchFn *SearchFn) searchLine(line string) (string, error) {
+	lineFn := *searchFn
+	lineFn.SearchString = line
+
+	postsData, err := lineFn.Search()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&postsData, lineFn.Output, postsData.ToText)
+}
+
+func (searchFn *SearchFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", searchFn.HealthzHandler)
+	mux.HandleFunc("/", searchFn.SearchHandler)
+	return mux
+}
+
+func (searchFn *SearchFn) addRedditCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&searchFn.RedditURL, "reddit-url", "", "reddit API URL (default https://www.reddit.com)")
+	cmd.PersistentFlags().StringVar(&searchFn.Subreddit, "reddit-subreddit", "", "restrict searches to this subreddit")
+	cmd.PersistentFlags().StringVar(&searchFn.Listing, "reddit-listing", "", "the listing or sort order: hot, new, top, ... (default new)")
+	cmd.PersistentFlags().StringVar(&searchFn.UserAgent, "reddit-user-agent", "", "the User-Agent sent to reddit (default knfun:reddit-fn)")
+
+	viper.BindPFlag("reddit-url", cmd.PersistentFlags().Lookup("reddit-url"))
+	viper.BindPFlag("reddit-subreddit", cmd.PersistentFlags().Lookup("reddit-subreddit"))
+	viper.BindPFlag("reddit-listing", cmd.PersistentFlags().Lookup("reddit-listing"))
+	viper.BindPFlag("reddit-user-agent", cmd.PersistentFlags().Lookup("reddit-user-agent"))
+}
+
+func (searchFn *SearchFn) initRedditFlags() {
+	if searchFn.RedditURL == "" {
+		searchFn.RedditURL = viper.GetString("reddit-url")
+	}
+
+	if searchFn.RedditURL == "" {
+		searchFn.RedditURL = "https://www.reddit.com"
+	}
+
+	if searchFn.Subreddit == "" {
+		searchFn.Subreddit = viper.GetString("reddit-subreddit")
+	}
+
+	if searchFn.Listing == "" {
+		searchFn.Listing = viper.GetString("reddit-listing")
+	}
+
+	if searchFn.Listing == "" {
+		searchFn.Listing = "new"
+	}
+
+	if searchFn.UserAgent == "" {
+		searchFn.UserAgent = viper.GetString("reddit-user-agent")
+	}
+
+	if searchFn.UserAgent == "" {
+		searchFn.UserAgent = "knfun:reddit-fn"
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reddit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const subredditPrefix = "r/"
+
+type PostData struct {
+	ID        string   `yaml:"id,omitempty" json:"id,omitempty"`
+	Text      string   `yaml:"text" json:"text"`
+	ImageURLs []string `yaml:"image-urls" json:"image-urls"`
+	CreatedAt string   `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+}
+
+type PostsData []PostData
+
+type SearchFn struct {
+	common.CommonFn
+
+	RedditURL string
+	Subreddit string
+	Listing   string
+	UserAgent string
+}
+
+type redditImageSource struct {
+	URL string `json:"url"`
+}
+
+type redditPreview struct {
+	Images []struct {
+		Source redditImageSource `json:"source"`
+	} `json:"images"`
+}
+
+type redditMediaMetadata struct {
+	Kind   string `json:"e"`
+	Source struct {
+		URL string `json:"u"`
+	} `json:"s"`
+}
+
+type redditPost struct {
+	ID            string                         `json:"id"`
+	Title         string                         `json:"title"`
+	Selftext      string                         `json:"selftext"`
+	CreatedUTC    float64                        `json:"created_utc"`
+	URL           string                         `json:"url"`
+	PostHint      string                         `json:"post_hint"`
+	Preview       *redditPreview                 `json:"preview"`
+	MediaMetadata map[string]redditMediaMetadata `json:"media_metadata"`
+}
+
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Data redditPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+func (searchFn *SearchFn) Search() (PostsData, error) {
+	body, err := common.FetchURL(searchFn.searchURL(), searchFn.headers(), searchFn.Timeout)
+	if err != nil {
+		return PostsData{}, err
+	}
+
+	listing := redditListing{}
+	err = json.Unmarshal(body, &listing)
+	if err != nil {
+		return PostsData{}, fmt.Errorf("error decoding Reddit listing: %s", err.Error())
+	}
+
+	posts := []redditPost{}
+	for _, child := range listing.Data.Children {
+		posts = append(posts, child.Data)
+	}
+
+	return searchFn.collectPostsData(posts), nil
+}
+
+func (searchFn *SearchFn) SearchHandler(writer http.ResponseWriter, request *http.Request) {
+	searchFn.InitCommonQueryParams(request)
+	log.Printf("RedditFn.Search: q=\"%s\", c=\"%d\", o=\"%s\"", searchFn.SearchString, searchFn.Count, searchFn.Output)
+
+	postsData, err := searchFn.Search()
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, &postsData, searchFn.Output, postsData.ToText)
+}
+
+// Private SearchFn
+
+func (searchFn *SearchFn) searchURL() string {
+	redditURL := strings.TrimSuffix(searchFn.RedditURL, "/")
+
+	params := url.Values{}
+	params.Set("limit", fmt.Sprintf("%d", searchFn.Count))
+	params.Set("raw_json", "1")
+
+	if strings.HasPrefix(searchFn.SearchString, subredditPrefix) {
+		subreddit := strings.TrimPrefix(searchFn.SearchString, subredditPrefix)
+		return fmt.Sprintf("%s/r/%s/%s.json?%s", redditURL, url.PathEscape(subreddit), searchFn.Listing, params.Encode())
+	}
+
+	params.Set("q", searchFn.SearchString)
+	params.Set("sort", searchFn.Listing)
+	if searchFn.Subreddit != "" {
+		params.Set("restrict_sr", "1")
+		return fmt.Sprintf("%s/r/%s/search.json?%s", redditURL, url.PathEscape(searchFn.Subreddit), params.Encode())
+	}
+
+	return fmt.Sprintf("%s/search.json?%s", redditURL, params.Encode())
+}
+
+func (searchFn *SearchFn) headers() map[string]string {
+	return map[string]string{
+		"Accept":     "application/json",
+		"User-Agent": searchFn.UserAgent,
+	}
+}
+
+func (searchFn *SearchFn) collectPostsData(posts []redditPost) PostsData {
+	postsData := PostsData{}
+	for _, post := range posts {
+		text := post.Title
+		if post.Selftext != "" {
+			text = fmt.Sprintf("%s\n%s", post.Title, post.Selftext)
+		}
+
+		postData := PostData{
+			ID:        post.ID,
+			Text:      text,
+			ImageURLs: collectImageURLs(post),
+		}
+		if post.CreatedUTC > 0 {
+			postData.CreatedAt = time.Unix(int64(post.CreatedUTC), 0).UTC().Format(time.RFC3339)
+		}
+		postsData = append(postsData, postData)
+	}
+	return postsData
+}
+
+// Private functions
+
+func collectImageURLs(post redditPost) []string {
+	imageURLs := []string{}
+	if post.PostHint == "image" && post.URL != "" {
+		return append(imageURLs, html.UnescapeString(post.URL))
+	}
+
+	mediaIDs := []string{}
+	for mediaID := range post.MediaMetadata {
+		mediaIDs = append(mediaIDs, mediaID)
+	}
+	sort.Strings(mediaIDs)
+
+	for _, mediaID := range mediaIDs {
+		metadata := post.MediaMetadata[mediaID]
+		if metadata.Kind == "Image" && metadata.Source.URL != "" {
+			imageURLs = append(imageURLs, html.UnescapeString(metadata.Source.URL))
+		}
+	}
+
+	if len(imageURLs) == 0 && post.Preview != nil {
+		for _, image := range post.Preview.Images {
+			if image.Source.URL != "" {
+				imageURLs = append(imageURLs, html.UnescapeString(image.Source.URL))
+			}
+		}
+	}
+
+	return imageURLs
+}
+
+// Private PostsData
+
+func (post PostData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("\n👽 %s\n", post.Text))
+	if len(post.ImageURLs) > 0 {
+		for _, imageUrl := range post.ImageURLs {
+			sb.WriteString(fmt.Sprintf("- 📸 %s\n", imageUrl))
+		}
+	}
+	return sb.String()
+}
+
+func (posts PostsData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, postData := range posts {
+		sb.WriteString(postData.ToText(postData))
+		sb.WriteString("------\n")
+	}
+	return sb.String()
+}
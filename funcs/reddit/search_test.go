@@ -0,0 +1,112 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reddit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"gotest.tools/assert"
+)
+
+func TestSearchSubreddit(t *testing.T) {
+	var request *http.Request
+	server := newFixtureServer(t, "testdata/listing.json", &request)
+	defer server.Close()
+
+	searchFn := newTestSearchFn(server.URL, "r/nba")
+
+	postsData, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, request.URL.Path, "/r/nba/new.json")
+	assert.Equal(t, request.URL.Query().Get("limit"), "10")
+	assert.Equal(t, request.Header.Get("User-Agent"), "knfun-test")
+
+	assert.Equal(t, len(postsData), 4)
+	assert.Equal(t, postsData[0].Text, "Dunk of the year")
+	assert.DeepEqual(t, postsData[0].ImageURLs, []string{"https://i.redd.it/dunk.jpg"})
+	assert.Equal(t, postsData[0].ID, "dunk1")
+	assert.Equal(t, postsData[0].CreatedAt, "2019-11-21T19:00:00Z")
+	assert.Equal(t, postsData[1].CreatedAt, "")
+	assert.Equal(t, postsData[1].Text, "Gallery from last night\nAll the best shots")
+	assert.DeepEqual(t, postsData[1].ImageURLs, []string{
+		"https://preview.redd.it/a.jpg?width=640&format=pjpg",
+		"https://preview.redd.it/b.jpg?width=640&format=pjpg",
+	})
+	assert.DeepEqual(t, postsData[2].ImageURLs, []string{"https://external-preview.redd.it/p.jpg?auto=webp&s=1"})
+	assert.Equal(t, len(postsData[3].ImageURLs), 0)
+}
+
+func TestSearchQuery(t *testing.T) {
+	var request *http.Request
+	server := newFixtureServer(t, "testdata/listing.json", &request)
+	defer server.Close()
+
+	searchFn := newTestSearchFn(server.URL, "#NBA finals")
+	_, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, request.URL.Path, "/search.json")
+	assert.Equal(t, request.URL.Query().Get("q"), "#NBA finals")
+	assert.Equal(t, request.URL.Query().Get("sort"), "new")
+
+	searchFn.Subreddit = "nba"
+	_, err = searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, request.URL.Path, "/r/nba/search.json")
+	assert.Equal(t, request.URL.Query().Get("restrict_sr"), "1")
+}
+
+func TestSearchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	searchFn := newTestSearchFn(server.URL, "r/nba")
+	searchFn.Timeout = 1
+
+	_, err := searchFn.Search()
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+// Private
+
+func newTestSearchFn(redditURL string, searchString string) *SearchFn {
+	return &SearchFn{
+		CommonFn:  common.CommonFn{SearchString: searchString, Count: 10},
+		RedditURL: redditURL,
+		Listing:   "new",
+		UserAgent: "knfun-test",
+	}
+}
+
+func newFixtureServer(t *testing.T, fixture string, request **http.Request) *httptest.Server {
+	body, err := ioutil.ReadFile(fixture)
+	assert.NilError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		*request = r
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(body)
+	}))
+}
@@ -0,0 +1,155 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	searchFn *SearchFn
+)
+
+func NewRSSCmd() *cobra.Command {
+	searchFn = &SearchFn{}
+
+	cobra.OnInitialize(searchFn.InitConfig)
+
+	rssCmd := &cobra.Command{
+		Use:   "rss",
+		Short: "RSS and Atom root function",
+		Long:  `Various functions over RSS and Atom feeds`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			searchFn.initRSSFlags()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(0)
+			}
+		},
+	}
+
+	searchCmd := &cobra.Command{
+		Use:   "search [SEARCH_STRING]",
+		Short: "Search feed items and extract contents",
+		Long: `Fetches the configured RSS or Atom feeds and responds with the content
+and images of the items matching SEARCH_STRING. All items are returned when
+SEARCH_STRING is omitted or '*'`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			searchFn.initRSSFlags()
+			return searchFn.initSearchCmdInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchFn.search(cmd, args)
+		},
+	}
+
+	searchFn.AddCommonCmdFlags(searchCmd)
+	searchFn.addRSSCmdFlags(rssCmd)
+
+	rssCmd.AddCommand(searchCmd)
+
+	return rssCmd
+}
+
+func Execute() error {
+	return NewRSSCmd().Execute()
+}
+
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if searchFn == nil {
+		NewRSSCmd()
+	}
+
+	searchFn.initRSSFlags()
+	return searchFn.handler(), nil
+}
+
+// Private
+
+func (searchFn *SearchFn) search(cmd *cobra.Command, args []string) error {
+	if searchFn.StartServer {
+		return searchFn.ListenAndServe(searchFn.handler())
+	} else if searchFn.Stdin {
+		return searchFn.ProcessLines(os.Stdin, os.Stdout, searchFn.searchLine)
+	} else {
+		itemsData, err := searchFn.Search()
+		if err != nil {
+			return err
+		}
+
+		err = common.PrintOutput(os.Stdout, &itemsData, searchFn.Output, itemsData.ToText)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchLine searches for a line of the standard input as search string
+func (searchFn *SearchFn) searchLine(line string) (string, error) {
+	lineFn := *searchFn
+	lineFn.SearchString = line
+
+	itemsData, err := lineFn.Search()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&itemsData, lineFn.Output, itemsData.ToText)
+}
+
+func (searchFn *SearchFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", searchFn.HealthzHandler)
+	mux.HandleFunc("/", searchFn.SearchHandler)
+	return mux
+}
+
+func (searchFn *SearchFn) addRSSCmdFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSliceVar(&searchFn.FeedURLs, "rss-feed-urls", []string{}, "comma separated list of RSS or Atom feed URLs")
+
+	viper.BindPFlag("rss-feed-urls", cmd.PersistentFlags().Lookup("rss-feed-urls"))
+}
+
+func (searchFn *SearchFn) initSearchCmdInputFlags(args []string) error {
+	if len(args) == 1 {
+		searchFn.SearchString = args[0]
+	}
+
+	if len(searchFn.FeedURLs) == 0 {
+		return fmt.Errorf("you must pass at least one feed URL with --rss-feed-urls")
+	}
+
+	return nil
+}
+
+func (searchFn *SearchFn) initRSSFlags() {
+	if len(searchFn.FeedURLs) == 0 {
+		searchFn.FeedURLs = viper.GetStringSlice("rss-feed-urls")
+	}
+}
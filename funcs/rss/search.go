@@ -0,0 +1,308 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const mediaNamespace = "http://search.yahoo.com/mrss/"
+
+var (
+	imgSrcRegexp    = regexp.MustCompile(`<img[^>]+src=["']([^"']+)["']`)
+	imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+)
+
+type ItemData struct {
+	ID        string   `yaml:"id,omitempty" json:"id,omitempty"`
+	Text      string   `yaml:"text" json:"text"`
+	ImageURLs []string `yaml:"image-urls" json:"image-urls"`
+}
+
+type ItemsData []ItemData
+
+type SearchFn struct {
+	common.CommonFn
+
+	FeedURLs []string
+}
+
+type feedText struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type feedLink struct {
+	Href  string `xml:"href,attr"`
+	Rel   string `xml:"rel,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type feedEnclosure struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type feedMedia struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+type feedMediaGroup struct {
+	Contents   []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []feedMedia `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type feedItem struct {
+	GUID            string           `xml:"guid"`
+	AtomID          string           `xml:"http://www.w3.org/2005/Atom id"`
+	Titles          []feedText       `xml:"title"`
+	Descriptions    []feedText       `xml:"description"`
+	Encoded         string           `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Summary         string           `xml:"http://www.w3.org/2005/Atom summary"`
+	Content         string           `xml:"http://www.w3.org/2005/Atom content"`
+	Links           []feedLink       `xml:"link"`
+	Enclosures      []feedEnclosure  `xml:"enclosure"`
+	MediaContents   []feedMedia      `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []feedMedia      `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroups     []feedMediaGroup `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type feed struct {
+	ChannelItems []feedItem `xml:"channel>item"`
+	Items        []feedItem `xml:"item"`
+	Entries      []feedItem `xml:"entry"`
+}
+
+func (searchFn *SearchFn) Search() (ItemsData, error) {
+	if len(searchFn.FeedURLs) == 0 {
+		return ItemsData{}, fmt.Errorf("you must configure at least one feed URL")
+	}
+
+	itemsData := ItemsData{}
+	for _, feedURL := range searchFn.FeedURLs {
+		items, err := searchFn.fetchFeedItems(feedURL)
+		if err != nil {
+			return ItemsData{}, err
+		}
+
+		for _, item := range items {
+			if searchFn.Count > 0 && len(itemsData) >= searchFn.Count {
+				return itemsData, nil
+			}
+
+			if searchFn.matches(item) {
+				itemsData = append(itemsData, searchFn.collectItemData(item))
+			}
+		}
+	}
+
+	return itemsData, nil
+}
+
+func (searchFn *SearchFn) SearchHandler(writer http.ResponseWriter, request *http.Request) {
+	searchFn.InitCommonQueryParams(request)
+	log.Printf("RSSFn.Search: q=\"%s\", c=\"%d\", o=\"%s\"", searchFn.SearchString, searchFn.Count, searchFn.Output)
+
+	itemsData, err := searchFn.Search()
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, &itemsData, searchFn.Output, itemsData.ToText)
+}
+
+// Private SearchFn
+
+func (searchFn *SearchFn) fetchFeedItems(feedURL string) ([]feedItem, error) {
+	headers := map[string]string{
+		"Accept": "application/rss+xml, application/atom+xml, application/xml, text/xml",
+	}
+
+	body, err := common.FetchURL(feedURL, headers, searchFn.Timeout)
+	if err != nil {
+		return []feedItem{}, err
+	}
+
+	f := feed{}
+	err = xml.Unmarshal(body, &f)
+	if err != nil {
+		return []feedItem{}, fmt.Errorf("error decoding feed '%s': %s", feedURL, err.Error())
+	}
+
+	items := append(f.ChannelItems, f.Items...)
+	return append(items, f.Entries...), nil
+}
+
+func (searchFn *SearchFn) matches(item feedItem) bool {
+	if searchFn.SearchString == "" || searchFn.SearchString == "*" {
+		return true
+	}
+
+	searchString := strings.ToLower(searchFn.SearchString)
+	return strings.Contains(strings.ToLower(item.title()), searchString) ||
+		strings.Contains(strings.ToLower(common.StripHTML(item.body())), searchString)
+}
+
+func (searchFn *SearchFn) collectItemData(item feedItem) ItemData {
+	text := strings.TrimSpace(item.title())
+	if body := common.StripHTML(item.body()); body != "" {
+		text = fmt.Sprintf("%s\n%s", text, body)
+	}
+
+	id := strings.TrimSpace(item.GUID)
+	if id == "" {
+		id = strings.TrimSpace(item.AtomID)
+	}
+
+	return ItemData{
+		ID:        id,
+		Text:      text,
+		ImageURLs: item.imageURLs(),
+	}
+}
+
+// Private feedItem
+
+func (item feedItem) title() string {
+	return feedTextValue(item.Titles)
+}
+
+func (item feedItem) body() string {
+	for _, body := range []string{item.Encoded, item.Content, feedTextValue(item.Descriptions), item.Summary} {
+		if strings.TrimSpace(body) != "" {
+			return body
+		}
+	}
+	return ""
+}
+
+func (item feedItem) imageURLs() []string {
+	imageURLs := []string{}
+	addImageURL := func(imageURL string) {
+		imageURL = strings.TrimSpace(imageURL)
+		if imageURL == "" {
+			return
+		}
+		for _, existingURL := range imageURLs {
+			if existingURL == imageURL {
+				return
+			}
+		}
+		imageURLs = append(imageURLs, imageURL)
+	}
+
+	for _, enclosure := range item.Enclosures {
+		if isImage(enclosure.URL, enclosure.Type, "") {
+			addImageURL(enclosure.URL)
+		}
+	}
+
+	for _, link := range item.Links {
+		if link.Rel == "enclosure" && isImage(link.Href, link.Type, "") {
+			addImageURL(link.Href)
+		}
+	}
+
+	medias := append([]feedMedia{}, item.MediaContents...)
+	for _, group := range item.MediaGroups {
+		medias = append(medias, group.Contents...)
+	}
+	for _, media := range medias {
+		if isImage(media.URL, media.Type, media.Medium) {
+			addImageURL(media.URL)
+		}
+	}
+
+	if len(imageURLs) == 0 {
+		thumbnails := append([]feedMedia{}, item.MediaThumbnails...)
+		for _, group := range item.MediaGroups {
+			thumbnails = append(thumbnails, group.Thumbnails...)
+		}
+		for _, thumbnail := range thumbnails {
+			addImageURL(thumbnail.URL)
+		}
+	}
+
+	for _, match := range imgSrcRegexp.FindAllStringSubmatch(item.body(), -1) {
+		addImageURL(match[1])
+	}
+
+	return imageURLs
+}
+
+// Private functions
+
+func feedTextValue(texts []feedText) string {
+	for _, text := range texts {
+		if text.XMLName.Space != mediaNamespace {
+			return text.Value
+		}
+	}
+	return ""
+}
+
+func isImage(imageURL string, mimeType string, medium string) bool {
+	if medium != "" {
+		return medium == "image"
+	}
+
+	if mimeType != "" {
+		return strings.HasPrefix(mimeType, "image/")
+	}
+
+	extension := strings.ToLower(path.Ext(strings.SplitN(imageURL, "?", 2)[0]))
+	for _, imageExtension := range imageExtensions {
+		if extension == imageExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// Private ItemsData
+
+func (item ItemData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("\n📰 %s\n", item.Text))
+	if len(item.ImageURLs) > 0 {
+		for _, imageUrl := range item.ImageURLs {
+			sb.WriteString(fmt.Sprintf("- 📸 %s\n", imageUrl))
+		}
+	}
+	return sb.String()
+}
+
+func (items ItemsData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, itemData := range items {
+		sb.WriteString(itemData.ToText(itemData))
+		sb.WriteString("------\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"gotest.tools/assert"
+)
+
+func TestSearchRSS(t *testing.T) {
+	server := newFixtureServer()
+	defer server.Close()
+
+	searchFn := &SearchFn{FeedURLs: []string{server.URL + "/rss.xml"}}
+
+	itemsData, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(itemsData), 3)
+	assert.Equal(t, itemsData[0].Text, "Knative 1.0 released\nServerless on Kubernetes")
+	assert.DeepEqual(t, itemsData[0].ImageURLs, []string{"https://news.example/knative.jpg"})
+	assert.Equal(t, itemsData[0].ID, "knative-1.0")
+	assert.Equal(t, itemsData[1].ID, "")
+	assert.Equal(t, itemsData[1].Text, "Photo gallery\nPictures from the game")
+	assert.DeepEqual(t, itemsData[1].ImageURLs, []string{"https://news.example/g2.jpg", "https://news.example/g1.png"})
+	assert.DeepEqual(t, itemsData[2].ImageURLs, []string{"https://news.example/inline.gif"})
+}
+
+func TestSearchAtom(t *testing.T) {
+	server := newFixtureServer()
+	defer server.Close()
+
+	searchFn := &SearchFn{FeedURLs: []string{server.URL + "/atom.xml"}}
+
+	itemsData, err := searchFn.Search()
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(itemsData), 2)
+	assert.Equal(t, itemsData[0].Text, "Scaling to zero\nHow Knative scales to zero")
+	assert.DeepEqual(t, itemsData[0].ImageURLs, []string{"https://blog.example/zero.png"})
+	assert.Equal(t, itemsData[0].ID, "urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6")
+	assert.Equal(t, itemsData[1].Text, "Thumbnail only\nThumbnails are used as a fallback")
+	assert.DeepEqual(t, itemsData[1].ImageURLs, []string{"https://blog.example/thumb.jpg"})
+}
+
+func TestSearchFilterAndCount(t *testing.T) {
+	server := newFixtureServer()
+	defer server.Close()
+
+	searchFn := &SearchFn{
+		CommonFn: common.CommonFn{SearchString: "KNATIVE"},
+		FeedURLs: []string{server.URL + "/rss.xml", server.URL + "/atom.xml"},
+	}
+
+	itemsData, err := searchFn.Search()
+	assert.NilError(t, err)
+	assert.Equal(t, len(itemsData), 2)
+	assert.Equal(t, itemsData[1].Text, "Scaling to zero\nHow Knative scales to zero")
+
+	searchFn.Count = 1
+	itemsData, err = searchFn.Search()
+	assert.NilError(t, err)
+	assert.Equal(t, len(itemsData), 1)
+}
+
+func TestSearchStdin(t *testing.T) {
+	server := newFixtureServer()
+	defer server.Close()
+
+	searchFn := &SearchFn{
+		CommonFn: common.CommonFn{Output: "ndjson", Concurrency: 2},
+		FeedURLs: []string{server.URL + "/rss.xml"},
+	}
+
+	writer := bytes.NewBufferString("")
+	err := searchFn.ProcessLines(strings.NewReader("knative\ngame\n"), writer, searchFn.searchLine)
+	assert.NilError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Assert(t, strings.Contains(lines[0], `"id":"knative-1.0"`))
+	assert.Assert(t, strings.Contains(lines[1], `"image-urls":["https://news.example/g2.jpg","https://news.example/g1.png"]`))
+	assert.Equal(t, searchFn.SearchString, "")
+}
+
+func TestSearchHandler(t *testing.T) {
+	server := newFixtureServer()
+	defer server.Close()
+
+	searchFn := &SearchFn{FeedURLs: []string{server.URL + "/rss.xml"}}
+
+	recorder := httptest.NewRecorder()
+	searchFn.SearchHandler(recorder, httptest.NewRequest("GET", "/?q=knative&o=jsonpath%3D%7B%5B*%5D.id%7D", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "knative-1.0\n")
+
+	recorder = httptest.NewRecorder()
+	searchFn.SearchHandler(recorder, httptest.NewRequest("GET", "/?q=knative&o=go-template-file%3D%2Fetc%2Fpasswd", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Assert(t, !strings.Contains(recorder.Body.String(), "root:"))
+}
+
+func TestSearchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	searchFn := &SearchFn{FeedURLs: []string{server.URL + "/rss.xml"}}
+	searchFn.Timeout = 1
+
+	_, err := searchFn.Search()
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+// Private
+
+func newFixtureServer() *httptest.Server {
+	return httptest.NewServer(http.FileServer(http.Dir("testdata")))
+}
@@ -0,0 +1,509 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	jsonWebhook       = "json"
+	slackWebhook      = "slack"
+	cloudEventWebhook = "cloudevent"
+
+	defaultAlertDedup      = 24 * time.Hour
+	defaultAlertRatePeriod = time.Minute
+	defaultAlertTimeout    = 10
+
+	alertCloudEventType   = "dev.knfun.summary.alert"
+	alertCloudEventSource = "knfun/summary-fn"
+)
+
+// AlertRule fires its webhook for the tweets with an image label matching the
+// rule's labels or label pattern with at least the min score. The sources and
+// text pattern further restrict the matching tweets when set
+type AlertRule struct {
+	Name         string        `yaml:"name" json:"name" mapstructure:"name"`
+	Labels       []string      `yaml:"labels,omitempty" json:"labels,omitempty" mapstructure:"labels"`
+	LabelPattern string        `yaml:"label-pattern,omitempty" json:"label-pattern,omitempty" mapstructure:"label-pattern"`
+	MinScore     float64       `yaml:"min-score,omitempty" json:"min-score,omitempty" mapstructure:"min-score"`
+	Sources      []string      `yaml:"sources,omitempty" json:"sources,omitempty" mapstructure:"sources"`
+	TextPattern  string        `yaml:"text-pattern,omitempty" json:"text-pattern,omitempty" mapstructure:"text-pattern"`
+	Webhook      AlertWebhook  `yaml:"webhook" json:"webhook" mapstructure:"webhook"`
+	Dedup        time.Duration `yaml:"dedup,omitempty" json:"dedup,omitempty" mapstructure:"dedup"`
+	RateLimit    int           `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty" mapstructure:"rate-limit"`
+	RatePeriod   time.Duration `yaml:"rate-period,omitempty" json:"rate-period,omitempty" mapstructure:"rate-period"`
+}
+
+type AlertWebhook struct {
+	URL     string            `yaml:"url" json:"url" mapstructure:"url"`
+	Format  string            `yaml:"format,omitempty" json:"format,omitempty" mapstructure:"format"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" mapstructure:"headers"`
+}
+
+type AlertMatch struct {
+	ImageURL string  `yaml:"image-url" json:"image-url"`
+	Label    string  `yaml:"label" json:"label"`
+	Score    float64 `yaml:"score" json:"score"`
+}
+
+type Alert struct {
+	Rule         string          `yaml:"rule" json:"rule"`
+	SearchString string          `yaml:"search-string" json:"search-string"`
+	FiredAt      time.Time       `yaml:"fired-at" json:"fired-at"`
+	Tweet        ClassifiedTweet `yaml:"tweet" json:"tweet"`
+	Matches      []AlertMatch    `yaml:"matches" json:"matches"`
+}
+
+// AlertEngine evaluates the rules against the classified tweets. A rule fires
+// at most once per tweet within its dedup period, and at most its rate limit
+// times per rate period, counting only the alerts its webhook received
+type AlertEngine struct {
+	Timeout int
+
+	rules   []*alertRule
+	sending sync.WaitGroup
+}
+
+type alertRule struct {
+	AlertRule
+
+	labels       map[string]bool
+	sources      map[string]bool
+	labelPattern *regexp.Regexp
+	textPattern  *regexp.Regexp
+
+	mutex   sync.Mutex
+	alerted map[string]time.Time
+	fired   []time.Time
+	pending map[string]bool
+}
+
+type alertCloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	Type            string    `json:"type"`
+	Source          string    `json:"source"`
+	ID              string    `json:"id"`
+	Time            time.Time `json:"time"`
+	Subject         string    `json:"subject"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Alert     `json:"data"`
+}
+
+// LoadAlertRules reads the `rules` of a YAML (or JSON) rules file
+func LoadAlertRules(path string) ([]AlertRule, error) {
+	rulesConfig := viper.New()
+	rulesConfig.SetConfigFile(path)
+
+	err := rulesConfig.ReadInConfig()
+	if err != nil {
+		return []AlertRule{}, err
+	}
+
+	rules := []AlertRule{}
+	err = rulesConfig.UnmarshalKey("rules", &rules)
+	if err != nil {
+		return []AlertRule{}, err
+	}
+	return rules, nil
+}
+
+// NewAlertEngine compiles the rules, whose webhooks time out after the timeout
+// in seconds, or defaultAlertTimeout when not set
+func NewAlertEngine(rules []AlertRule, timeout int) (*AlertEngine, error) {
+	if timeout <= 0 {
+		timeout = defaultAlertTimeout
+	}
+
+	engine := &AlertEngine{Timeout: timeout}
+	for _, rule := range rules {
+		compiledRule, err := compileAlertRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule '%s': %s", rule.Name, err.Error())
+		}
+		engine.rules = append(engine.rules, compiledRule)
+	}
+	return engine, nil
+}
+
+// Evaluate returns the alerts fired by the classified tweets of a search and
+// reserves them for the dedup and rate limits of their rules until Send
+// delivers them, so the returned alerts must be passed to Send
+func (engine *AlertEngine) Evaluate(searchString string, classifiedTweets []ClassifiedTweet, now time.Time) []Alert {
+	alerts := []Alert{}
+	for _, rule := range engine.rules {
+		for _, cTweet := range classifiedTweets {
+			matches := rule.matches(cTweet)
+			if len(matches) == 0 {
+				continue
+			}
+
+			if !rule.reserve(tweetKey(cTweet), now) {
+				continue
+			}
+
+			alerts = append(alerts, Alert{
+				Rule:         rule.Name,
+				SearchString: searchString,
+				FiredAt:      now.UTC(),
+				Tweet:        cTweet,
+				Matches:      matches,
+			})
+		}
+	}
+	return alerts
+}
+
+// Send posts each alert to the webhook of its rule and returns the errors of
+// the alerts that could not be delivered. Only the delivered alerts count for
+// the dedup and rate limits, the others fire again on the next evaluation
+func (engine *AlertEngine) Send(alerts []Alert) []error {
+	errs := []error{}
+	for _, alert := range alerts {
+		rule := engine.rule(alert.Rule)
+		if rule == nil {
+			errs = append(errs, fmt.Errorf("no alert rule '%s'", alert.Rule))
+			continue
+		}
+
+		err := sendAlert(rule.Webhook, alert, engine.Timeout)
+		rule.record(tweetKey(alert.Tweet), alert.FiredAt, err == nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sending alert '%s' to '%s': %s", alert.Rule, rule.Webhook.URL, err.Error()))
+		}
+	}
+	return errs
+}
+
+// SendAsync sends the alerts in the background and passes their errors to done
+func (engine *AlertEngine) SendAsync(alerts []Alert, done func(errs []error)) {
+	engine.sending.Add(1)
+	go func() {
+		defer engine.sending.Done()
+		done(engine.Send(alerts))
+	}()
+}
+
+// Wait returns once the alerts sent in the background are sent
+func (engine *AlertEngine) Wait() {
+	engine.sending.Wait()
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) loadAlertRules() error {
+	if summaryFn.alerts != nil {
+		return nil
+	}
+
+	rules := []AlertRule{}
+	if summaryFn.AlertRulesFile != "" {
+		var err error
+		rules, err = LoadAlertRules(summaryFn.AlertRulesFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := viper.UnmarshalKey("alerts", &rules)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	alerts, err := NewAlertEngine(rules, summaryFn.Timeout)
+	if err != nil {
+		return err
+	}
+
+	summaryFn.alerts = alerts
+	return nil
+}
+
+// summarized saves the summary and fires its alerts
+func (summaryFn *SummaryFn) summarized(summaryData SummaryData) {
+	summaryFn.saveSummary(summaryData)
+	summaryFn.fireAlerts(summaryData)
+}
+
+// fireAlerts sends the alerts in the background so that slow webhooks do not
+// delay the summary, and only logs the webhook errors since the alerts are
+// optional
+func (summaryFn *SummaryFn) fireAlerts(summaryData SummaryData) {
+	if summaryFn.alerts == nil {
+		return
+	}
+
+	searchString := summaryData.Query.SearchString
+	alerts := summaryFn.alerts.Evaluate(searchString, summaryData.ClassifiedTweets, time.Now())
+	if len(alerts) == 0 {
+		return
+	}
+
+	summaryFn.alerts.SendAsync(alerts, func(errs []error) {
+		for _, err := range errs {
+			log.Printf("Error firing alert: %s\n", err.Error())
+		}
+		log.Printf("Fired %d alerts for search '%s'", len(alerts)-len(errs), searchString)
+	})
+}
+
+// waitAlerts waits for the alerts still being sent, e.g., before exiting
+func (summaryFn *SummaryFn) waitAlerts() {
+	if summaryFn.alerts == nil {
+		return
+	}
+	summaryFn.alerts.Wait()
+}
+
+// Private AlertEngine
+
+func (engine *AlertEngine) rule(name string) *alertRule {
+	for _, rule := range engine.rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Private alertRule
+
+func (rule *alertRule) matches(cTweet ClassifiedTweet) []AlertMatch {
+	if len(rule.sources) > 0 && !rule.sources[strings.ToLower(cTweet.Source)] {
+		return []AlertMatch{}
+	}
+
+	if rule.textPattern != nil && !rule.textPattern.MatchString(cTweet.Text) {
+		return []AlertMatch{}
+	}
+
+	matches := []AlertMatch{}
+	for _, cImage := range cTweet.ClassifiedImages {
+		for _, label := range cImage.Labels {
+			if float64(label.Score) < rule.MinScore || !rule.matchesLabel(label.Name) {
+				continue
+			}
+			matches = append(matches, AlertMatch{ImageURL: cImage.ImageURL, Label: label.Name, Score: float64(label.Score)})
+		}
+	}
+	return matches
+}
+
+// matchesLabel matches any label when the rule has neither labels nor pattern
+func (rule *alertRule) matchesLabel(name string) bool {
+	if len(rule.labels) == 0 && rule.labelPattern == nil {
+		return true
+	}
+	return rule.labels[strings.ToLower(strings.TrimSpace(name))] ||
+		(rule.labelPattern != nil && rule.labelPattern.MatchString(name))
+}
+
+// reserve holds the alert of the tweet until it is recorded, unless it already
+// fired within the dedup period, is being sent, or the rule reached its rate
+// limit, in which case the tweet can fire again the next time it is summarized
+func (rule *alertRule) reserve(key string, now time.Time) bool {
+	rule.mutex.Lock()
+	defer rule.mutex.Unlock()
+
+	for alertedKey, alertedAt := range rule.alerted {
+		if now.Sub(alertedAt) >= rule.Dedup {
+			delete(rule.alerted, alertedKey)
+		}
+	}
+	if _, ok := rule.alerted[key]; ok || rule.pending[key] {
+		return false
+	}
+
+	recentlyFired := []time.Time{}
+	for _, firedAt := range rule.fired {
+		if now.Sub(firedAt) < rule.RatePeriod {
+			recentlyFired = append(recentlyFired, firedAt)
+		}
+	}
+	rule.fired = recentlyFired
+
+	if rule.RateLimit > 0 && len(rule.fired)+len(rule.pending) >= rule.RateLimit {
+		log.Printf("Alert rule '%s' reached its rate limit of %d alerts per %s, skipping '%s'\n", rule.Name, rule.RateLimit, rule.RatePeriod, key)
+		return false
+	}
+
+	rule.pending[key] = true
+	return true
+}
+
+// record releases the reserved alert of the tweet, and counts it for the dedup
+// and rate limits when its webhook received it
+func (rule *alertRule) record(key string, firedAt time.Time, sent bool) {
+	rule.mutex.Lock()
+	defer rule.mutex.Unlock()
+
+	delete(rule.pending, key)
+	if sent {
+		rule.alerted[key] = firedAt
+		rule.fired = append(rule.fired, firedAt)
+	}
+}
+
+// Private functions
+
+func compileAlertRule(rule AlertRule) (*alertRule, error) {
+	if rule.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if rule.Webhook.URL == "" {
+		return nil, errors.New("missing webhook url")
+	}
+
+	switch rule.Webhook.Format {
+	case "":
+		rule.Webhook.Format = jsonWebhook
+	case jsonWebhook, slackWebhook, cloudEventWebhook:
+	default:
+		return nil, fmt.Errorf("unknown webhook format '%s', expected json, slack, or cloudevent", rule.Webhook.Format)
+	}
+
+	if rule.Dedup <= 0 {
+		rule.Dedup = defaultAlertDedup
+	}
+	if rule.RatePeriod <= 0 {
+		rule.RatePeriod = defaultAlertRatePeriod
+	}
+
+	compiledRule := &alertRule{
+		AlertRule: rule,
+		labels:    map[string]bool{},
+		sources:   map[string]bool{},
+		alerted:   map[string]time.Time{},
+		pending:   map[string]bool{},
+	}
+
+	for _, label := range rule.Labels {
+		compiledRule.labels[strings.ToLower(strings.TrimSpace(label))] = true
+	}
+	for _, source := range rule.Sources {
+		compiledRule.sources[strings.ToLower(strings.TrimSpace(source))] = true
+	}
+
+	var err error
+	if rule.LabelPattern != "" {
+		compiledRule.labelPattern, err = regexp.Compile(rule.LabelPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rule.TextPattern != "" {
+		compiledRule.textPattern, err = regexp.Compile(rule.TextPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return compiledRule, nil
+}
+
+func sendAlert(webhook AlertWebhook, alert Alert, timeout int) error {
+	contentType, body, err := alertPayload(webhook.Format, alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	webhookClient := http.Client{
+		Timeout: time.Second * time.Duration(timeout),
+	}
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return nil
+}
+
+func alertPayload(format string, alert Alert) (string, []byte, error) {
+	switch format {
+	case slackWebhook:
+		body, err := json.Marshal(map[string]string{"text": alert.ToText()})
+		return "application/json", body, err
+	case cloudEventWebhook:
+		body, err := json.Marshal(alertCloudEvent{
+			SpecVersion:     "1.0",
+			Type:            alertCloudEventType,
+			Source:          alertCloudEventSource,
+			ID:              alert.id(),
+			Time:            alert.FiredAt,
+			Subject:         alert.Rule,
+			DataContentType: "application/json",
+			Data:            alert,
+		})
+		return "application/cloudevents+json", body, err
+	}
+
+	body, err := json.Marshal(alert)
+	return "application/json", body, err
+}
+
+// Alert
+
+func (alert Alert) ToText() string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("🚨 `%s` alert for search `%s`", alert.Rule, alert.SearchString))
+	if alert.Tweet.Source != "" {
+		sb.WriteString(fmt.Sprintf(" from `%s`", alert.Tweet.Source))
+	}
+	sb.WriteString(fmt.Sprintf("\n> %s\n", strings.ReplaceAll(alert.Tweet.Text, "\n", "\n> ")))
+	for _, match := range alert.Matches {
+		sb.WriteString(fmt.Sprintf("- 📸 %s is a `%s` with `%1.3f` confidence\n", match.ImageURL, match.Label, match.Score))
+	}
+	return sb.String()
+}
+
+// Private Alert
+
+func (alert Alert) id() string {
+	hash := sha1.New()
+	hash.Write([]byte(alert.Rule + "\n" + tweetKey(alert.Tweet) + "\n" + alert.FiredAt.Format(time.RFC3339Nano)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
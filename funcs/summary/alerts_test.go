@@ -0,0 +1,265 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestAlertRuleMatches(t *testing.T) {
+	cTweet := ClassifiedTweet{
+		ID:     "1",
+		Text:   "BREAKING: fire downtown",
+		Source: "twitter",
+		ClassifiedImages: []ClassifiedImage{
+			ClassifiedImage{ImageURL: "http://img/1.jpg", Labels: []Label{Label{Name: "Fire", Score: 0.9}, Label{Name: "smoke", Score: 0.4}}},
+			ClassifiedImage{ImageURL: "http://img/2.jpg", Labels: []Label{Label{Name: "wildfire", Score: 0.7}}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		rule    AlertRule
+		matches int
+	}{
+		{"labels", AlertRule{Labels: []string{"fire"}}, 1},
+		{"label pattern", AlertRule{LabelPattern: "(?i)fire"}, 2},
+		{"min score", AlertRule{LabelPattern: "(?i)fire", MinScore: 0.8}, 1},
+		{"any label", AlertRule{MinScore: 0.5}, 2},
+		{"source", AlertRule{Labels: []string{"fire"}, Sources: []string{"mastodon"}}, 0},
+		{"text pattern", AlertRule{Labels: []string{"smoke"}, TextPattern: "^BREAKING"}, 1},
+		{"text mismatch", AlertRule{Labels: []string{"smoke"}, TextPattern: "^breaking"}, 0},
+	} {
+		tc.rule.Name = tc.name
+		tc.rule.Webhook.URL = "http://localhost"
+		rule, err := compileAlertRule(tc.rule)
+		assert.NilError(t, err)
+		assert.Equal(t, len(rule.matches(cTweet)), tc.matches, tc.name)
+	}
+}
+
+func TestNewAlertEngineInvalidRules(t *testing.T) {
+	for _, rule := range []AlertRule{
+		AlertRule{Webhook: AlertWebhook{URL: "http://localhost"}},
+		AlertRule{Name: "no-webhook"},
+		AlertRule{Name: "format", Webhook: AlertWebhook{URL: "http://localhost", Format: "xml"}},
+		AlertRule{Name: "pattern", LabelPattern: "(", Webhook: AlertWebhook{URL: "http://localhost"}},
+	} {
+		_, err := NewAlertEngine([]AlertRule{rule}, 5)
+		assert.Assert(t, err != nil, rule.Name)
+	}
+}
+
+func TestAlertEngineDedupAndRateLimit(t *testing.T) {
+	receiver := newAlertReceiver()
+	defer receiver.Close()
+
+	engine, err := NewAlertEngine([]AlertRule{AlertRule{
+		Name:       "balls",
+		Labels:     []string{"ball"},
+		Webhook:    AlertWebhook{URL: receiver.URL},
+		Dedup:      time.Hour,
+		RateLimit:  1,
+		RatePeriod: time.Minute,
+	}}, 5)
+	assert.NilError(t, err)
+
+	now := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	tweets := []ClassifiedTweet{newBallTweet("1"), newBallTweet("2")}
+
+	alerts := engine.Evaluate("NBA", tweets, now)
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0].Tweet.ID, "1")
+	assert.Equal(t, alerts[0].SearchString, "NBA")
+
+	// the alert being sent is neither evaluated again nor over the rate limit
+	assert.Equal(t, len(engine.Evaluate("NBA", tweets, now)), 0)
+	assert.Equal(t, len(engine.Send(alerts)), 0)
+
+	alerts = engine.Evaluate("NBA", tweets, now.Add(time.Minute))
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0].Tweet.ID, "2")
+	assert.Equal(t, len(engine.Send(alerts)), 0)
+
+	alerts = engine.Evaluate("NBA", tweets, now.Add(2*time.Minute))
+	assert.Equal(t, len(alerts), 0)
+
+	alerts = engine.Evaluate("NBA", tweets, now.Add(time.Hour))
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0].Tweet.ID, "1")
+	assert.Equal(t, len(engine.Send(alerts)), 0)
+	assert.Equal(t, len(receiver.received()), 3)
+}
+
+func TestAlertEngineSend(t *testing.T) {
+	receiver := newAlertReceiver()
+	defer receiver.Close()
+
+	engine, err := NewAlertEngine([]AlertRule{
+		AlertRule{Name: "json", Webhook: AlertWebhook{URL: receiver.URL, Headers: map[string]string{"Authorization": "Bearer token"}}},
+		AlertRule{Name: "slack", Webhook: AlertWebhook{URL: receiver.URL, Format: slackWebhook}},
+		AlertRule{Name: "cloudevent", Webhook: AlertWebhook{URL: receiver.URL, Format: cloudEventWebhook}},
+	}, 5)
+	assert.NilError(t, err)
+
+	alerts := engine.Evaluate("NBA", []ClassifiedTweet{newBallTweet("1")}, time.Now())
+	assert.Equal(t, len(alerts), 3)
+	assert.Equal(t, len(engine.Send(alerts)), 0)
+
+	requests := receiver.received()
+	assert.Equal(t, len(requests), 3)
+
+	assert.Equal(t, requests[0].header.Get("Authorization"), "Bearer token")
+	alert := Alert{}
+	assert.NilError(t, json.Unmarshal(requests[0].body, &alert))
+	assert.Equal(t, alert.Rule, "json")
+	assert.DeepEqual(t, alert.Matches, []AlertMatch{AlertMatch{ImageURL: "http://img/1.jpg", Label: "ball", Score: float64(float32(0.9))}})
+
+	slackMessage := map[string]string{}
+	assert.NilError(t, json.Unmarshal(requests[1].body, &slackMessage))
+	assert.Assert(t, strings.Contains(slackMessage["text"], "`slack` alert for search `NBA`"))
+	assert.Assert(t, strings.Contains(slackMessage["text"], "is a `ball`"))
+
+	assert.Equal(t, requests[2].header.Get("Content-Type"), "application/cloudevents+json")
+	cloudEvent := alertCloudEvent{}
+	assert.NilError(t, json.Unmarshal(requests[2].body, &cloudEvent))
+	assert.Equal(t, cloudEvent.SpecVersion, "1.0")
+	assert.Equal(t, cloudEvent.Type, alertCloudEventType)
+	assert.Equal(t, cloudEvent.Data.Tweet.ID, "1")
+	assert.Assert(t, cloudEvent.ID != "")
+}
+
+func TestAlertEngineSendError(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "nope", http.StatusForbidden)
+	}))
+	defer receiver.Close()
+
+	engine, err := NewAlertEngine([]AlertRule{AlertRule{Name: "forbidden", Webhook: AlertWebhook{URL: receiver.URL}}}, 5)
+	assert.NilError(t, err)
+
+	errs := engine.Send(engine.Evaluate("NBA", []ClassifiedTweet{newBallTweet("1")}, time.Now()))
+	assert.Equal(t, len(errs), 1)
+	assert.Assert(t, strings.Contains(errs[0].Error(), "403"))
+
+	// the undelivered alert fires again
+	alerts := engine.Evaluate("NBA", []ClassifiedTweet{newBallTweet("1")}, time.Now())
+	assert.Equal(t, len(alerts), 1)
+}
+
+func TestNewAlertEngineDefaultTimeout(t *testing.T) {
+	engine, err := NewAlertEngine([]AlertRule{}, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, engine.Timeout, defaultAlertTimeout)
+}
+
+func TestLoadAlertRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summary-alerts")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alerts.yaml")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(`
+rules:
+  - name: weapons
+    labels: [weapon, gun]
+    min-score: 0.7
+    sources: [twitter]
+    dedup: 1h
+    rate-limit: 10
+    rate-period: 5m
+    webhook:
+      url: http://localhost:9000/hooks
+      format: slack
+`), 0644))
+
+	rules, err := LoadAlertRules(path)
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 1)
+	assert.DeepEqual(t, rules[0].Labels, []string{"weapon", "gun"})
+	assert.Equal(t, rules[0].MinScore, 0.7)
+	assert.Equal(t, rules[0].Dedup, time.Hour)
+	assert.Equal(t, rules[0].RatePeriod, 5*time.Minute)
+	assert.Equal(t, rules[0].Webhook.Format, slackWebhook)
+}
+
+func TestSummaryHandlerFiresAlerts(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	receiver := newAlertReceiver()
+	defer receiver.Close()
+
+	var err error
+	summaryFn.alerts, err = NewAlertEngine([]AlertRule{AlertRule{Name: "balls", Labels: []string{"ball"}, Webhook: AlertWebhook{URL: receiver.URL}}}, 5)
+	assert.NilError(t, err)
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil))
+		assert.Equal(t, recorder.Code, http.StatusOK)
+	}
+
+	summaryFn.waitAlerts()
+	requests := receiver.received()
+	assert.Equal(t, len(requests), 1)
+
+	alert := Alert{}
+	assert.NilError(t, json.Unmarshal(requests[0].body, &alert))
+	assert.Equal(t, alert.Tweet.Text, "t1")
+}
+
+// Private
+
+type receivedAlert struct {
+	header http.Header
+	body   []byte
+}
+
+type alertReceiver struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []receivedAlert
+}
+
+func newAlertReceiver() *alertReceiver {
+	receiver := &alertReceiver{}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+
+		receiver.mutex.Lock()
+		receiver.requests = append(receiver.requests, receivedAlert{header: request.Header, body: body})
+		receiver.mutex.Unlock()
+	}))
+	return receiver
+}
+
+func (receiver *alertReceiver) received() []receivedAlert {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return append([]receivedAlert{}, receiver.requests...)
+}
@@ -0,0 +1,63 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"log"
+
+	"github.com/maximilien/knfun/funcs/client"
+)
+
+// TextAnalysis and its fields are the types of the NLUFn client
+type (
+	TextAnalysis = client.TextAnalysis
+	Sentiment    = client.Sentiment
+	Keyword      = client.Keyword
+	Entity       = client.Entity
+)
+
+// Private SummaryFn
+
+// analyzeTweet returns nil when no NLUFn is configured or when the analysis
+// fails, since the text analysis is optional in the summary
+func (summaryFn *SummaryFn) analyzeTweet(ctx context.Context, tweet Tweet) *TextAnalysis {
+	if summaryFn.NLUFnURL == "" || tweet.Text == "" {
+		return nil
+	}
+
+	textAnalysis, err := analyzeText(ctx, summaryFn.NLUFnURL, tweet.Text, summaryFn.Timeout)
+	if err != nil {
+		log.Printf("Error analyzing tweet text: %s\n", err.Error())
+		return nil
+	}
+	return &textAnalysis
+}
+
+// Private functions
+
+func analyzeText(ctx context.Context, nluFnURL string, text string, timeout int) (TextAnalysis, error) {
+	return client.NewNLUClient(nluFnURL, timeout).Analyze(ctx, text)
+}
+
+func sentimentEmoji(sentiment Sentiment) string {
+	switch sentiment.Label {
+	case "positive":
+		return "🙂"
+	case "negative":
+		return "🙁"
+	}
+	return "😐"
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestAnalyzeTweet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Query().Get("q") == "fail" {
+			http.Error(writer, "boom", http.StatusInternalServerError)
+			return
+		}
+
+		assert.Equal(t, request.URL.Query().Get("q"), "What a game!")
+		assert.Equal(t, request.URL.Query().Get("o"), "json")
+		fmt.Fprint(writer, `{"text": "What a game!", "backend": "lexicon",
+			"sentiment": {"label": "positive", "score": 0.6},
+			"keywords": [{"text": "game", "relevance": 1}],
+			"entities": []}`)
+	}))
+	defer server.Close()
+
+	summaryFn := &SummaryFn{NLUFnURL: server.URL}
+	summaryFn.Timeout = 10
+
+	textAnalysis := summaryFn.analyzeTweet(context.Background(), Tweet{Text: "What a game!"})
+	assert.Assert(t, textAnalysis != nil)
+	assert.DeepEqual(t, textAnalysis.Sentiment, Sentiment{Label: "positive", Score: 0.6})
+	assert.DeepEqual(t, textAnalysis.Keywords, []Keyword{Keyword{Text: "game", Relevance: 1}})
+
+	assert.Assert(t, summaryFn.analyzeTweet(context.Background(), Tweet{Text: "fail"}) == nil)
+
+	summaryFn.NLUFnURL = ""
+	assert.Assert(t, summaryFn.analyzeTweet(context.Background(), Tweet{Text: "What a game!"}) == nil)
+}
@@ -0,0 +1,177 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/maximilien/knfun/funcs/client"
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	defaultClassifierName = "watson"
+
+	watsonClassifier  = "watson"
+	gvisionClassifier = "gvision"
+)
+
+// ClassifierBackend is a named classifier func, e.g., watson-fn, gvision-fn,
+// or a local model with the same API. The type is the API of the func:
+// watson (default) or gvision, and the headers are added to the ones of
+// --classifier-headers
+type ClassifierBackend struct {
+	Name    string            `yaml:"name" json:"name" mapstructure:"name"`
+	Type    string            `yaml:"type,omitempty" json:"type,omitempty" mapstructure:"type"`
+	URL     string            `yaml:"url" json:"url" mapstructure:"url"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" mapstructure:"headers"`
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) classifierBackends() []ClassifierBackend {
+	if len(summaryFn.Classifiers) > 0 {
+		return summaryFn.Classifiers
+	}
+
+	if summaryFn.WatsonFnURL == "" {
+		return []ClassifierBackend{}
+	}
+
+	return []ClassifierBackend{
+		ClassifierBackend{
+			Name: defaultClassifierName,
+			URL:  summaryFn.WatsonFnURL,
+		},
+	}
+}
+
+// classifierBackend returns the named backend, or the --classifier backend, or
+// else the first one, when the name is empty
+func (summaryFn *SummaryFn) classifierBackend(name string) (ClassifierBackend, error) {
+	backends := summaryFn.classifierBackends()
+	if len(backends) == 0 {
+		return ClassifierBackend{}, fmt.Errorf("you must configure at least one classifier or a WatsonFn URL")
+	}
+
+	if name == "" {
+		name = summaryFn.Classifier
+	}
+	if name == "" {
+		return backends[0], nil
+	}
+
+	names := []string{}
+	for _, backend := range backends {
+		if backend.Name == name {
+			return backend, nil
+		}
+		names = append(names, backend.Name)
+	}
+	return ClassifierBackend{}, fmt.Errorf("unknown classifier '%s', expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// classifierQueryParam returns the `classifier` query parameter, or responds
+// with a bad request and false when it is not a configured classifier
+func (summaryFn *SummaryFn) classifierQueryParam(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	classifier := summaryFn.ExtractQueryStringParam(request, []string{"classifier"}, "")
+	if classifier == "" {
+		return "", true
+	}
+
+	_, err := summaryFn.classifierBackend(classifier)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return classifier, true
+}
+
+func (summaryFn *SummaryFn) classifierName(name string) string {
+	backend, err := summaryFn.classifierBackend(name)
+	if err != nil {
+		return name
+	}
+	return backend.Name
+}
+
+func (summaryFn *SummaryFn) classifyImage(ctx context.Context, classifier string, imageURL string, classifyFn common.ClassifyFn) (ClassifiedImage, error) {
+	backend, err := summaryFn.classifierBackend(classifier)
+	if err != nil {
+		return ClassifiedImage{}, err
+	}
+
+	headers := map[string]string{}
+	for name, value := range summaryFn.ClassifierHeaders {
+		headers[name] = value
+	}
+	for name, value := range backend.Headers {
+		headers[name] = value
+	}
+
+	switch classifierType(backend) {
+	case watsonClassifier:
+		watsonClient := client.NewWatsonClient(backend.URL, summaryFn.Timeout)
+		watsonClient.Headers = headers
+		return watsonClient.Classify(ctx, imageURL, classifyFn)
+	case gvisionClassifier:
+		gvisionClient := client.NewGVisionClient(backend.URL, summaryFn.Timeout)
+		gvisionClient.Headers = headers
+		return gvisionClient.DetectLabels(ctx, imageURL, classifyFn)
+	default:
+		return ClassifiedImage{}, fmt.Errorf("unknown type '%s' of classifier '%s', expected watson or gvision", backend.Type, backend.Name)
+	}
+}
+
+func (summaryFn *SummaryFn) watsonClient(watsonFnURL string, timeout int) *client.WatsonClient {
+	watsonClient := client.NewWatsonClient(watsonFnURL, timeout)
+	watsonClient.Headers = summaryFn.ClassifierHeaders
+	return watsonClient
+}
+
+// Private functions
+
+// parseClassifierBackends names the backends of --classifiers, whose type is
+// gvision for the gvision backend and watson otherwise
+func parseClassifierBackends(classifierURLs map[string]string) []ClassifierBackend {
+	names := []string{}
+	for name := range classifierURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	backends := []ClassifierBackend{}
+	for _, name := range names {
+		backends = append(backends, ClassifierBackend{
+			Name: name,
+			URL:  classifierURLs[name],
+		})
+	}
+	return backends
+}
+
+func classifierType(backend ClassifierBackend) string {
+	if backend.Type != "" {
+		return backend.Type
+	}
+	if backend.Name == gvisionClassifier {
+		return gvisionClassifier
+	}
+	return watsonClassifier
+}
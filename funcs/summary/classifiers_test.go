@@ -0,0 +1,73 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestClassifierBackend(t *testing.T) {
+	summaryFn := &SummaryFn{WatsonFnURL: "http://watson"}
+	backend, err := summaryFn.classifierBackend("")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, backend, ClassifierBackend{Name: "watson", URL: "http://watson"})
+
+	summaryFn.Classifiers = parseClassifierBackends(map[string]string{"watson": "http://watson", "gvision": "http://gvision/labels", "local": "http://local"})
+	assert.DeepEqual(t, []string{summaryFn.Classifiers[0].Name, summaryFn.Classifiers[1].Name, summaryFn.Classifiers[2].Name}, []string{"gvision", "local", "watson"})
+	assert.Equal(t, classifierType(summaryFn.Classifiers[0]), gvisionClassifier)
+	assert.Equal(t, classifierType(summaryFn.Classifiers[1]), watsonClassifier)
+
+	backend, err = summaryFn.classifierBackend("")
+	assert.NilError(t, err)
+	assert.Equal(t, backend.Name, "gvision")
+
+	summaryFn.Classifier = "watson"
+	assert.Equal(t, summaryFn.classifierName(""), "watson")
+	assert.Equal(t, summaryFn.classifierName("local"), "local")
+
+	_, err = summaryFn.classifierBackend("aws")
+	assert.Error(t, err, "unknown classifier 'aws', expected one of: gvision, local, watson")
+
+	_, err = (&SummaryFn{}).classifierBackend("")
+	assert.ErrorContains(t, err, "you must configure at least one classifier")
+}
+
+func TestSummaryHandlerClassifier(t *testing.T) {
+	summaryFn, closeServers := newCompareSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&classifier=gvision&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Query.Classifier, "gvision")
+	assert.DeepEqual(t, summaryData.ClassifiedTweets[0].ClassifiedImages[0].Labels, []Label{Label{Name: "Ball", Score: 0.95}, Label{Name: "Sports", Score: 0.8}})
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil))
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Query.Classifier, "watson")
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&classifier=aws&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+}
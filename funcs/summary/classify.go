@@ -0,0 +1,184 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/client"
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	defaultClassifyTimeout  = 10
+	defaultClassifyCacheTTL = 3600
+
+	maxClassifyCacheEntries = 1000
+
+	// maxAnalyzeTextLength is larger than the text of the tweets and posts of
+	// all the sources, in bytes
+	maxAnalyzeTextLength = 10000
+)
+
+// classifyCache keeps the classified images of the `/classify` proxy, keyed
+// by image URL and classify parameters, until they expire. A nil cache caches
+// nothing
+type classifyCache struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]classifyCacheEntry
+}
+
+type classifyCacheEntry struct {
+	classifiedImage ClassifiedImage
+	expiresAt       time.Time
+}
+
+// ClassifyHandler classifies the `q` image URL with the `classifier` backend
+// for the async page, so that the browser never calls the classifier func. The classifier
+// headers, e.g., to authenticate, and the timeout are applied here and the
+// classified images are cached
+func (summaryFn *SummaryFn) ClassifyHandler(writer http.ResponseWriter, request *http.Request) {
+	imageURL := summaryFn.ExtractQueryStringParam(request, []string{"q", "query", "image-url", "u"}, "")
+	if imageURL == "" {
+		http.Error(writer, "you must pass a `q` image URL", http.StatusBadRequest)
+		return
+	}
+
+	err := common.ValidateHTTPURL(imageURL)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("invalid `q` image URL: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	classifier, ok := summaryFn.classifierQueryParam(writer, request)
+	if !ok {
+		return
+	}
+
+	classifyFn := summaryFn.ClassifyFn
+	classifyFn.InitClassifyQueryParams(request)
+	log.Printf("SummaryFn.Classify: q=\"%s\", classifier=\"%s\", max=\"%d\", threshold=\"%1.3f\"", imageURL, classifier, classifyFn.MaxLabels, classifyFn.MinScore)
+
+	cacheKey := fmt.Sprintf("%s|%s|%d|%f", summaryFn.classifierName(classifier), imageURL, classifyFn.MaxLabels, classifyFn.MinScore)
+	classifiedImage, ok := summaryFn.classifyCache.get(cacheKey, time.Now())
+	if !ok {
+		ctx, cancel := context.WithTimeout(request.Context(), time.Duration(summaryFn.ClassifyTimeout)*time.Second)
+		defer cancel()
+
+		classifiedImage, err = summaryFn.classifyImage(ctx, classifier, imageURL, classifyFn)
+		if err != nil {
+			// the error is only logged since it can contain the classifier URL
+			log.Printf("Error classifying image '%s': %s\n", imageURL, err.Error())
+			http.Error(writer, fmt.Sprintf("error classifying image '%s'", imageURL), http.StatusBadGateway)
+			return
+		}
+		summaryFn.classifyCache.put(cacheKey, classifiedImage, time.Now())
+	}
+
+	common.RespondOutput(writer, http.StatusOK, classifiedImage, "json", nil)
+}
+
+// AnalyzeHandler analyzes the sentiment of the `q` text with nlu-fn for the
+// async page, like ClassifyHandler
+func (summaryFn *SummaryFn) AnalyzeHandler(writer http.ResponseWriter, request *http.Request) {
+	if summaryFn.NLUFnURL == "" {
+		http.Error(writer, "text analysis is disabled, start summary-fn with a --nlu-fn-url", http.StatusNotFound)
+		return
+	}
+
+	text := summaryFn.ExtractQueryStringParam(request, []string{"q", "query", "text", "t"}, "")
+	if text == "" {
+		http.Error(writer, "you must pass a `q` text", http.StatusBadRequest)
+		return
+	}
+
+	if len(text) > maxAnalyzeTextLength {
+		http.Error(writer, fmt.Sprintf("the `q` text is longer than %d bytes", maxAnalyzeTextLength), http.StatusBadRequest)
+		return
+	}
+	log.Printf("SummaryFn.Analyze: q=\"%s\"", text)
+
+	ctx, cancel := context.WithTimeout(request.Context(), time.Duration(summaryFn.ClassifyTimeout)*time.Second)
+	defer cancel()
+
+	textAnalysis, err := client.NewNLUClient(summaryFn.NLUFnURL, summaryFn.Timeout).Analyze(ctx, text)
+	if err != nil {
+		log.Printf("Error analyzing text '%s': %s\n", text, err.Error())
+		http.Error(writer, "error analyzing text", http.StatusBadGateway)
+		return
+	}
+
+	common.RespondOutput(writer, http.StatusOK, textAnalysis, "json", nil)
+}
+
+// Private classifyCache
+
+func newClassifyCache(ttl time.Duration) *classifyCache {
+	return &classifyCache{
+		ttl:     ttl,
+		entries: map[string]classifyCacheEntry{},
+	}
+}
+
+func (cache *classifyCache) get(key string, now time.Time) (ClassifiedImage, bool) {
+	if cache == nil {
+		return ClassifiedImage{}, false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, ok := cache.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return ClassifiedImage{}, false
+	}
+	return entry.classifiedImage, true
+}
+
+// put evicts the expired entries, then the entry expiring first, when the
+// cache is full. A ttl of 0 disables the cache
+func (cache *classifyCache) put(key string, classifiedImage ClassifiedImage, now time.Time) {
+	if cache == nil || cache.ttl <= 0 {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(cache.entries) >= maxClassifyCacheEntries {
+		oldestKey := ""
+		for entryKey, entry := range cache.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(cache.entries, entryKey)
+				continue
+			}
+			if oldestKey == "" || entry.expiresAt.Before(cache.entries[oldestKey].expiresAt) {
+				oldestKey = entryKey
+			}
+		}
+		if len(cache.entries) >= maxClassifyCacheEntries {
+			delete(cache.entries, oldestKey)
+		}
+	}
+
+	cache.entries[key] = classifyCacheEntry{classifiedImage: classifiedImage, expiresAt: now.Add(cache.ttl)}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestClassifyHandler(t *testing.T) {
+	var classifications int32
+	classifierServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&classifications, 1)
+		assert.Equal(t, request.Header.Get("Authorization"), "Bearer token")
+		assert.Equal(t, request.URL.Query().Get("max"), "3")
+		fmt.Fprintf(writer, `{"ImageURL": "%s", "labels": [{"name": "ball", "score": 0.9}]}`, request.URL.Query().Get("q"))
+	}))
+	defer classifierServer.Close()
+
+	summaryFn := &SummaryFn{
+		WatsonFnURL:       classifierServer.URL,
+		ClassifierHeaders: map[string]string{"Authorization": "Bearer token"},
+		ClassifyTimeout:   5,
+		classifyCache:     newClassifyCache(time.Minute),
+	}
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		summaryFn.ClassifyHandler(recorder, httptest.NewRequest("GET", "/classify?q=http%3A%2F%2Fimg%2F1.jpg%3Fsize%3Dlarge&max=3", nil))
+		assert.Equal(t, recorder.Code, http.StatusOK)
+
+		classifiedImage := ClassifiedImage{}
+		assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &classifiedImage))
+		assert.DeepEqual(t, classifiedImage, ClassifiedImage{ImageURL: "http://img/1.jpg?size=large", Labels: []Label{Label{Name: "ball", Score: 0.9}}})
+	}
+	assert.Equal(t, atomic.LoadInt32(&classifications), int32(1))
+
+	recorder := httptest.NewRecorder()
+	summaryFn.ClassifyHandler(recorder, httptest.NewRequest("GET", "/classify", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+
+	// only the http(s) URLs of images are classified, not the files of the
+	// classifier func
+	for _, imageURL := range []string{"/etc/passwd", "file:///etc/passwd", "ftp://img/1.jpg", "http://"} {
+		recorder = httptest.NewRecorder()
+		summaryFn.ClassifyHandler(recorder, httptest.NewRequest("GET", "/classify?q="+url.QueryEscape(imageURL), nil))
+		assert.Equal(t, recorder.Code, http.StatusBadRequest, imageURL)
+	}
+	assert.Equal(t, atomic.LoadInt32(&classifications), int32(1))
+}
+
+func TestClassifyHandlerError(t *testing.T) {
+	classifierServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "cannot classify", http.StatusInternalServerError)
+	}))
+	defer classifierServer.Close()
+
+	summaryFn := &SummaryFn{WatsonFnURL: classifierServer.URL, ClassifyTimeout: 5}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.ClassifyHandler(recorder, httptest.NewRequest("GET", "/classify?q=http://img/1.jpg", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadGateway)
+	assert.Assert(t, !strings.Contains(recorder.Body.String(), classifierServer.URL))
+}
+
+func TestAnalyzeHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	(&SummaryFn{}).AnalyzeHandler(recorder, httptest.NewRequest("GET", "/analyze?q=dunk", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	nluServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, `{"sentiment": {"label": "positive", "score": 0.5}}`)
+	}))
+	defer nluServer.Close()
+
+	recorder = httptest.NewRecorder()
+	(&SummaryFn{NLUFnURL: nluServer.URL, ClassifyTimeout: 5}).AnalyzeHandler(recorder, httptest.NewRequest("GET", "/analyze?q=dunk", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	textAnalysis := TextAnalysis{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &textAnalysis))
+	assert.Equal(t, textAnalysis.Sentiment.Label, "positive")
+
+	recorder = httptest.NewRecorder()
+	(&SummaryFn{NLUFnURL: nluServer.URL, ClassifyTimeout: 5}).AnalyzeHandler(recorder, httptest.NewRequest("GET", "/analyze?q="+strings.Repeat("a", maxAnalyzeTextLength+1), nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+}
+
+func TestClassifyCache(t *testing.T) {
+	now := time.Now()
+	cache := newClassifyCache(time.Minute)
+	cache.put("1", ClassifiedImage{ImageURL: "http://img/1.jpg"}, now)
+
+	classifiedImage, ok := cache.get("1", now.Add(30*time.Second))
+	assert.Assert(t, ok)
+	assert.Equal(t, classifiedImage.ImageURL, "http://img/1.jpg")
+
+	_, ok = cache.get("1", now.Add(time.Minute))
+	assert.Assert(t, !ok)
+
+	var nilCache *classifyCache
+	nilCache.put("1", ClassifiedImage{}, now)
+	_, ok = nilCache.get("1", now)
+	assert.Assert(t, !ok)
+
+	disabledCache := newClassifyCache(0)
+	disabledCache.put("1", ClassifiedImage{}, now)
+	_, ok = disabledCache.get("1", now)
+	assert.Assert(t, !ok)
+}
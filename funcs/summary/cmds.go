@@ -12,13 +12,18 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package summary
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/maximilien/knfun/funcs/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -37,12 +42,13 @@ func NewSummaryCmd() *cobra.Command {
 		Short: "Summary root function",
 		Long: `Summarizes the TwitterFn and WatsonFn APIs funcs
 searching for tweets (with images) that contains SEARCH_STRING`,
+		Args: cobra.ArbitraryArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			summaryFn.initInputFlags(args)
 			return summaryFn.InitCommonInputFlags(args)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			if len(args) == 0 && !summaryFn.Stdin {
 				cmd.Help()
 				os.Exit(0)
 			}
@@ -51,8 +57,12 @@ searching for tweets (with images) that contains SEARCH_STRING`,
 		},
 	}
 
+	summaryCmd.AddCommand(summaryFn.newHistoryCmd())
+	summaryCmd.AddCommand(summaryFn.newReportCmd())
+
 	summaryFn.AddCommonCmdFlags(summaryCmd)
 	summaryFn.addSummaryCmdFlags(summaryCmd)
+	summaryFn.AddClassifyCmdFlags(summaryCmd)
 
 	return summaryCmd
 }
@@ -61,38 +71,369 @@ func Execute() error {
 	return NewSummaryCmd().Execute()
 }
 
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process, closing its store when the context is
+// done
+func Handler(ctx context.Context) (http.Handler, error) {
+	if summaryFn == nil {
+		NewSummaryCmd()
+	}
+
+	summaryFn.initInputFlags([]string{})
+
+	err := summaryFn.openStore()
+	if err != nil {
+		return nil, err
+	}
+
+	err = summaryFn.loadAlertRules()
+	if err != nil {
+		summaryFn.closeStore()
+		return nil, err
+	}
+
+	handler, err := summaryFn.handler(ctx)
+	if err != nil {
+		summaryFn.closeStore()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		summaryFn.closeStore()
+	}()
+
+	return handler, nil
+}
+
 // Private
 
 func (summaryFn *SummaryFn) summary(cmd *cobra.Command, args []string) error {
+	err := summaryFn.openStore()
+	if err != nil {
+		return err
+	}
+	defer summaryFn.closeStore()
+
+	err = summaryFn.loadAlertRules()
+	if err != nil {
+		return err
+	}
+	defer summaryFn.waitAlerts()
+
 	if summaryFn.StartServer {
-		if os.Getenv("ASYNC") != "" {
-			http.HandleFunc("/", summaryFn.SummaryAsyncHandler)
-		} else {
-			http.HandleFunc("/", summaryFn.SummaryHandler)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		handler, err := summaryFn.handler(ctx)
+		if err != nil {
+			return err
 		}
 
-		return http.ListenAndServe(fmt.Sprintf(":%d", summaryFn.Port), nil)
+		return summaryFn.ListenAndServe(handler)
+	} else if summaryFn.CompareClassifier != "" {
+		return summaryFn.compare()
+	} else if summaryFn.Stdin {
+		if summaryFn.Output == "html" {
+			return errors.New("the html output cannot be used with --stdin, use text, yaml, json, or ndjson")
+		}
+		return summaryFn.ProcessLines(os.Stdin, os.Stdout, summaryFn.summaryLine)
 	} else {
-		classifiedTweets, err := summaryFn.Summary()
+		summaryData, err := summaryFn.Summary(context.Background())
+		if err == nil {
+			summaryFn.summarized(summaryData)
+		}
+
+		summaryData = summaryFn.ViewOptions.Apply(summaryData)
+		if summaryFn.Output == "html" {
+			if err != nil {
+				return err
+			}
+			return summaryFn.renderHTML(context.Background(), os.Stdout, summaryData, SummaryLinks{})
+		}
+
+		if printErr := common.PrintOutput(os.Stdout, &summaryData, summaryFn.Output, summaryData.ToText); printErr != nil {
+			return printErr
+		}
 		if err != nil {
-			return err
+			// the errors are already part of the printed summary document
+			summaryFn.waitAlerts()
+			summaryFn.closeStore()
+			os.Exit(1)
 		}
+	}
+
+	return nil
+}
+
+// summaryLine summarizes a line of the standard input as search string, whose
+// summary lists its errors, if any
+func (summaryFn *SummaryFn) summaryLine(line string) (string, error) {
+	lineFn := *summaryFn
+	lineFn.SearchString = line
 
-		for _, cTweet := range classifiedTweets {
-			fmt.Printf("%s\n", cTweet.Flatten(summaryFn.Output))
-			fmt.Printf("=======\n\n")
+	summaryData, err := lineFn.Summary(context.Background())
+	if err == nil {
+		lineFn.summarized(summaryData)
+	}
+
+	summaryData = lineFn.ViewOptions.Apply(summaryData)
+	outputData, flattenErr := common.Flatten(&summaryData, lineFn.Output, summaryData.ToText)
+	if flattenErr != nil {
+		return "", flattenErr
+	}
+	return outputData, err
+}
+
+// handler loads the templates and returns the routes of the summary server,
+// whose poller and summary jobs run until the context is done
+func (summaryFn *SummaryFn) handler(ctx context.Context) (http.Handler, error) {
+	err := summaryFn.summaryTemplates().Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// created before serving since the requests share it
+	summaryFn.classifyCache = newClassifyCache(time.Duration(summaryFn.ClassifyCacheTTL) * time.Second)
+
+	summaryFn.poller = NewPoller(summaryFn, summaryFn.PollWindow, summaryFn.Watch)
+	if summaryFn.PollInterval > 0 {
+		go summaryFn.poller.Run(ctx, time.Duration(summaryFn.PollInterval)*time.Second)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", summaryFn.HealthzHandler)
+	mux.Handle("/static/", summaryFn.summaryTemplates().StaticHandler())
+	mux.HandleFunc("/events", summaryFn.SummaryEventsHandler)
+	mux.HandleFunc("/live", summaryFn.SummaryLiveHandler)
+	mux.HandleFunc("/history", summaryFn.HistoryHandler)
+	mux.HandleFunc("/history/", summaryFn.HistoryHandler)
+	mux.HandleFunc("/report", summaryFn.ReportHandler)
+	mux.HandleFunc("/poll", summaryFn.PollHandler)
+	mux.HandleFunc("/poll/events", summaryFn.PollEventsHandler)
+	mux.HandleFunc("/classify", summaryFn.ClassifyHandler)
+	mux.HandleFunc("/analyze", summaryFn.AnalyzeHandler)
+	mux.HandleFunc("/jobs", summaryFn.JobsHandler)
+	mux.HandleFunc("/jobs/", summaryFn.JobsHandler)
+	if summaryFn.Async {
+		summaryFn.jobs = NewJobManager(summaryFn, summaryFn.JobWorkers, summaryFn.JobQueue)
+		go summaryFn.jobs.Run(ctx)
+
+		mux.HandleFunc("/", summaryFn.SummaryAsyncHandler)
+	} else {
+		mux.HandleFunc("/", summaryFn.SummaryHandler)
+	}
+	return mux, nil
+}
+
+func (summaryFn *SummaryFn) compare() error {
+	comparisonData, err := summaryFn.Compare(context.Background(), "", summaryFn.CompareClassifier)
+	if len(comparisonData.Classifiers) == 0 {
+		return err
+	}
+
+	if summaryFn.Output == "html" {
+		if err != nil {
+			return err
 		}
+		return summaryFn.renderComparison(os.Stdout, comparisonData)
 	}
 
+	if printErr := common.PrintOutput(os.Stdout, &comparisonData, summaryFn.Output, comparisonData.ToText); printErr != nil {
+		return printErr
+	}
+	if err != nil {
+		// the errors are already part of the printed comparison document
+		summaryFn.closeStore()
+		os.Exit(1)
+	}
 	return nil
 }
 
+func (summaryFn *SummaryFn) newHistoryCmd() *cobra.Command {
+	limit := defaultHistoryLimit
+
+	historyCmd := &cobra.Command{
+		Use:   "history [SEARCH_ID]",
+		Short: "Browse the stored searches",
+		Long: `Lists the searches stored with --store-backend, most recent first, or
+renders the summary of the search with SEARCH_ID without searching and
+classifying again`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			summaryFn.initInputFlags(args)
+			if summaryFn.StoreBackend == "" {
+				return errors.New("you must pass a --store-backend to browse the history")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return summaryFn.history(args, limit)
+		},
+	}
+
+	historyCmd.Flags().StringVarP(&summaryFn.Output, "output", "o", "text", common.OutputUsage("html"))
+	historyCmd.Flags().IntVar(&limit, "limit", defaultHistoryLimit, "the max number of searches to list, 0 for all")
+
+	return historyCmd
+}
+
+func (summaryFn *SummaryFn) history(args []string, limit int) error {
+	err := summaryFn.openStore()
+	if err != nil {
+		return err
+	}
+	defer summaryFn.closeStore()
+
+	if len(args) == 0 {
+		storedSearches, err := summaryFn.store.Searches(limit)
+		if err != nil {
+			return err
+		}
+
+		if summaryFn.Output == "html" {
+			return summaryFn.summaryTemplates().Execute(os.Stdout, historyLayoutTemplate, HistoryPageData{PageTitle: "Past searches", Searches: storedSearches})
+		}
+
+		return common.PrintOutput(os.Stdout, &storedSearches, summaryFn.Output, storedSearches.ToText)
+	}
+
+	_, summaryData, err := summaryFn.store.LoadSearch(args[0])
+	if err != nil {
+		return err
+	}
+
+	summaryData = summaryFn.ViewOptions.Apply(summaryData)
+	if summaryFn.Output == "html" {
+		return summaryFn.renderHTML(context.Background(), os.Stdout, summaryData, SummaryLinks{})
+	}
+
+	return common.PrintOutput(os.Stdout, &summaryData, summaryFn.Output, summaryData.ToText)
+}
+
+func (summaryFn *SummaryFn) newReportCmd() *cobra.Command {
+	var format, searchID, out string
+
+	reportCmd := &cobra.Command{
+		Use:   "report [SEARCH_STRING]",
+		Short: "Export a summary as a CSV, Markdown, or HTML report",
+		Long: `Summarizes the tweets with images that contains SEARCH_STRING, or loads the
+stored search with --search-id, and exports it as a CSV file with a row per
+image label, a Markdown digest, or a self-contained HTML page with inlined
+thumbnails and charts`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			summaryFn.initInputFlags(args)
+			if searchID != "" {
+				if summaryFn.StoreBackend == "" {
+					return errors.New("you must pass a --store-backend to report a stored search")
+				}
+				return nil
+			}
+			return summaryFn.InitCommonInputFlags(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return summaryFn.report(format, searchID, out)
+		},
+	}
+
+	reportCmd.Flags().StringVarP(&format, "format", "f", htmlReport, "the report format: csv, markdown, or html")
+	reportCmd.Flags().StringVar(&searchID, "search-id", "", "report the stored search with this ID instead of searching")
+	reportCmd.Flags().StringVar(&out, "out", "", "the report file (default stdout)")
+	reportCmd.Flags().IntVarP(&summaryFn.Count, "count", "c", 10, "the max number of results")
+	summaryFn.AddClassifyCmdFlags(reportCmd)
+
+	return reportCmd
+}
+
+func (summaryFn *SummaryFn) report(format string, searchID string, out string) error {
+	if reportContentType(format) == "" {
+		return fmt.Errorf("unknown report format '%s', expected csv, markdown, or html", format)
+	}
+
+	err := summaryFn.openStore()
+	if err != nil {
+		return err
+	}
+	defer summaryFn.closeStore()
+
+	summaryData, _, err := summaryFn.reportSummary(context.Background(), searchID)
+	if err != nil {
+		return err
+	}
+
+	viewOptions := summaryFn.ViewOptions
+	viewOptions.Page, viewOptions.PageSize = 1, 0
+	summaryData = viewOptions.Apply(summaryData)
+
+	if out == "" {
+		return summaryFn.WriteReport(os.Stdout, format, summaryData)
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	err = summaryFn.WriteReport(file, format, summaryData)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 func (summaryFn *SummaryFn) addSummaryCmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&summaryFn.TwitterFnURL, "twitter-fn-url", "", "twitter API func URL")
 	cmd.PersistentFlags().StringVar(&summaryFn.WatsonFnURL, "watson-fn-url", "", "watson API func URL")
+	cmd.PersistentFlags().StringVar(&summaryFn.NLUFnURL, "nlu-fn-url", "", "NLU func URL to analyze the sentiment of the text (optional)")
+	cmd.PersistentFlags().StringToStringVar(&summaryFn.classifierURLs, "classifiers", map[string]string{}, "named classifier func URLs, e.g., watson=URL1,gvision=URL2,local=URL3 (default the watson-fn-url)")
+	cmd.PersistentFlags().StringVar(&summaryFn.Classifier, "classifier", "", "the name of the classifier of the images (default the first classifier)")
+	cmd.PersistentFlags().StringVar(&summaryFn.CompareClassifier, "compare", "", "compare the labels of the classifier with the labels of this other classifier")
+	cmd.PersistentFlags().StringToStringVar(&summaryFn.ClassifierHeaders, "classifier-headers", map[string]string{}, "headers sent to the classifier funcs, e.g., Authorization='Bearer TOKEN'")
+	cmd.PersistentFlags().IntVar(&summaryFn.ClassifyTimeout, "classify-timeout", defaultClassifyTimeout, "seconds before the /classify and /analyze proxies of the async page time out")
+	cmd.PersistentFlags().IntVar(&summaryFn.ClassifyCacheTTL, "classify-cache-ttl", defaultClassifyCacheTTL, "seconds the /classify proxy caches the classified images, 0 to disable")
+	cmd.PersistentFlags().StringToStringVar(&summaryFn.sourceURLs, "sources", map[string]string{}, "named content source func URLs, e.g., twitter=URL1,mastodon=URL2 (default the twitter-fn-url)")
+	cmd.PersistentFlags().StringToIntVar(&summaryFn.sourceWeights, "source-weights", map[string]int{}, "weights of the named content sources, e.g., twitter=2,mastodon=1 (default 1)")
+	cmd.PersistentFlags().StringVar(&summaryFn.ViewOptions.View, "view", listView, "the view of the HTML output: list or table")
+	cmd.PersistentFlags().StringVar(&summaryFn.ViewOptions.Sort, "sort", sortByTime, "sort the classified tweets by: time, confidence, or label")
+	cmd.PersistentFlags().StringSliceVar(&summaryFn.ViewOptions.Labels, "label", []string{}, "only keep the tweets with one of these image labels")
+	cmd.PersistentFlags().Float64Var(&summaryFn.ViewOptions.MinConfidence, "min-confidence", 0.0, "only keep the tweets with an image label of at least this confidence")
+	cmd.PersistentFlags().IntVar(&summaryFn.ViewOptions.Page, "page", 1, "the page of classified tweets")
+	cmd.PersistentFlags().IntVar(&summaryFn.ViewOptions.PageSize, "page-size", defaultPageSize, "the number of classified tweets per page, 0 for all")
+	cmd.PersistentFlags().StringVar(&summaryFn.StoreBackend, "store-backend", "", "store the summaries to browse their history: bolt or memory (default no storage)")
+	cmd.PersistentFlags().StringVar(&summaryFn.StorePath, "store-path", "knfun-summary.db", "the BoltDB file of the bolt store backend")
+	cmd.PersistentFlags().StringSliceVar(&summaryFn.Watch, "watch", []string{}, "search strings polled for new tweets when started as a server")
+	cmd.PersistentFlags().IntVar(&summaryFn.PollInterval, "poll-interval", 0, "seconds between the polls of the watched searches, 0 to only poll on /poll requests, e.g., from a PingSource")
+	cmd.PersistentFlags().IntVar(&summaryFn.PollWindow, "poll-window", defaultPollWindow, "the number of most recent tweets kept for each watched search, 0 for all")
+	cmd.PersistentFlags().IntVar(&summaryFn.PollMaxSearches, "poll-max-searches", defaultPollMaxSearches, "the max number of searches polled for the /poll and /poll/events clients besides the watched ones, 0 for no limit")
+	cmd.PersistentFlags().StringVar(&summaryFn.AlertRulesFile, "alert-rules", "", "YAML file with the alert rules firing webhooks on matching image labels (default the alerts of the config file)")
+	cmd.PersistentFlags().BoolVar(&summaryFn.Async, "async", false, "serve the async summary page and the /jobs API running summary jobs in the background")
+	cmd.PersistentFlags().IntVar(&summaryFn.JobWorkers, "job-workers", defaultJobWorkers, "the number of summary jobs running at the same time with --async")
+	cmd.PersistentFlags().IntVar(&summaryFn.JobQueue, "job-queue", defaultJobQueue, "the max number of summary jobs waiting for a worker with --async")
+	cmd.PersistentFlags().StringSliceVar(&summaryFn.JobCallbackHosts, "job-callback-hosts", []string{}, "hosts, optionally with a port, the finished jobs can be POSTed to with the callback parameter (default no callbacks)")
+	cmd.PersistentFlags().StringVar(&summaryFn.TemplatesDir, "templates-dir", "", "directory with custom layout.html, async_layout.html, live_layout.html, history_layout.html, report_layout.html, compare_layout.html and static/ assets (default the embedded theme)")
+	cmd.PersistentFlags().BoolVar(&summaryFn.Dev, "dev", false, "dev mode, re-parses the templates on every request")
 
 	viper.BindPFlag("twitter-fn-url", cmd.PersistentFlags().Lookup("twitter-fn-url"))
 	viper.BindPFlag("watson-fn-url", cmd.PersistentFlags().Lookup("watson-fn-url"))
+	viper.BindPFlag("nlu-fn-url", cmd.PersistentFlags().Lookup("nlu-fn-url"))
+	viper.BindPFlag("classifier", cmd.PersistentFlags().Lookup("classifier"))
+	viper.BindPFlag("classify-timeout", cmd.PersistentFlags().Lookup("classify-timeout"))
+	viper.BindPFlag("classify-cache-ttl", cmd.PersistentFlags().Lookup("classify-cache-ttl"))
+	viper.BindPFlag("store-backend", cmd.PersistentFlags().Lookup("store-backend"))
+	viper.BindPFlag("store-path", cmd.PersistentFlags().Lookup("store-path"))
+	viper.BindPFlag("watch", cmd.PersistentFlags().Lookup("watch"))
+	viper.BindPFlag("poll-interval", cmd.PersistentFlags().Lookup("poll-interval"))
+	viper.BindPFlag("poll-window", cmd.PersistentFlags().Lookup("poll-window"))
+	viper.BindPFlag("poll-max-searches", cmd.PersistentFlags().Lookup("poll-max-searches"))
+	viper.BindPFlag("alert-rules", cmd.PersistentFlags().Lookup("alert-rules"))
+	viper.BindPFlag("async", cmd.PersistentFlags().Lookup("async"))
+	viper.BindPFlag("job-workers", cmd.PersistentFlags().Lookup("job-workers"))
+	viper.BindPFlag("job-queue", cmd.PersistentFlags().Lookup("job-queue"))
+	viper.BindPFlag("job-callback-hosts", cmd.PersistentFlags().Lookup("job-callback-hosts"))
+	viper.BindPFlag("templates-dir", cmd.PersistentFlags().Lookup("templates-dir"))
+	viper.BindPFlag("dev", cmd.PersistentFlags().Lookup("dev"))
 }
 
 func (summaryFn *SummaryFn) initInputFlags(args []string) {
@@ -103,4 +444,79 @@ func (summaryFn *SummaryFn) initInputFlags(args []string) {
 	if summaryFn.WatsonFnURL == "" {
 		summaryFn.WatsonFnURL = viper.GetString("watson-fn-url")
 	}
+
+	if summaryFn.NLUFnURL == "" {
+		summaryFn.NLUFnURL = viper.GetString("nlu-fn-url")
+	}
+
+	if summaryFn.Classifier == "" {
+		summaryFn.Classifier = viper.GetString("classifier")
+	}
+
+	if len(summaryFn.classifierURLs) > 0 {
+		summaryFn.Classifiers = parseClassifierBackends(summaryFn.classifierURLs)
+	} else {
+		err := viper.UnmarshalKey("classifiers", &summaryFn.Classifiers)
+		if err != nil {
+			log.Printf("Error reading `classifiers` config: %s\n", err.Error())
+		}
+	}
+
+	if len(summaryFn.ClassifierHeaders) == 0 {
+		summaryFn.ClassifierHeaders = viper.GetStringMapString("classifier-headers")
+	}
+
+	summaryFn.ClassifyTimeout = viper.GetInt("classify-timeout")
+	summaryFn.ClassifyCacheTTL = viper.GetInt("classify-cache-ttl")
+
+	if summaryFn.StoreBackend == "" {
+		summaryFn.StoreBackend = viper.GetString("store-backend")
+	}
+
+	// viper returns the flag when set, then the config value, then the default
+	summaryFn.StorePath = viper.GetString("store-path")
+
+	if len(summaryFn.Watch) == 0 {
+		summaryFn.Watch = viper.GetStringSlice("watch")
+	}
+
+	if summaryFn.PollInterval == 0 {
+		summaryFn.PollInterval = viper.GetInt("poll-interval")
+	}
+
+	summaryFn.PollWindow = viper.GetInt("poll-window")
+	summaryFn.PollMaxSearches = viper.GetInt("poll-max-searches")
+
+	if summaryFn.AlertRulesFile == "" {
+		summaryFn.AlertRulesFile = viper.GetString("alert-rules")
+	}
+
+	// also set by the former ASYNC env variable since viper reads the env
+	if !summaryFn.Async {
+		summaryFn.Async = viper.GetBool("async")
+	}
+
+	summaryFn.JobWorkers = viper.GetInt("job-workers")
+	summaryFn.JobQueue = viper.GetInt("job-queue")
+
+	if len(summaryFn.JobCallbackHosts) == 0 {
+		summaryFn.JobCallbackHosts = viper.GetStringSlice("job-callback-hosts")
+	}
+
+	if summaryFn.TemplatesDir == "" {
+		summaryFn.TemplatesDir = viper.GetString("templates-dir")
+	}
+
+	if !summaryFn.Dev {
+		summaryFn.Dev = viper.GetBool("dev")
+	}
+
+	if len(summaryFn.sourceURLs) > 0 {
+		summaryFn.Sources = parseContentSources(summaryFn.sourceURLs, summaryFn.sourceWeights)
+	} else {
+		err := viper.UnmarshalKey("sources", &summaryFn.Sources)
+		if err != nil {
+			log.Printf("Error reading `sources` config: %s\n", err.Error())
+		}
+	}
 }
@@ -0,0 +1,273 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+// ComparisonData has the labels of each image from two classifiers. The
+// agreement of an image is the Jaccard index of the case insensitive label
+// names of both classifiers, and the agreement of the comparison is the mean
+// agreement of the images both classifiers classified
+type ComparisonData struct {
+	Query       SummaryQuery      `yaml:"query" json:"query"`
+	Classifiers []string          `yaml:"classifiers" json:"classifiers"`
+	Agreement   float64           `yaml:"agreement" json:"agreement"`
+	Compared    int               `yaml:"compared" json:"compared"`
+	Images      []ImageComparison `yaml:"images" json:"images"`
+	Errors      []string          `yaml:"errors,omitempty" json:"errors,omitempty"`
+}
+
+type ImageComparison struct {
+	TweetID         string             `yaml:"tweet-id,omitempty" json:"tweet-id,omitempty"`
+	Text            string             `yaml:"text" json:"text"`
+	Source          string             `yaml:"source" json:"source"`
+	ImageURL        string             `yaml:"image-url" json:"image-url"`
+	Classifications []ClassifierLabels `yaml:"classifications" json:"classifications"`
+	Compared        bool               `yaml:"compared" json:"compared"`
+	Agreement       float64            `yaml:"agreement" json:"agreement"`
+	CommonLabels    []string           `yaml:"common-labels" json:"common-labels"`
+}
+
+type ClassifierLabels struct {
+	Classifier string  `yaml:"classifier" json:"classifier"`
+	Labels     []Label `yaml:"labels" json:"labels"`
+	Error      string  `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+type ComparePageData struct {
+	PageTitle  string
+	Comparison ComparisonData
+}
+
+// Compare classifies the images of the tweets with both classifiers, the
+// default one when a name is empty
+func (summaryFn *SummaryFn) Compare(ctx context.Context, classifier string, otherClassifier string) (ComparisonData, error) {
+	classifiers := []string{}
+	for _, name := range []string{classifier, otherClassifier} {
+		backend, err := summaryFn.classifierBackend(name)
+		if err != nil {
+			return ComparisonData{}, err
+		}
+		classifiers = append(classifiers, backend.Name)
+	}
+
+	if classifiers[0] == classifiers[1] {
+		return ComparisonData{}, fmt.Errorf("you must compare two different classifiers, not '%s' with itself", classifiers[0])
+	}
+
+	comparisonData := ComparisonData{
+		Query:       summaryFn.summaryQuery(),
+		Classifiers: classifiers,
+		Images:      []ImageComparison{},
+	}
+	comparisonData.Query.Classifier = ""
+
+	tweets, errorMessages, err := summaryFn.collectTweetsWithErrors(ctx, summaryFn.SearchString, summaryFn.Count)
+	comparisonData.Errors = append(comparisonData.Errors, errorMessages...)
+	if err != nil {
+		comparisonData.Errors = append(comparisonData.Errors, err.Error())
+		return comparisonData, err
+	}
+
+	for _, tweet := range summaryFn.collectTweetsWithImages(tweets) {
+		for _, imageURL := range tweet.ImageURLs {
+			comparisonData.Images = append(comparisonData.Images, ImageComparison{
+				TweetID:         tweet.ID,
+				Text:            tweet.Text,
+				Source:          tweet.Source,
+				ImageURL:        imageURL,
+				Classifications: make([]ClassifierLabels, len(classifiers)),
+			})
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, maxConcurrentClassifications)
+	)
+	for i := range comparisonData.Images {
+		for j, classifier := range classifiers {
+			wg.Add(1)
+			go func(imageComparison *ImageComparison, j int, classifier string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				classifierLabels := ClassifierLabels{Classifier: classifier, Labels: []Label{}}
+				classifiedImage, err := summaryFn.classifyImage(ctx, classifier, imageComparison.ImageURL, summaryFn.ClassifyFn)
+				if err != nil {
+					log.Printf("Error classifying image '%s' with '%s': %s\n", imageComparison.ImageURL, classifier, err.Error())
+					classifierLabels.Error = err.Error()
+				} else {
+					classifierLabels.Labels = classifiedImage.Labels
+				}
+				imageComparison.Classifications[j] = classifierLabels
+			}(&comparisonData.Images[i], j, classifier)
+		}
+	}
+	wg.Wait()
+
+	totalAgreement := 0.0
+	for i := range comparisonData.Images {
+		imageComparison := &comparisonData.Images[i]
+		for _, classifierLabels := range imageComparison.Classifications {
+			if classifierLabels.Error != "" {
+				comparisonData.Errors = append(comparisonData.Errors, fmt.Sprintf("%s: %s: %s", classifierLabels.Classifier, imageComparison.ImageURL, classifierLabels.Error))
+			}
+		}
+
+		first, second := imageComparison.Classifications[0], imageComparison.Classifications[1]
+		if first.Error != "" || second.Error != "" {
+			imageComparison.CommonLabels = []string{}
+			continue
+		}
+
+		imageComparison.CommonLabels, imageComparison.Agreement = labelsAgreement(first.Labels, second.Labels)
+		imageComparison.Compared = true
+		comparisonData.Compared++
+		totalAgreement += imageComparison.Agreement
+	}
+
+	if comparisonData.Compared > 0 {
+		comparisonData.Agreement = totalAgreement / float64(comparisonData.Compared)
+	}
+	return comparisonData, nil
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) writeComparison(ctx context.Context, writer http.ResponseWriter, output string, classifier string, otherClassifier string) {
+	log.Printf("SummaryFn.Compare: s=\"%s\", classifiers=\"%s,%s\"", summaryFn.SearchString, classifier, otherClassifier)
+
+	comparisonData, err := summaryFn.Compare(ctx, classifier, otherClassifier)
+	if err != nil {
+		log.Printf("Error comparing classifiers: %s\n", err.Error())
+		status := http.StatusBadGateway
+		if len(comparisonData.Classifiers) == 0 {
+			status = http.StatusBadRequest
+		}
+		http.Error(writer, err.Error(), status)
+		return
+	}
+
+	if output != "html" {
+		common.RespondOutput(writer, http.StatusOK, &comparisonData, output, comparisonData.ToText)
+		return
+	}
+
+	writer.Header().Add("Content-Type", common.OutputContentType("html"))
+	err = summaryFn.renderComparison(writer, comparisonData)
+	if err != nil {
+		log.Printf("Error executing compare template: %s\n", err.Error())
+	}
+}
+
+func (summaryFn *SummaryFn) renderComparison(writer io.Writer, comparisonData ComparisonData) error {
+	return summaryFn.summaryTemplates().Execute(writer, compareLayoutTemplate, ComparePageData{
+		PageTitle:  fmt.Sprintf("`%s` and `%s` labels for search `%s`", comparisonData.Classifiers[0], comparisonData.Classifiers[1], comparisonData.Query.SearchString),
+		Comparison: comparisonData,
+	})
+}
+
+// Private functions
+
+// labelsAgreement returns the sorted lower case label names of both labels and
+// their Jaccard index, 1 when both are empty
+func labelsAgreement(labels []Label, otherLabels []Label) ([]string, float64) {
+	names := labelNames(labels)
+	otherNames := labelNames(otherLabels)
+
+	union := map[string]bool{}
+	commonNames := []string{}
+	for name := range names {
+		union[name] = true
+		if otherNames[name] {
+			commonNames = append(commonNames, name)
+		}
+	}
+	for name := range otherNames {
+		union[name] = true
+	}
+	sort.Strings(commonNames)
+
+	if len(union) == 0 {
+		return commonNames, 1
+	}
+	return commonNames, float64(len(commonNames)) / float64(len(union))
+}
+
+// isCommonLabel is the `commonLabel` template func
+func isCommonLabel(commonLabels []string, name string) bool {
+	for _, commonLabel := range commonLabels {
+		if commonLabel == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func labelNames(labels []Label) map[string]bool {
+	names := map[string]bool{}
+	for _, label := range labels {
+		names[strings.ToLower(label.Name)] = true
+	}
+	return names
+}
+
+// ComparisonData
+
+func (comparisonData ComparisonData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("⚖️  `%s` and `%s` agree %.1f%% on %d images for search `%s`\n",
+		comparisonData.Classifiers[0], comparisonData.Classifiers[1], comparisonData.Agreement*100, comparisonData.Compared, comparisonData.Query.SearchString))
+
+	for _, imageComparison := range comparisonData.Images {
+		sb.WriteString(fmt.Sprintf("\n🖼  %s", imageComparison.ImageURL))
+		if imageComparison.Compared {
+			sb.WriteString(fmt.Sprintf(" (%.1f%% agreement)", imageComparison.Agreement*100))
+		}
+		sb.WriteString("\n")
+
+		for _, classifierLabels := range imageComparison.Classifications {
+			if classifierLabels.Error != "" {
+				sb.WriteString(fmt.Sprintf("    %s: ⚠️  %s\n", classifierLabels.Classifier, classifierLabels.Error))
+				continue
+			}
+
+			labels := []string{}
+			for _, label := range classifierLabels.Labels {
+				labels = append(labels, fmt.Sprintf("%s (%1.3f)", label.Name, label.Score))
+			}
+			sb.WriteString(fmt.Sprintf("    %s: %s\n", classifierLabels.Classifier, strings.Join(labels, ", ")))
+		}
+	}
+
+	for _, errorMessage := range comparisonData.Errors {
+		sb.WriteString(fmt.Sprintf("⚠️  %s\n", errorMessage))
+	}
+	return sb.String()
+}
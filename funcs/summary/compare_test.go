@@ -0,0 +1,88 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestLabelsAgreement(t *testing.T) {
+	for _, tc := range []struct {
+		labels       []Label
+		otherLabels  []Label
+		commonLabels []string
+		agreement    float64
+	}{
+		{[]Label{}, []Label{}, []string{}, 1},
+		{[]Label{Label{Name: "ball"}}, []Label{}, []string{}, 0},
+		{[]Label{Label{Name: "ball"}, Label{Name: "person"}}, []Label{Label{Name: "Ball"}, Label{Name: "Sports"}}, []string{"ball"}, 1.0 / 3},
+		{[]Label{Label{Name: "Ball"}, Label{Name: "court"}}, []Label{Label{Name: "court"}, Label{Name: "ball"}}, []string{"ball", "court"}, 1},
+	} {
+		commonLabels, agreement := labelsAgreement(tc.labels, tc.otherLabels)
+		assert.DeepEqual(t, commonLabels, tc.commonLabels)
+		assert.Equal(t, agreement, tc.agreement)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	summaryFn, closeServers := newCompareSummaryFn(t)
+	defer closeServers()
+
+	comparisonData, err := summaryFn.Compare(context.Background(), "", "gvision")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, comparisonData.Classifiers, []string{"watson", "gvision"})
+	assert.Equal(t, len(comparisonData.Images), 3)
+	assert.Equal(t, comparisonData.Compared, 2)
+	assert.Equal(t, comparisonData.Agreement, 1.0/3)
+	assert.DeepEqual(t, comparisonData.Images[0].CommonLabels, []string{"ball"})
+	assert.Equal(t, comparisonData.Images[2].Classifications[0].Error, "")
+	assert.Assert(t, comparisonData.Images[2].Classifications[1].Error != "")
+	assert.Assert(t, !comparisonData.Images[2].Compared)
+	assert.Equal(t, len(comparisonData.Errors), 1)
+
+	_, err = summaryFn.Compare(context.Background(), "watson", "watson")
+	assert.ErrorContains(t, err, "two different classifiers")
+}
+
+func TestSummaryHandlerCompare(t *testing.T) {
+	summaryFn, closeServers := newCompareSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&classifier=gvision&compare=watson&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	comparisonData := ComparisonData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &comparisonData))
+	assert.DeepEqual(t, comparisonData.Classifiers, []string{"gvision", "watson"})
+
+	summaryFn.templates = NewSummaryTemplates("", false, statsFuncs)
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&compare=gvision", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `<div class="common">ball (0.900)</div>`))
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "33.3%"))
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&compare=aws&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+}
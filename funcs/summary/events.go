@@ -0,0 +1,238 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	tweetEvent          = "tweet"
+	classificationEvent = "classification"
+	analysisEvent       = "analysis"
+	errorEvent          = "error"
+	statsEvent          = "stats"
+	doneEvent           = "done"
+
+	maxConcurrentClassifications = 4
+)
+
+// SummaryEvent is one step of a streamed summary: a tweet with images as soon
+// as it is collected, then each of its classified images and text analysis as
+// they complete, and finally the stats of the whole summary
+type SummaryEvent struct {
+	Type            string           `yaml:"type" json:"type"`
+	TweetIndex      int              `yaml:"tweet-index" json:"tweet-index"`
+	ImageIndex      int              `yaml:"image-index" json:"image-index"`
+	Tweet           *Tweet           `yaml:"tweet,omitempty" json:"tweet,omitempty"`
+	ClassifiedImage *ClassifiedImage `yaml:"classified-image,omitempty" json:"classified-image,omitempty"`
+	Analysis        *TextAnalysis    `yaml:"analysis,omitempty" json:"analysis,omitempty"`
+	Stats           *SummaryStats    `yaml:"stats,omitempty" json:"stats,omitempty"`
+	Error           string           `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// StreamSummary sends the summary events to the channel and closes it when the
+// summary is done or the context is cancelled
+func (summaryFn *SummaryFn) StreamSummary(ctx context.Context, searchString string, count int, classifier string, classifyFn common.ClassifyFn, events chan<- SummaryEvent) {
+	defer close(events)
+
+	var (
+		mutex         sync.Mutex
+		errorMessages []string
+	)
+
+	send := func(event SummaryEvent) {
+		if event.Type == errorEvent {
+			mutex.Lock()
+			errorMessages = append(errorMessages, event.Error)
+			mutex.Unlock()
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	tweets, sourceErrorMessages, err := summaryFn.collectTweetsWithErrors(ctx, searchString, count)
+	for _, errorMessage := range sourceErrorMessages {
+		send(SummaryEvent{Type: errorEvent, TweetIndex: -1, ImageIndex: -1, Error: errorMessage})
+	}
+	if err != nil {
+		send(SummaryEvent{Type: errorEvent, TweetIndex: -1, ImageIndex: -1, Error: err.Error()})
+		send(SummaryEvent{Type: doneEvent, TweetIndex: -1, ImageIndex: -1})
+		return
+	}
+
+	tweetsWithImages := summaryFn.collectTweetsWithImages(tweets)
+	for i := range tweetsWithImages {
+		send(SummaryEvent{Type: tweetEvent, TweetIndex: i, ImageIndex: -1, Tweet: &tweetsWithImages[i]})
+	}
+
+	var (
+		wg               sync.WaitGroup
+		classifiedImages = make([][]*ClassifiedImage, len(tweetsWithImages))
+		semaphore        = make(chan struct{}, maxConcurrentClassifications)
+	)
+
+	for i, tweet := range tweetsWithImages {
+		classifiedImages[i] = make([]*ClassifiedImage, len(tweet.ImageURLs))
+
+		if summaryFn.NLUFnURL != "" {
+			wg.Add(1)
+			go func(i int, tweet Tweet) {
+				defer wg.Done()
+				if analysis := summaryFn.analyzeTweet(ctx, tweet); analysis != nil {
+					send(SummaryEvent{Type: analysisEvent, TweetIndex: i, ImageIndex: -1, Analysis: analysis})
+				}
+			}(i, tweet)
+		}
+
+		for j, imageURL := range tweet.ImageURLs {
+			wg.Add(1)
+			go func(i int, j int, imageURL string) {
+				defer wg.Done()
+
+				select {
+				case semaphore <- struct{}{}:
+					defer func() { <-semaphore }()
+				case <-ctx.Done():
+					return
+				}
+
+				classifiedImage, err := summaryFn.classifyImage(ctx, classifier, imageURL, classifyFn)
+				if err != nil {
+					log.Printf("Error classifying image '%s': %s\n", imageURL, err.Error())
+					send(SummaryEvent{Type: errorEvent, TweetIndex: i, ImageIndex: j, Error: fmt.Sprintf("%s: %s", imageURL, err.Error())})
+					return
+				}
+
+				mutex.Lock()
+				classifiedImages[i][j] = &classifiedImage
+				mutex.Unlock()
+
+				send(SummaryEvent{Type: classificationEvent, TweetIndex: i, ImageIndex: j, ClassifiedImage: &classifiedImage})
+			}(i, j, imageURL)
+		}
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	classifiedTweets := []ClassifiedTweet{}
+	for i, tweet := range tweetsWithImages {
+		classifiedTweet := ClassifiedTweet{ID: tweet.ID, Text: tweet.Text, Source: tweet.Source, CreatedAt: tweet.CreatedAt}
+		for _, classifiedImage := range classifiedImages[i] {
+			if classifiedImage != nil {
+				classifiedTweet.ClassifiedImages = append(classifiedTweet.ClassifiedImages, *classifiedImage)
+			}
+		}
+
+		if len(classifiedTweet.ClassifiedImages) > 0 {
+			classifiedTweets = append(classifiedTweets, classifiedTweet)
+		}
+	}
+
+	stats := computeStats(len(tweets), classifiedTweets)
+
+	query := summaryFn.newSummaryQuery(searchString, count, classifier, classifyFn)
+	summaryFn.summarized(SummaryData{Query: query, Stats: stats, ClassifiedTweets: classifiedTweets, Errors: errorMessages})
+
+	send(SummaryEvent{Type: statsEvent, TweetIndex: -1, ImageIndex: -1, Stats: &stats})
+	send(SummaryEvent{Type: doneEvent, TweetIndex: -1, ImageIndex: -1})
+}
+
+// SummaryEventsHandler streams the summary as Server-Sent Events, one per
+// SummaryEvent with the event type as the SSE event name
+func (summaryFn *SummaryFn) SummaryEventsHandler(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	fn := summaryFn.requestFn(request)
+	log.Printf("SummaryFn.SummaryEvents: s=\"%s\", c=\"%d\"", fn.SearchString, fn.Count)
+
+	classifier, ok := fn.classifierQueryParam(writer, request)
+	if !ok {
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := make(chan SummaryEvent)
+	go fn.StreamSummary(request.Context(), fn.SearchString, fn.Count, classifier, fn.ClassifyFn, events)
+
+	for event := range events {
+		err := writeEvent(writer, event)
+		if err != nil {
+			log.Printf("Error writing summary event: %s\n", err.Error())
+			continue
+		}
+		flusher.Flush()
+	}
+}
+
+// SummaryLiveHandler renders the page that subscribes to the summary events
+// with the same query parameters, or to the poll events with `poll=true`
+func (summaryFn *SummaryFn) SummaryLiveHandler(writer http.ResponseWriter, request *http.Request) {
+	fn := summaryFn.requestFn(request)
+
+	data := SummaryPageData{
+		PageTitle: fmt.Sprintf("Live tweets with images for search `%s`", fn.SearchString),
+		EventsURL: "/events?" + request.URL.RawQuery,
+		Context:   request.Context(),
+	}
+
+	if poll, _ := strconv.ParseBool(fn.ExtractQueryStringParam(request, []string{"poll"}, "")); poll {
+		data.PageTitle = fmt.Sprintf("Polled tweets with images for search `%s`", fn.SearchString)
+		data.EventsURL = "/poll/events?" + request.URL.RawQuery
+		data.Poll = true
+	}
+
+	writer.Header().Add("Content-Type", common.OutputContentType("html"))
+	err := fn.summaryTemplates().Execute(writer, liveLayoutTemplate, data)
+	if err != nil {
+		log.Printf("Error executing live template: %s\n", err.Error())
+	}
+}
+
+// Private functions
+
+func writeEvent(writer io.Writer, event SummaryEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,150 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestSummaryEventsHandler(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryEventsHandler(recorder, httptest.NewRequest("GET", "/events?q=NBA&c=5", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/event-stream")
+
+	events := readEvents(t, recorder.Body.String())
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Type]++
+	}
+	assert.DeepEqual(t, counts, map[string]int{tweetEvent: 2, classificationEvent: 1, errorEvent: 2, statsEvent: 1, doneEvent: 1})
+
+	assert.Equal(t, events[0].Type, errorEvent)
+	assert.Assert(t, strings.HasPrefix(events[0].Error, "broken:"))
+	assert.Equal(t, events[1].Type, tweetEvent)
+	assert.Equal(t, events[1].Tweet.Text, "t1")
+
+	for _, event := range events {
+		if event.Type == classificationEvent {
+			assert.Equal(t, event.TweetIndex, 0)
+			assert.Equal(t, event.ClassifiedImage.ImageURL, "http://img/1.jpg")
+		}
+	}
+
+	stats := events[len(events)-2]
+	assert.Equal(t, stats.Type, statsEvent)
+	assert.Equal(t, stats.Stats.TweetCount, 3)
+	assert.Equal(t, stats.Stats.ImageCount, 1)
+	assert.Equal(t, events[len(events)-1].Type, doneEvent)
+}
+
+func TestStreamSummaryAllSourcesFail(t *testing.T) {
+	summaryFn := &SummaryFn{Sources: []ContentSource{ContentSource{Name: "broken", URL: "http://127.0.0.1:0"}}}
+
+	events := make(chan SummaryEvent)
+	go summaryFn.StreamSummary(context.Background(), "NBA", 5, "", summaryFn.ClassifyFn, events)
+
+	types := []string{}
+	for event := range events {
+		types = append(types, event.Type)
+	}
+	assert.DeepEqual(t, types, []string{errorEvent, errorEvent, doneEvent})
+}
+
+func TestStreamSummaryCancelled(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan SummaryEvent)
+	go summaryFn.StreamSummary(ctx, "NBA", 5, "", summaryFn.ClassifyFn, events)
+
+	<-events
+	cancel()
+
+	for event := range events {
+		assert.Assert(t, event.Type != doneEvent)
+	}
+}
+
+func TestStreamSummaryQuery(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	summaryFn.StoreBackend = memoryStoreBackend
+	assert.NilError(t, summaryFn.openStore())
+	defer summaryFn.closeStore()
+
+	// the stored query has the options of the stream, not the summaryFn ones
+	summaryFn.MaxLabels, summaryFn.MinScore = 10, 0.0
+	classifyFn := summaryFn.ClassifyFn
+	classifyFn.MaxLabels, classifyFn.MinScore = 3, 0.5
+
+	events := make(chan SummaryEvent)
+	go summaryFn.StreamSummary(context.Background(), "NBA", 5, "", classifyFn, events)
+	for range events {
+	}
+
+	storedSearches, err := summaryFn.store.Searches(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(storedSearches), 1)
+	assert.Equal(t, storedSearches[0].Query.SearchString, "NBA")
+	assert.Equal(t, storedSearches[0].Query.MaxLabels, 3)
+	assert.Equal(t, storedSearches[0].Query.MinScore, 0.5)
+}
+
+func TestSummaryLiveHandler(t *testing.T) {
+	summaryFn := &SummaryFn{}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryLiveHandler(recorder, httptest.NewRequest("GET", "/live?q=NBA&c=5", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "Live tweets with images for search `NBA`"))
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `new EventSource("/events?q=NBA\u0026c=5")`))
+}
+
+// Private
+
+func readEvents(t *testing.T, body string) []SummaryEvent {
+	events := []SummaryEvent{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	eventType := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event := SummaryEvent{}
+			assert.NilError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			assert.Equal(t, event.Type, eventType)
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,242 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// The fixtures shared by the tests of the package: the servers of the other
+// funcs and the summary data and tweets
+
+// Private
+
+// newSourceServer serves the body to the NBA and knative searches
+func newSourceServer(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		assert.Equal(t, query.Get("o"), "json")
+		if query.Get("q") != "#NBA" && query.Get("q") != "NBA" {
+			assert.Equal(t, query.Get("q"), "knative")
+		}
+		fmt.Fprint(writer, body)
+	}))
+}
+
+// newTestSummaryFn searches a twitter source serving three tweets, the first
+// with an image labelled ball and the last with an image that fails to
+// classify, and a broken source
+func newTestSummaryFn(t *testing.T) (*SummaryFn, func()) {
+	sourceServer := newSourceServer(t, `[
+		{"text": "t1", "image-urls": ["http://img/1.jpg"]},
+		{"text": "t2", "image-urls": []},
+		{"text": "t3", "image-urls": ["http://img/fail.jpg"]}
+	]`)
+
+	classifierServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		imageURL := request.URL.Query().Get("q")
+		if imageURL == "http://img/fail.jpg" {
+			http.Error(writer, "cannot classify", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(writer, `{"ImageURL": "%s", "labels": [{"name": "ball", "score": 0.9}]}`, imageURL)
+	}))
+
+	summaryFn := &SummaryFn{
+		WatsonFnURL: classifierServer.URL,
+		Sources: []ContentSource{
+			ContentSource{Name: "twitter", URL: sourceServer.URL},
+			ContentSource{Name: "broken", URL: "http://127.0.0.1:0"},
+		},
+	}
+
+	return summaryFn, func() {
+		sourceServer.Close()
+		classifierServer.Close()
+	}
+}
+
+// newCompareSummaryFn serves three images, the watson classifier labels them
+// ball and person, and the gvision classifier Ball and Sports, except for the
+// last image it cannot classify
+func newCompareSummaryFn(t *testing.T) (*SummaryFn, func()) {
+	sourceServer := newSourceServer(t, `[
+		{"id": "1", "text": "t1", "image-urls": ["http://img/1.jpg", "http://img/2.jpg"]},
+		{"id": "2", "text": "t2", "image-urls": ["http://img/fail.jpg"]}
+	]`)
+
+	watsonServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprintf(writer, `{"source_url": "%s", "classifiers": [{"classes": [{"class": "ball", "score": 0.9}, {"class": "person", "score": 0.7}]}]}`, request.URL.Query().Get("q"))
+	}))
+
+	gvisionServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, request.Header.Get("Authorization"), "Bearer gvision")
+		imageURL := request.URL.Query().Get("q")
+		if imageURL == "http://img/fail.jpg" {
+			http.Error(writer, "cannot detect labels", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(writer, `{"ImageURL": "%s", "Labels": [{"name": "Ball", "score": 0.95}, {"name": "Sports", "score": 0.8}]}`, imageURL)
+	}))
+
+	summaryFn := &SummaryFn{
+		Sources: []ContentSource{ContentSource{Name: "twitter", URL: sourceServer.URL}},
+		Classifiers: []ClassifierBackend{
+			ClassifierBackend{Name: "watson", URL: watsonServer.URL},
+			ClassifierBackend{Name: "gvision", Type: gvisionClassifier, URL: gvisionServer.URL, Headers: map[string]string{"Authorization": "Bearer gvision"}},
+		},
+	}
+	summaryFn.SearchString = "NBA"
+	summaryFn.Count = 10
+
+	return summaryFn, func() {
+		sourceServer.Close()
+		watsonServer.Close()
+		gvisionServer.Close()
+	}
+}
+
+// pollServer serves the tweets 11 and 12 to the first search, then the tweet
+// 13, whose first image is the image of the tweet 11, to the searches since 12
+type pollServer struct {
+	summaryFn *SummaryFn
+
+	mutex          sync.Mutex
+	sinceIDs       []string
+	classifyCount  int
+	sourceServer   *httptest.Server
+	classifyServer *httptest.Server
+}
+
+func newPollServer(t *testing.T) *pollServer {
+	pollServer := &pollServer{}
+
+	pollServer.sourceServer = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		sinceID := request.URL.Query().Get(sinceIDParam)
+
+		pollServer.mutex.Lock()
+		pollServer.sinceIDs = append(pollServer.sinceIDs, sinceID)
+		pollServer.mutex.Unlock()
+
+		switch sinceID {
+		case "":
+			fmt.Fprint(writer, `[
+				{"id": "12", "text": "t12", "image-urls": ["http://img/2.jpg"], "created-at": "2019-10-01T10:02:00Z"},
+				{"id": "11", "text": "t11", "image-urls": ["http://img/1.jpg"], "created-at": "2019-10-01T10:01:00Z"}
+			]`)
+		case "12":
+			fmt.Fprint(writer, `[
+				{"id": "13", "text": "t13", "image-urls": ["http://img/1.jpg", "http://img/3.jpg"], "created-at": "2019-10-01T10:03:00Z"}
+			]`)
+		default:
+			assert.Equal(t, sinceID, "13")
+			fmt.Fprint(writer, `[]`)
+		}
+	}))
+
+	pollServer.classifyServer = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		pollServer.mutex.Lock()
+		pollServer.classifyCount++
+		pollServer.mutex.Unlock()
+
+		fmt.Fprintf(writer, `{"ImageURL": "%s", "labels": [{"name": "ball", "score": 0.9}]}`, request.URL.Query().Get("q"))
+	}))
+
+	pollServer.summaryFn = &SummaryFn{
+		WatsonFnURL: pollServer.classifyServer.URL,
+		Sources:     []ContentSource{ContentSource{Name: "twitter", URL: pollServer.sourceServer.URL}},
+	}
+	pollServer.summaryFn.Count = 10
+	pollServer.summaryFn.Timeout = 5
+
+	return pollServer
+}
+
+func (pollServer *pollServer) lastSinceID() string {
+	pollServer.mutex.Lock()
+	defer pollServer.mutex.Unlock()
+	return pollServer.sinceIDs[len(pollServer.sinceIDs)-1]
+}
+
+func (pollServer *pollServer) searches() int {
+	pollServer.mutex.Lock()
+	defer pollServer.mutex.Unlock()
+	return len(pollServer.sinceIDs)
+}
+
+func (pollServer *pollServer) classifications() int {
+	pollServer.mutex.Lock()
+	defer pollServer.mutex.Unlock()
+	return pollServer.classifyCount
+}
+
+func (pollServer *pollServer) close() {
+	pollServer.sourceServer.Close()
+	pollServer.classifyServer.Close()
+}
+
+// newReportSummaryData has a tweet with a CSV quoted text and an image with
+// two labels
+func newReportSummaryData(imageURL string) SummaryData {
+	classifiedTweet := newClassifiedTweet("1", "2019-10-01T10:00:00Z", Label{Name: "ball", Score: 0.9}, Label{Name: "sport | game", Score: 0.5})
+	classifiedTweet.Text = "dunk, \"wow\""
+	classifiedTweet.ClassifiedImages[0].ImageURL = imageURL
+	classifiedTweets := []ClassifiedTweet{classifiedTweet}
+
+	return SummaryData{
+		Query:            SummaryQuery{SearchString: "NBA", Sources: []string{"twitter"}},
+		Stats:            computeStats(2, classifiedTweets),
+		ClassifiedTweets: classifiedTweets,
+		Errors:           []string{"broken: oops"},
+	}
+}
+
+// newStoreSummaryData has a ball tweet per id
+func newStoreSummaryData(searchString string, ids ...string) SummaryData {
+	summaryData := SummaryData{
+		Query:            SummaryQuery{SearchString: searchString, Count: 5, View: &ViewOptions{View: tableView}},
+		Stats:            SummaryStats{TweetCount: 5},
+		ClassifiedTweets: []ClassifiedTweet{},
+		Errors:           []string{"broken: unavailable"},
+	}
+
+	for _, id := range ids {
+		summaryData.ClassifiedTweets = append(summaryData.ClassifiedTweets, newBallTweet(id))
+	}
+	return summaryData
+}
+
+// newClassifiedTweet is a twitter tweet whose ID and text are the id, with a
+// single `http://img/<id>.jpg` image with the labels
+func newClassifiedTweet(id string, createdAt string, labels ...Label) ClassifiedTweet {
+	return ClassifiedTweet{
+		ID:               id,
+		Text:             id,
+		Source:           "twitter",
+		CreatedAt:        createdAt,
+		ClassifiedImages: []ClassifiedImage{ClassifiedImage{ImageURL: "http://img/" + id + ".jpg", Labels: labels}},
+	}
+}
+
+// newBallTweet is a tweet whose image is labelled ball
+func newBallTweet(id string) ClassifiedTweet {
+	return newClassifiedTweet(id, "", Label{Name: "ball", Score: 0.9})
+}
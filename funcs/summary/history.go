@@ -0,0 +1,132 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const defaultHistoryLimit = 50
+
+var errSearchNotFound = errors.New("search not found")
+
+type HistoryPageData struct {
+	PageTitle string
+	Searches  StoredSearches
+}
+
+// HistoryHandler lists the stored searches at `/history` and re-renders the
+// summary of one of them at `/history/ID`, without searching or classifying
+func (summaryFn *SummaryFn) HistoryHandler(writer http.ResponseWriter, request *http.Request) {
+	if summaryFn.store == nil {
+		http.Error(writer, "history is disabled, start summary-fn with a --store-backend", http.StatusNotFound)
+		return
+	}
+
+	output := summaryFn.NegotiateOutput(request, "html")
+	searchID := strings.Trim(strings.TrimPrefix(request.URL.Path, "/history"), "/")
+	log.Printf("SummaryFn.History: id=\"%s\", o=\"%s\"", searchID, output)
+
+	if searchID != "" {
+		_, summaryData, err := summaryFn.store.LoadSearch(searchID)
+		if err != nil {
+			log.Printf("Error loading search '%s': %s\n", searchID, err.Error())
+			http.Error(writer, err.Error(), storeErrorStatus(err))
+			return
+		}
+
+		summaryFn.writeSummary(writer, request, http.StatusOK, output, summaryFn.viewQueryParams(request).Apply(summaryData))
+		return
+	}
+
+	limit := summaryFn.ExtractQueryIntParam(request, []string{"limit"}, defaultHistoryLimit)
+	storedSearches, err := summaryFn.store.Searches(limit)
+	if err != nil {
+		log.Printf("Error listing searches: %s\n", err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if output != "html" {
+		common.RespondOutput(writer, http.StatusOK, &storedSearches, output, storedSearches.ToText)
+		return
+	}
+
+	writer.Header().Add("Content-Type", common.OutputContentType("html"))
+	err = summaryFn.summaryTemplates().Execute(writer, historyLayoutTemplate, HistoryPageData{
+		PageTitle: "Past searches",
+		Searches:  storedSearches,
+	})
+	if err != nil {
+		log.Printf("Error executing history template: %s\n", err.Error())
+	}
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) openStore() error {
+	if summaryFn.StoreBackend == "" || summaryFn.store != nil {
+		return nil
+	}
+
+	store, err := NewStore(summaryFn.StoreBackend, summaryFn.StorePath)
+	if err != nil {
+		return err
+	}
+
+	summaryFn.store = store
+	return nil
+}
+
+func (summaryFn *SummaryFn) closeStore() {
+	if summaryFn.store == nil {
+		return
+	}
+
+	err := summaryFn.store.Close()
+	if err != nil {
+		log.Printf("Error closing store: %s\n", err.Error())
+	}
+	summaryFn.store = nil
+}
+
+// saveSummary only logs the storage errors since the history is optional
+func (summaryFn *SummaryFn) saveSummary(summaryData SummaryData) {
+	if summaryFn.store == nil {
+		return
+	}
+
+	storedSearch, err := summaryFn.store.SaveSearch(time.Now(), summaryData)
+	if err != nil {
+		log.Printf("Error saving summary: %s\n", err.Error())
+		return
+	}
+	log.Printf("Saved summary as search '%s'", storedSearch.ID)
+}
+
+// Private functions
+
+func storeErrorStatus(err error) int {
+	if errors.Is(err, errSearchNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,569 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+
+	defaultJobWorkers      = 2
+	defaultJobQueue        = 100
+	defaultCallbackTimeout = 10
+
+	// maxFinishedJobs bounds the finished jobs kept for `GET /jobs/ID`, the
+	// oldest are forgotten first
+	maxFinishedJobs = 100
+)
+
+var (
+	errJobNotFound  = errors.New("job not found")
+	errJobQueueFull = errors.New("job queue is full, try again later")
+	errJobFinished  = errors.New("job is already finished")
+
+	errJobCallbacksDisabled = errors.New("job callbacks are disabled, start summary-fn with --job-callback-hosts")
+)
+
+// Job is the status of a summary job with its progress and the partial
+// summary of the images classified so far
+type Job struct {
+	ID          string       `yaml:"id" json:"id"`
+	Status      string       `yaml:"status" json:"status"`
+	CallbackURL string       `yaml:"callback-url,omitempty" json:"callback-url,omitempty"`
+	CreatedAt   time.Time    `yaml:"created-at" json:"created-at"`
+	StartedAt   *time.Time   `yaml:"started-at,omitempty" json:"started-at,omitempty"`
+	FinishedAt  *time.Time   `yaml:"finished-at,omitempty" json:"finished-at,omitempty"`
+	Progress    JobProgress  `yaml:"progress" json:"progress"`
+	Summary     *SummaryData `yaml:"summary,omitempty" json:"summary,omitempty"`
+	Error       string       `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+type JobProgress struct {
+	TweetsWithImages int `yaml:"tweets-with-images" json:"tweets-with-images"`
+	Images           int `yaml:"images" json:"images"`
+	ClassifiedImages int `yaml:"classified-images" json:"classified-images"`
+	FailedImages     int `yaml:"failed-images" json:"failed-images"`
+}
+
+type Jobs []Job
+
+// JobManager runs the summary jobs with a bounded number of workers, the
+// submitted jobs wait in a bounded queue
+type JobManager struct {
+	Workers   int
+	QueueSize int
+
+	summaryFn *SummaryFn
+
+	mutex sync.Mutex
+	jobs  map[string]*summaryJob
+	ids   []string
+	queue chan *summaryJob
+}
+
+// summaryJob has its own copy of the query and classify options of the request
+// that submitted it
+type summaryJob struct {
+	job        Job
+	query      SummaryQuery
+	classifyFn common.ClassifyFn
+	timeout    int
+	cancel     context.CancelFunc
+
+	tweets           []Tweet
+	classifiedImages [][]*ClassifiedImage
+	analyses         []*TextAnalysis
+	stats            *SummaryStats
+	errors           []string
+}
+
+// NewJobManager runs the jobs with the content sources and classifier of the
+// summaryFn once Run is called
+func NewJobManager(summaryFn *SummaryFn, workers int, queueSize int) *JobManager {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &JobManager{
+		Workers:   workers,
+		QueueSize: queueSize,
+		summaryFn: summaryFn,
+		jobs:      map[string]*summaryJob{},
+		queue:     make(chan *summaryJob, queueSize),
+	}
+}
+
+// Run starts the workers and cancels the running jobs when the context is done
+func (manager *JobManager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < manager.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-manager.queue:
+					manager.run(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Submit queues a summary job for the query, the callback URL, when set, is
+// POSTed the finished job
+func (manager *JobManager) Submit(query SummaryQuery, classifyFn common.ClassifyFn, callbackURL string) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &summaryJob{
+		job: Job{
+			ID:          id,
+			Status:      jobQueued,
+			CallbackURL: callbackURL,
+			CreatedAt:   time.Now().UTC(),
+		},
+		query:      query,
+		classifyFn: classifyFn,
+		timeout:    manager.callbackTimeout(),
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	select {
+	case manager.queue <- job:
+	default:
+		return Job{}, errJobQueueFull
+	}
+
+	manager.jobs[id] = job
+	manager.ids = append(manager.ids, id)
+	manager.prune()
+
+	return job.snapshot(), nil
+}
+
+func (manager *JobManager) Job(id string) (Job, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	job, ok := manager.jobs[id]
+	if !ok {
+		return Job{}, errJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// Jobs returns all the jobs, most recent first, without their summaries
+func (manager *JobManager) Jobs() Jobs {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	jobs := Jobs{}
+	for i := len(manager.ids) - 1; i >= 0; i-- {
+		job := manager.jobs[manager.ids[i]].snapshot()
+		job.Summary = nil
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// Cancel stops a running job, keeping its partial summary, or removes a
+// queued job from the queue
+func (manager *JobManager) Cancel(id string) (Job, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	job, ok := manager.jobs[id]
+	if !ok {
+		return Job{}, errJobNotFound
+	}
+
+	switch job.job.Status {
+	case jobQueued:
+		job.finish(jobCancelled, "")
+	case jobRunning:
+		job.finish(jobCancelled, "")
+		job.cancel()
+	default:
+		return job.snapshot(), errJobFinished
+	}
+	return job.snapshot(), nil
+}
+
+// JobsHandler starts a summary job with `POST /jobs` and the same query
+// parameters as the summary, plus an optional `callback` URL on one of the
+// --job-callback-hosts, lists the jobs
+// with `GET /jobs`, returns a job with its progress and partial summary with
+// `GET /jobs/ID`, and cancels it with `DELETE /jobs/ID`
+func (summaryFn *SummaryFn) JobsHandler(writer http.ResponseWriter, request *http.Request) {
+	if summaryFn.jobs == nil {
+		http.Error(writer, "jobs are disabled, start summary-fn with --async", http.StatusNotFound)
+		return
+	}
+
+	output := summaryFn.NegotiateOutput(request, "json")
+	if output == "html" {
+		output = "json"
+	}
+
+	jobID := strings.Trim(strings.TrimPrefix(request.URL.Path, "/jobs"), "/")
+	log.Printf("SummaryFn.Jobs: %s id=\"%s\", o=\"%s\"", request.Method, jobID, output)
+
+	if jobID == "" {
+		switch request.Method {
+		case http.MethodPost:
+			summaryFn.submitJob(writer, request, output)
+		case http.MethodGet:
+			jobs := summaryFn.jobs.Jobs()
+			common.RespondOutput(writer, http.StatusOK, &jobs, output, jobs.ToText)
+		default:
+			writer.Header().Set("Allow", "GET, POST")
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	var (
+		job Job
+		err error
+	)
+	switch request.Method {
+	case http.MethodGet:
+		job, err = summaryFn.jobs.Job(jobID)
+	case http.MethodDelete:
+		job, err = summaryFn.jobs.Cancel(jobID)
+	default:
+		writer.Header().Set("Allow", "GET, DELETE")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error with job '%s': %s\n", jobID, err.Error())
+		http.Error(writer, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	writeJob(writer, http.StatusOK, output, job)
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) submitJob(writer http.ResponseWriter, request *http.Request, output string) {
+	commonFn := summaryFn.CommonFn
+	commonFn.InitCommonQueryParams(request)
+	classifyFn := summaryFn.ClassifyFn
+	classifyFn.InitClassifyQueryParams(request)
+
+	if commonFn.SearchString == "" {
+		http.Error(writer, "you must pass a `q` search string", http.StatusBadRequest)
+		return
+	}
+
+	classifier, ok := summaryFn.classifierQueryParam(writer, request)
+	if !ok {
+		return
+	}
+
+	callbackURL := summaryFn.ExtractQueryStringParam(request, []string{"callback", "callback-url"}, "")
+	if callbackURL != "" {
+		err := summaryFn.validateCallbackURL(callbackURL)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := summaryFn.newSummaryQuery(commonFn.SearchString, commonFn.Count, classifier, classifyFn)
+	job, err := summaryFn.jobs.Submit(query, classifyFn, callbackURL)
+	if err != nil {
+		log.Printf("Error submitting job: %s\n", err.Error())
+		http.Error(writer, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	writer.Header().Set("Location", "/jobs/"+job.ID)
+	writeJob(writer, http.StatusAccepted, output, job)
+}
+
+// validateCallbackURL only accepts the http(s) URLs of the --job-callback-hosts
+// so that the jobs cannot be used to POST to the other hosts of the network
+func (summaryFn *SummaryFn) validateCallbackURL(callbackURL string) error {
+	if len(summaryFn.JobCallbackHosts) == 0 {
+		return errJobCallbacksDisabled
+	}
+
+	err := common.ValidateHTTPURL(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback: %s", err.Error())
+	}
+
+	parsedURL, _ := url.Parse(callbackURL)
+	for _, host := range summaryFn.JobCallbackHosts {
+		if strings.EqualFold(host, parsedURL.Host) || strings.EqualFold(host, parsedURL.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the callback host '%s' is not one of the --job-callback-hosts", parsedURL.Host)
+}
+
+// Private JobManager
+
+func (manager *JobManager) run(ctx context.Context, job *summaryJob) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	manager.mutex.Lock()
+	if job.job.Status != jobQueued {
+		manager.mutex.Unlock()
+		return
+	}
+	startedAt := time.Now().UTC()
+	job.job.Status, job.job.StartedAt, job.cancel = jobRunning, &startedAt, cancel
+	manager.mutex.Unlock()
+
+	events := make(chan SummaryEvent)
+	go manager.summaryFn.StreamSummary(ctx, job.query.SearchString, job.query.Count, job.query.Classifier, job.classifyFn, events)
+
+	for event := range events {
+		manager.mutex.Lock()
+		job.apply(event)
+		manager.mutex.Unlock()
+	}
+
+	manager.mutex.Lock()
+	switch {
+	case job.job.Status != jobRunning:
+		// cancelled
+	case ctx.Err() != nil:
+		job.finish(jobCancelled, "")
+	case job.stats == nil:
+		job.finish(jobFailed, strings.Join(job.errors, "\n"))
+	default:
+		job.finish(jobDone, "")
+	}
+	finished := job.snapshot()
+	manager.mutex.Unlock()
+
+	log.Printf("Job '%s' %s", finished.ID, finished.Status)
+	if finished.CallbackURL == "" {
+		return
+	}
+
+	err := postJob(finished.CallbackURL, finished, job.timeout)
+	if err != nil {
+		log.Printf("Error calling back '%s' for job '%s': %s\n", finished.CallbackURL, finished.ID, err.Error())
+	}
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs
+func (manager *JobManager) prune() {
+	finished := 0
+	for _, id := range manager.ids {
+		if manager.jobs[id].job.FinishedAt != nil {
+			finished++
+		}
+	}
+
+	ids := []string{}
+	for _, id := range manager.ids {
+		if finished > maxFinishedJobs && manager.jobs[id].job.FinishedAt != nil {
+			delete(manager.jobs, id)
+			finished--
+			continue
+		}
+		ids = append(ids, id)
+	}
+	manager.ids = ids
+}
+
+// callbackTimeout is the timeout in seconds of the job callbacks, or
+// defaultCallbackTimeout when not set
+func (manager *JobManager) callbackTimeout() int {
+	if manager.summaryFn.Timeout > 0 {
+		return manager.summaryFn.Timeout
+	}
+	return defaultCallbackTimeout
+}
+
+// Private summaryJob
+
+func (job *summaryJob) apply(event SummaryEvent) {
+	switch event.Type {
+	case tweetEvent:
+		job.tweets = append(job.tweets, *event.Tweet)
+		job.classifiedImages = append(job.classifiedImages, make([]*ClassifiedImage, len(event.Tweet.ImageURLs)))
+		job.analyses = append(job.analyses, nil)
+		job.job.Progress.TweetsWithImages++
+		job.job.Progress.Images += len(event.Tweet.ImageURLs)
+	case classificationEvent:
+		job.classifiedImages[event.TweetIndex][event.ImageIndex] = event.ClassifiedImage
+		job.job.Progress.ClassifiedImages++
+	case analysisEvent:
+		job.analyses[event.TweetIndex] = event.Analysis
+	case errorEvent:
+		job.errors = append(job.errors, event.Error)
+		if event.ImageIndex >= 0 {
+			job.job.Progress.FailedImages++
+		}
+	case statsEvent:
+		job.stats = event.Stats
+	}
+}
+
+func (job *summaryJob) finish(status string, errorMessage string) {
+	finishedAt := time.Now().UTC()
+	job.job.Status, job.job.FinishedAt, job.job.Error = status, &finishedAt, errorMessage
+}
+
+func (job *summaryJob) snapshot() Job {
+	snapshot := job.job
+	if job.job.Status == jobQueued {
+		return snapshot
+	}
+
+	classifiedTweets := []ClassifiedTweet{}
+	for i, tweet := range job.tweets {
+		classifiedTweet := ClassifiedTweet{ID: tweet.ID, Text: tweet.Text, Source: tweet.Source, CreatedAt: tweet.CreatedAt, Analysis: job.analyses[i]}
+		for _, classifiedImage := range job.classifiedImages[i] {
+			if classifiedImage != nil {
+				classifiedTweet.ClassifiedImages = append(classifiedTweet.ClassifiedImages, *classifiedImage)
+			}
+		}
+
+		if len(classifiedTweet.ClassifiedImages) > 0 {
+			classifiedTweets = append(classifiedTweets, classifiedTweet)
+		}
+	}
+
+	stats := computeStats(len(job.tweets), classifiedTweets)
+	if job.stats != nil {
+		stats = *job.stats
+	}
+
+	snapshot.Summary = &SummaryData{
+		Query:            job.query,
+		Stats:            stats,
+		ClassifiedTweets: classifiedTweets,
+		Errors:           append([]string{}, job.errors...),
+	}
+	return snapshot
+}
+
+// Private functions
+
+func writeJob(writer http.ResponseWriter, status int, output string, job Job) {
+	common.RespondOutput(writer, status, &job, output, job.ToText)
+}
+
+func postJob(callbackURL string, job Job, timeout int) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	callbackClient := http.Client{
+		Timeout: time.Second * time.Duration(timeout),
+		// redirects could lead to hosts that are not allowed
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := callbackClient.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("callback responded with %s", res.Status)
+	}
+	return nil
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func jobErrorStatus(err error) int {
+	switch err {
+	case errJobNotFound:
+		return http.StatusNotFound
+	case errJobQueueFull:
+		return http.StatusServiceUnavailable
+	case errJobFinished:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Job
+
+func (job Job) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("⚙️  job %s %s: %d of %d images classified, %d failed\n",
+		job.ID, job.Status, job.Progress.ClassifiedImages, job.Progress.Images, job.Progress.FailedImages))
+	if job.Error != "" {
+		sb.WriteString(fmt.Sprintf("⚠️  %s\n", job.Error))
+	}
+	if job.Summary != nil {
+		sb.WriteString(job.Summary.ToText(job.Summary))
+	}
+	return sb.String()
+}
+
+// Jobs
+
+func (jobs Jobs) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, job := range jobs {
+		sb.WriteString(job.ToText(job))
+	}
+	return sb.String()
+}
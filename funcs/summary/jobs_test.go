@@ -0,0 +1,227 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestJobManagerRunsJobs(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	callbacks := make(chan Job, 1)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		job := Job{}
+		assert.NilError(t, json.NewDecoder(request.Body).Decode(&job))
+		callbacks <- job
+	}))
+	defer callbackServer.Close()
+
+	manager := NewJobManager(pollServer.summaryFn, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+
+	job, err := manager.Submit(SummaryQuery{SearchString: "NBA", Count: 10}, pollServer.summaryFn.ClassifyFn, callbackServer.URL)
+	assert.NilError(t, err)
+	assert.Equal(t, job.Status, jobQueued)
+
+	job = waitForJob(t, manager, job.ID)
+	assert.Equal(t, job.Status, jobDone)
+	assert.Equal(t, job.Progress, JobProgress{TweetsWithImages: 2, Images: 2, ClassifiedImages: 2})
+	assert.Equal(t, len(job.Summary.ClassifiedTweets), 2)
+	assert.Equal(t, job.Summary.Stats.ImageCount, 2)
+
+	callbackJob := <-callbacks
+	assert.Equal(t, callbackJob.ID, job.ID)
+	assert.Equal(t, callbackJob.Status, jobDone)
+}
+
+func TestJobManagerFailedJob(t *testing.T) {
+	summaryFn := &SummaryFn{Sources: []ContentSource{ContentSource{Name: "twitter", URL: "http://127.0.0.1:0"}}}
+	summaryFn.Timeout = 1
+
+	manager := NewJobManager(summaryFn, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+
+	job, err := manager.Submit(SummaryQuery{SearchString: "NBA", Count: 10}, summaryFn.ClassifyFn, "")
+	assert.NilError(t, err)
+
+	job = waitForJob(t, manager, job.ID)
+	assert.Equal(t, job.Status, jobFailed)
+	assert.Assert(t, job.Error != "")
+}
+
+func TestJobManagerCancelAndQueue(t *testing.T) {
+	manager := NewJobManager(&SummaryFn{}, 1, 1)
+
+	job, err := manager.Submit(SummaryQuery{SearchString: "NBA"}, manager.summaryFn.ClassifyFn, "")
+	assert.NilError(t, err)
+
+	_, err = manager.Submit(SummaryQuery{SearchString: "NFL"}, manager.summaryFn.ClassifyFn, "")
+	assert.Equal(t, err, errJobQueueFull)
+
+	job, err = manager.Cancel(job.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, job.Status, jobCancelled)
+
+	_, err = manager.Cancel(job.ID)
+	assert.Equal(t, err, errJobFinished)
+
+	_, err = manager.Job("unknown")
+	assert.Equal(t, err, errJobNotFound)
+	assert.Equal(t, len(manager.Jobs()), 1)
+}
+
+func TestJobManagerCallbackTimeout(t *testing.T) {
+	manager := NewJobManager(&SummaryFn{}, 1, 10)
+	assert.Equal(t, manager.callbackTimeout(), defaultCallbackTimeout)
+
+	job, err := manager.Submit(SummaryQuery{SearchString: "NBA"}, manager.summaryFn.ClassifyFn, "")
+	assert.NilError(t, err)
+	assert.Equal(t, manager.jobs[job.ID].timeout, defaultCallbackTimeout)
+
+	manager.summaryFn.Timeout = 5
+	assert.Equal(t, manager.callbackTimeout(), 5)
+}
+
+func TestJobManagerCancelRunningJob(t *testing.T) {
+	searching, searchCancelled := make(chan bool), make(chan bool)
+	sourceServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		searching <- true
+		<-request.Context().Done()
+		searchCancelled <- true
+	}))
+	defer sourceServer.Close()
+
+	summaryFn := &SummaryFn{Sources: []ContentSource{ContentSource{Name: "twitter", URL: sourceServer.URL}}}
+	summaryFn.Timeout = 10
+
+	manager := NewJobManager(summaryFn, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+
+	job, err := manager.Submit(SummaryQuery{SearchString: "NBA", Count: 10}, summaryFn.ClassifyFn, "")
+	assert.NilError(t, err)
+
+	<-searching
+	job, err = manager.Cancel(job.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, job.Status, jobCancelled)
+
+	select {
+	case <-searchCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the search of the cancelled job was not cancelled")
+	}
+}
+
+func TestJobsHandler(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	summaryFn := pollServer.summaryFn
+	recorder := httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("POST", "/jobs?q=NBA", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	summaryFn.jobs = NewJobManager(summaryFn, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go summaryFn.jobs.Run(ctx)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("POST", "/jobs?q=NBA&max-labels=3", nil))
+	assert.Equal(t, recorder.Code, http.StatusAccepted)
+
+	// the job has its own copy of the query parameters
+	assert.Equal(t, summaryFn.SearchString, "")
+	assert.Equal(t, summaryFn.MaxLabels, 0)
+
+	job := Job{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	assert.Equal(t, recorder.Header().Get("Location"), "/jobs/"+job.ID)
+
+	waitForJob(t, summaryFn.jobs, job.ID)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("GET", "/jobs/"+job.ID, nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	assert.Equal(t, job.Status, jobDone)
+	assert.Equal(t, job.Summary.Query.MaxLabels, 3)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("DELETE", "/jobs/"+job.ID, nil))
+	assert.Equal(t, recorder.Code, http.StatusConflict)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("GET", "/jobs/unknown", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestJobsHandlerCallbacks(t *testing.T) {
+	summaryFn := &SummaryFn{jobs: NewJobManager(&SummaryFn{}, 1, 10)}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.JobsHandler(recorder, httptest.NewRequest("POST", "/jobs?q=NBA&callback=http://localhost:9000/done", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Equal(t, recorder.Body.String(), errJobCallbacksDisabled.Error()+"\n")
+
+	summaryFn.JobCallbackHosts = []string{"localhost:9000", "hooks.example.com"}
+	for _, tc := range []struct {
+		callbackURL string
+		status      int
+	}{
+		{"http://localhost:9000/done", http.StatusAccepted},
+		{"https://HOOKS.example.com:8443/done", http.StatusAccepted},
+		{"http://localhost:9001/done", http.StatusBadRequest},
+		{"http://169.254.169.254/latest/meta-data", http.StatusBadRequest},
+		{"file:///etc/passwd", http.StatusBadRequest},
+	} {
+		recorder = httptest.NewRecorder()
+		summaryFn.JobsHandler(recorder, httptest.NewRequest("POST", "/jobs?q=NBA&callback="+url.QueryEscape(tc.callbackURL), nil))
+		assert.Equal(t, recorder.Code, tc.status, tc.callbackURL)
+	}
+}
+
+// Private
+
+func waitForJob(t *testing.T, manager *JobManager, id string) Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := manager.Job(id)
+		assert.NilError(t, err)
+		if job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job '%s' did not finish", id)
+	return Job{}
+}
@@ -0,0 +1,772 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	defaultPollWindow      = 100
+	defaultPollMaxSearches = 20
+	pollEventsBuffer       = 256
+
+	// sinceIDParam passes the newest tweet ID of the previous poll to each
+	// source, the tweets of sources ignoring it are deduplicated instead
+	sinceIDParam = "since-id"
+)
+
+var errTooManyPolledSearches = errors.New("too many polled searches, try again later or start summary-fn with a larger --poll-max-searches")
+
+// Poller watches search strings: each poll only asks the sources for the
+// tweets newer than the previous poll, only classifies the images it has not
+// classified yet, and keeps a rolling window of the most recent tweets. The
+// searches of the clients, unlike the watched ones, are limited to MaxSearches
+// and the least recently requested one without subscribers is dropped first
+type Poller struct {
+	Window      int
+	Count       int
+	MaxSearches int
+	ClassifyFn  common.ClassifyFn
+
+	summaryFn *SummaryFn
+
+	pollMutex   sync.Mutex
+	mutex       sync.Mutex
+	searches    []string
+	watches     map[string]*pollWatch
+	subscribers map[chan SummaryEvent]string
+}
+
+type PollResult struct {
+	SearchString string    `yaml:"search-string" json:"search-string"`
+	PolledAt     time.Time `yaml:"polled-at" json:"polled-at"`
+	NewTweets    int       `yaml:"new-tweets" json:"new-tweets"`
+	NewImages    int       `yaml:"new-images" json:"new-images"`
+	WindowTweets int       `yaml:"window-tweets" json:"window-tweets"`
+	Errors       []string  `yaml:"errors,omitempty" json:"errors,omitempty"`
+}
+
+type PollResults []PollResult
+
+type polledTweet struct {
+	tweet Tweet
+	key   string
+	index int
+}
+
+type pollWatch struct {
+	searchString     string
+	sinceIDs         map[string]string
+	tweets           []polledTweet
+	classifiedImages map[string]ClassifiedImage
+	analyses         map[string]*TextAnalysis
+	errors           []string
+	polledAt         time.Time
+	nextIndex        int
+
+	// initialPoll is set once a subscriber started the first poll
+	initialPoll bool
+
+	// watched searches are never dropped, requestedAt orders the others
+	watched     bool
+	requestedAt time.Time
+}
+
+// NewPoller polls with the count, classification and max searches settings of
+// the summaryFn and keeps the window most recent tweets of each search, 0 for
+// all
+func NewPoller(summaryFn *SummaryFn, window int, searchStrings []string) *Poller {
+	poller := &Poller{
+		Window:      window,
+		Count:       summaryFn.Count,
+		MaxSearches: summaryFn.PollMaxSearches,
+		ClassifyFn:  summaryFn.ClassifyFn,
+		summaryFn:   summaryFn,
+		watches:     map[string]*pollWatch{},
+		subscribers: map[chan SummaryEvent]string{},
+	}
+
+	for _, searchString := range searchStrings {
+		poller.Watch(searchString)
+	}
+	return poller
+}
+
+// Watch adds the search string to the polled searches without polling it, it
+// is polled until the poller stops
+func (poller *Poller) Watch(searchString string) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+	poller.watch(searchString, true)
+}
+
+func (poller *Poller) Searches() []string {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+	return append([]string{}, poller.searches...)
+}
+
+// Run polls all the watched searches, then again every interval until the
+// context is done
+func (poller *Poller) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		poller.PollAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (poller *Poller) PollAll(ctx context.Context) PollResults {
+	poller.mutex.Lock()
+	watches := []*pollWatch{}
+	for _, searchString := range poller.searches {
+		watches = append(watches, poller.watches[searchString])
+	}
+	poller.mutex.Unlock()
+
+	results := PollResults{}
+	for _, watch := range watches {
+		results = append(results, poller.poll(ctx, watch))
+	}
+	return results
+}
+
+// Poll collects the new tweets of the search string, which is then polled by
+// the next polls, classifies their new images and sends them to the
+// subscribers. The errors are part of the result since the next poll may
+// succeed, the error is errTooManyPolledSearches
+func (poller *Poller) Poll(ctx context.Context, searchString string) (PollResult, error) {
+	poller.mutex.Lock()
+	watch, err := poller.watch(searchString, false)
+	poller.mutex.Unlock()
+	if err != nil {
+		return PollResult{}, err
+	}
+
+	return poller.poll(ctx, watch), nil
+}
+
+// Summary returns the summary of the rolling window of a polled search
+func (poller *Poller) Summary(searchString string) (SummaryData, bool) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+
+	watch, ok := poller.watches[searchString]
+	if !ok || watch.polledAt.IsZero() {
+		return SummaryData{}, false
+	}
+	return poller.summaryData(watch), true
+}
+
+// Subscribe returns the events replaying the current window of the search
+// string, then the channel of the events of its next polls. A search that was
+// never polled is polled in the background with the context, so that the
+// subscriber gets its window without waiting for the next poll. The
+// subscriber must call unsubscribe when done
+func (poller *Poller) Subscribe(ctx context.Context, searchString string) ([]SummaryEvent, <-chan SummaryEvent, func(), error) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+
+	watch, err := poller.watch(searchString, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	replay := []SummaryEvent{}
+	if !watch.polledAt.IsZero() {
+		replay = watch.events(watch.tweets, poller.summaryData(watch))
+	} else if !watch.initialPoll {
+		watch.initialPoll = true
+		go poller.poll(ctx, watch)
+	}
+
+	events := make(chan SummaryEvent, pollEventsBuffer)
+	poller.subscribers[events] = searchString
+
+	return replay, events, func() {
+		poller.mutex.Lock()
+		defer poller.mutex.Unlock()
+		delete(poller.subscribers, events)
+		close(events)
+	}, nil
+}
+
+// PollHandler polls all the watched searches once, or only the `q` search
+// which is then watched, e.g. when invoked by a Knative PingSource
+func (summaryFn *SummaryFn) PollHandler(writer http.ResponseWriter, request *http.Request) {
+	if summaryFn.poller == nil {
+		http.Error(writer, "polling is disabled, start summary-fn as a server", http.StatusNotFound)
+		return
+	}
+
+	output := summaryFn.NegotiateOutput(request, "json")
+	searchString := summaryFn.ExtractQueryStringParam(request, []string{"q", "query", "search-string", "s"}, "")
+	log.Printf("SummaryFn.Poll: s=\"%s\", o=\"%s\"", searchString, output)
+
+	pollResults := PollResults{}
+	if searchString != "" {
+		pollResult, err := summaryFn.poller.Poll(request.Context(), searchString)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		pollResults = append(pollResults, pollResult)
+	} else {
+		pollResults = summaryFn.poller.PollAll(request.Context())
+	}
+
+	if len(pollResults) == 0 {
+		http.Error(writer, "no watched searches, pass a `q` search string or start summary-fn with --watch", http.StatusBadRequest)
+		return
+	}
+
+	if output == "html" {
+		output = "json"
+	}
+	common.RespondOutput(writer, http.StatusOK, &pollResults, output, pollResults.ToText)
+}
+
+// PollEventsHandler streams the window of the `q` polled search, then the
+// tweets and classifications of each next poll, as Server-Sent Events
+func (summaryFn *SummaryFn) PollEventsHandler(writer http.ResponseWriter, request *http.Request) {
+	if summaryFn.poller == nil {
+		http.Error(writer, "polling is disabled, start summary-fn as a server", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	searchString := summaryFn.ExtractQueryStringParam(request, []string{"q", "query", "search-string", "s"}, summaryFn.SearchString)
+	log.Printf("SummaryFn.PollEvents: s=\"%s\"", searchString)
+
+	replay, events, unsubscribe, err := summaryFn.poller.Subscribe(request.Context(), searchString)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer unsubscribe()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	for _, event := range replay {
+		err := writeEvent(writer, event)
+		if err != nil {
+			log.Printf("Error writing poll event: %s\n", err.Error())
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event := <-events:
+			err := writeEvent(writer, event)
+			if err != nil {
+				log.Printf("Error writing poll event: %s\n", err.Error())
+				continue
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Private SummaryFn
+
+// polledSummary returns the window of the search string when it is polled so
+// the summary page does not search and classify again
+func (summaryFn *SummaryFn) polledSummary(searchString string) (SummaryData, bool) {
+	if summaryFn.poller == nil {
+		return SummaryData{}, false
+	}
+	return summaryFn.poller.Summary(searchString)
+}
+
+// Private Poller
+
+// poll runs one poll at a time since concurrent polls of a search would fetch
+// and classify the same new tweets
+func (poller *Poller) poll(ctx context.Context, watch *pollWatch) PollResult {
+	poller.pollMutex.Lock()
+	defer poller.pollMutex.Unlock()
+
+	searchString := watch.searchString
+	result := PollResult{SearchString: searchString, PolledAt: time.Now().UTC(), Errors: []string{}}
+
+	poller.mutex.Lock()
+	sources := watch.sinceSources(poller.summaryFn.contentSources())
+	poller.mutex.Unlock()
+
+	tweets, errorMessages, err := poller.summaryFn.collectSourcesTweets(ctx, sources, searchString, poller.Count)
+	result.Errors = append(result.Errors, errorMessages...)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	poller.mutex.Lock()
+	newTweets := watch.newTweets(tweets)
+	imageURLs := watch.unclassifiedImageURLs(newTweets)
+	poller.mutex.Unlock()
+
+	classifiedImages, errorMessages := poller.classifyImages(ctx, imageURLs)
+	result.Errors = append(result.Errors, errorMessages...)
+	analyses := poller.analyzeTweets(ctx, newTweets)
+
+	poller.mutex.Lock()
+	for imageURL, classifiedImage := range classifiedImages {
+		watch.classifiedImages[imageURL] = classifiedImage
+	}
+	for key, analysis := range analyses {
+		watch.analyses[key] = analysis
+	}
+
+	watch.tweets = append(newTweets, watch.tweets...)
+	if poller.Window > 0 && len(watch.tweets) > poller.Window {
+		watch.tweets = watch.tweets[:poller.Window]
+	}
+	watch.prune()
+	watch.updateSinceIDs(tweets)
+	watch.errors = result.Errors
+	watch.polledAt = result.PolledAt
+
+	result.NewTweets = len(newTweets)
+	result.NewImages = len(classifiedImages)
+	result.WindowTweets = len(watch.tweets)
+
+	newClassifiedTweets := watch.classifiedTweets(newTweets)
+	events := watch.events(newTweets, poller.summaryData(watch))
+	poller.broadcast(searchString, events)
+	poller.mutex.Unlock()
+
+	log.Printf("Polled search '%s': %d new tweets, %d new images, %d errors", searchString, result.NewTweets, result.NewImages, len(result.Errors))
+
+	if len(newClassifiedTweets) > 0 {
+		query := poller.summaryQuery(searchString)
+		poller.summaryFn.summarized(SummaryData{
+			Query:            query,
+			Stats:            computeStats(len(newTweets), newClassifiedTweets),
+			ClassifiedTweets: newClassifiedTweets,
+			Errors:           result.Errors,
+		})
+	}
+
+	return result
+}
+
+// watch must be called with the mutex locked, it drops the least recently
+// requested search without subscribers when the clients reached MaxSearches
+func (poller *Poller) watch(searchString string, watched bool) (*pollWatch, error) {
+	watch, ok := poller.watches[searchString]
+	if !ok {
+		if !watched && poller.MaxSearches > 0 && poller.requestedSearches() >= poller.MaxSearches && !poller.dropSearch() {
+			return nil, errTooManyPolledSearches
+		}
+
+		watch = &pollWatch{
+			searchString:     searchString,
+			sinceIDs:         map[string]string{},
+			tweets:           []polledTweet{},
+			classifiedImages: map[string]ClassifiedImage{},
+			analyses:         map[string]*TextAnalysis{},
+		}
+		poller.watches[searchString] = watch
+		poller.searches = append(poller.searches, searchString)
+	}
+
+	watch.watched = watch.watched || watched
+	watch.requestedAt = time.Now()
+	return watch, nil
+}
+
+// requestedSearches must be called with the mutex locked
+func (poller *Poller) requestedSearches() int {
+	count := 0
+	for _, watch := range poller.watches {
+		if !watch.watched {
+			count++
+		}
+	}
+	return count
+}
+
+// dropSearch must be called with the mutex locked, it returns false when all
+// the searches of the clients have subscribers
+func (poller *Poller) dropSearch() bool {
+	subscribed := map[string]bool{}
+	for _, searchString := range poller.subscribers {
+		subscribed[searchString] = true
+	}
+
+	var dropped *pollWatch
+	for _, watch := range poller.watches {
+		if watch.watched || subscribed[watch.searchString] {
+			continue
+		}
+		if dropped == nil || watch.requestedAt.Before(dropped.requestedAt) {
+			dropped = watch
+		}
+	}
+	if dropped == nil {
+		return false
+	}
+
+	delete(poller.watches, dropped.searchString)
+	searches := []string{}
+	for _, searchString := range poller.searches {
+		if searchString != dropped.searchString {
+			searches = append(searches, searchString)
+		}
+	}
+	poller.searches = searches
+
+	log.Printf("Dropped polled search '%s' to poll other searches", dropped.searchString)
+	return true
+}
+
+func (poller *Poller) classifyImages(ctx context.Context, imageURLs []string) (map[string]ClassifiedImage, []string) {
+	var (
+		wg            sync.WaitGroup
+		mutex         sync.Mutex
+		errorMessages = make([]string, len(imageURLs))
+		semaphore     = make(chan struct{}, maxConcurrentClassifications)
+	)
+
+	classifiedImages := map[string]ClassifiedImage{}
+	for i, imageURL := range imageURLs {
+		wg.Add(1)
+		go func(i int, imageURL string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			classifiedImage, err := poller.summaryFn.classifyImage(ctx, "", imageURL, poller.ClassifyFn)
+			if err != nil {
+				log.Printf("Error classifying image '%s': %s\n", imageURL, err.Error())
+				errorMessages[i] = fmt.Sprintf("%s: %s", imageURL, err.Error())
+				return
+			}
+
+			mutex.Lock()
+			classifiedImages[imageURL] = classifiedImage
+			mutex.Unlock()
+		}(i, imageURL)
+	}
+	wg.Wait()
+
+	return classifiedImages, nonEmptyStrings(errorMessages)
+}
+
+func (poller *Poller) analyzeTweets(ctx context.Context, polledTweets []polledTweet) map[string]*TextAnalysis {
+	analyses := map[string]*TextAnalysis{}
+	if poller.summaryFn.NLUFnURL == "" {
+		return analyses
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, polled := range polledTweets {
+		if len(polled.tweet.ImageURLs) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(polled polledTweet) {
+			defer wg.Done()
+			if analysis := poller.summaryFn.analyzeTweet(ctx, polled.tweet); analysis != nil {
+				mutex.Lock()
+				analyses[polled.key] = analysis
+				mutex.Unlock()
+			}
+		}(polled)
+	}
+	wg.Wait()
+
+	return analyses
+}
+
+// broadcast must be called with the mutex locked, it drops the events of the
+// subscribers that are too slow rather than block the polls
+func (poller *Poller) broadcast(searchString string, events []SummaryEvent) {
+	for subscriber, subscribedSearch := range poller.subscribers {
+		if subscribedSearch != searchString {
+			continue
+		}
+
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+			default:
+				log.Printf("Dropping '%s' poll event of search '%s' for a slow subscriber\n", event.Type, searchString)
+			}
+		}
+	}
+}
+
+func (poller *Poller) summaryQuery(searchString string) SummaryQuery {
+	query := poller.summaryFn.summaryQuery()
+	query.SearchString = searchString
+	query.Count = poller.Count
+	query.MaxLabels = poller.ClassifyFn.MaxLabels
+	query.MinScore = poller.ClassifyFn.MinScore
+	return query
+}
+
+// summaryData must be called with the mutex locked
+func (poller *Poller) summaryData(watch *pollWatch) SummaryData {
+	classifiedTweets := watch.classifiedTweets(watch.tweets)
+	return SummaryData{
+		Query:            poller.summaryQuery(watch.searchString),
+		Stats:            computeStats(len(watch.tweets), classifiedTweets),
+		ClassifiedTweets: classifiedTweets,
+		Errors:           append([]string{}, watch.errors...),
+	}
+}
+
+// Private pollWatch
+
+func (watch *pollWatch) sinceSources(sources []ContentSource) []ContentSource {
+	sinceSources := []ContentSource{}
+	for _, source := range sources {
+		if sinceID := watch.sinceIDs[source.Name]; sinceID != "" {
+			params := map[string]string{}
+			for name, value := range source.Params {
+				params[name] = value
+			}
+			params[sinceIDParam] = sinceID
+			source.Params = params
+		}
+		sinceSources = append(sinceSources, source)
+	}
+	return sinceSources
+}
+
+// newTweets returns the tweets not in the window yet, most recent first
+func (watch *pollWatch) newTweets(tweets []Tweet) []polledTweet {
+	windowKeys := map[string]bool{}
+	for _, polled := range watch.tweets {
+		windowKeys[polled.key] = true
+	}
+
+	newTweets := []polledTweet{}
+	for _, tweet := range tweets {
+		key := polledTweetKey(tweet)
+		if windowKeys[key] {
+			continue
+		}
+		windowKeys[key] = true
+		newTweets = append(newTweets, polledTweet{tweet: tweet, key: key})
+	}
+
+	sort.SliceStable(newTweets, func(i, j int) bool {
+		return newerThan(newTweets[i].tweet.CreatedAt, newTweets[j].tweet.CreatedAt)
+	})
+
+	for i := len(newTweets) - 1; i >= 0; i-- {
+		newTweets[i].index = watch.nextIndex
+		watch.nextIndex++
+	}
+	return newTweets
+}
+
+func (watch *pollWatch) unclassifiedImageURLs(polledTweets []polledTweet) []string {
+	seen := map[string]bool{}
+	imageURLs := []string{}
+	for _, polled := range polledTweets {
+		for _, imageURL := range polled.tweet.ImageURLs {
+			if _, ok := watch.classifiedImages[imageURL]; ok || seen[imageURL] {
+				continue
+			}
+			seen[imageURL] = true
+			imageURLs = append(imageURLs, imageURL)
+		}
+	}
+	return imageURLs
+}
+
+// prune forgets the classified images and analyses out of the window
+func (watch *pollWatch) prune() {
+	keys := map[string]bool{}
+	imageURLs := map[string]bool{}
+	for _, polled := range watch.tweets {
+		keys[polled.key] = true
+		for _, imageURL := range polled.tweet.ImageURLs {
+			imageURLs[imageURL] = true
+		}
+	}
+
+	for imageURL := range watch.classifiedImages {
+		if !imageURLs[imageURL] {
+			delete(watch.classifiedImages, imageURL)
+		}
+	}
+	for key := range watch.analyses {
+		if !keys[key] {
+			delete(watch.analyses, key)
+		}
+	}
+}
+
+func (watch *pollWatch) updateSinceIDs(tweets []Tweet) {
+	for _, tweet := range tweets {
+		if newerID(tweet.ID, watch.sinceIDs[tweet.Source]) {
+			watch.sinceIDs[tweet.Source] = tweet.ID
+		}
+	}
+}
+
+// classifiedTweets keeps the tweets with at least one classified image
+func (watch *pollWatch) classifiedTweets(polledTweets []polledTweet) []ClassifiedTweet {
+	classifiedTweets := []ClassifiedTweet{}
+	for _, polled := range polledTweets {
+		classifiedTweet := ClassifiedTweet{
+			ID:        polled.tweet.ID,
+			Text:      polled.tweet.Text,
+			Source:    polled.tweet.Source,
+			CreatedAt: polled.tweet.CreatedAt,
+			Analysis:  watch.analyses[polled.key],
+		}
+		for _, imageURL := range polled.tweet.ImageURLs {
+			if classifiedImage, ok := watch.classifiedImages[imageURL]; ok {
+				classifiedTweet.ClassifiedImages = append(classifiedTweet.ClassifiedImages, classifiedImage)
+			}
+		}
+
+		if len(classifiedTweet.ClassifiedImages) > 0 {
+			classifiedTweets = append(classifiedTweets, classifiedTweet)
+		}
+	}
+	return classifiedTweets
+}
+
+// events sends the oldest tweets first so that pages prepending the tweets
+// show the most recent on top
+func (watch *pollWatch) events(polledTweets []polledTweet, summaryData SummaryData) []SummaryEvent {
+	events := []SummaryEvent{}
+	for i := len(polledTweets) - 1; i >= 0; i-- {
+		polled := polledTweets[i]
+		if len(polled.tweet.ImageURLs) == 0 {
+			continue
+		}
+
+		tweet := polled.tweet
+		events = append(events, SummaryEvent{Type: tweetEvent, TweetIndex: polled.index, ImageIndex: -1, Tweet: &tweet})
+		for j, imageURL := range tweet.ImageURLs {
+			if classifiedImage, ok := watch.classifiedImages[imageURL]; ok {
+				events = append(events, SummaryEvent{Type: classificationEvent, TweetIndex: polled.index, ImageIndex: j, ClassifiedImage: &classifiedImage})
+			}
+		}
+		if analysis := watch.analyses[polled.key]; analysis != nil {
+			events = append(events, SummaryEvent{Type: analysisEvent, TweetIndex: polled.index, ImageIndex: -1, Analysis: analysis})
+		}
+	}
+
+	for _, errorMessage := range summaryData.Errors {
+		events = append(events, SummaryEvent{Type: errorEvent, TweetIndex: -1, ImageIndex: -1, Error: errorMessage})
+	}
+
+	stats := summaryData.Stats
+	return append(events, SummaryEvent{Type: statsEvent, TweetIndex: -1, ImageIndex: -1, Stats: &stats})
+}
+
+// Private functions
+
+func polledTweetKey(tweet Tweet) string {
+	cTweet := ClassifiedTweet{ID: tweet.ID, Text: tweet.Text, Source: tweet.Source}
+	for _, imageURL := range tweet.ImageURLs {
+		cTweet.ClassifiedImages = append(cTweet.ClassifiedImages, ClassifiedImage{ImageURL: imageURL})
+	}
+	return tweetKey(cTweet)
+}
+
+// newerID compares numeric tweet IDs, e.g., Twitter and Mastodon IDs, since
+// the other IDs are not ordered
+func newerID(id string, otherID string) bool {
+	if !isNumericID(id) {
+		return false
+	}
+	if !isNumericID(otherID) {
+		return true
+	}
+
+	id, otherID = strings.TrimLeft(id, "0"), strings.TrimLeft(otherID, "0")
+	if len(id) != len(otherID) {
+		return len(id) > len(otherID)
+	}
+	return id > otherID
+}
+
+func isNumericID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func nonEmptyStrings(values []string) []string {
+	nonEmpty := []string{}
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return nonEmpty
+}
+
+// PollResults
+
+func (pollResults PollResults) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, pollResult := range pollResults {
+		sb.WriteString(fmt.Sprintf("🔁 `%s` polled at %s: %d new tweets, %d new images, %d tweets in window\n",
+			pollResult.SearchString, pollResult.PolledAt.Format(time.RFC3339), pollResult.NewTweets, pollResult.NewImages, pollResult.WindowTweets))
+		for _, errorMessage := range pollResult.Errors {
+			sb.WriteString(fmt.Sprintf("⚠️  %s\n", errorMessage))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,241 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestPollerOnlyFetchesAndClassifiesNewTweets(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	poller := NewPoller(pollServer.summaryFn, 0, []string{"NBA"})
+
+	pollResult, err := poller.Poll(context.Background(), "NBA")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pollResult.Errors, []string{})
+	assert.Equal(t, pollResult.NewTweets, 2)
+	assert.Equal(t, pollResult.NewImages, 2)
+	assert.Equal(t, pollServer.lastSinceID(), "")
+
+	pollResult, err = poller.Poll(context.Background(), "NBA")
+	assert.NilError(t, err)
+	assert.Equal(t, pollResult.NewTweets, 1)
+	assert.Equal(t, pollResult.NewImages, 1)
+	assert.Equal(t, pollResult.WindowTweets, 3)
+	assert.Equal(t, pollServer.lastSinceID(), "12")
+	assert.Equal(t, pollServer.classifications(), 3)
+
+	summaryData, ok := poller.Summary("NBA")
+	assert.Assert(t, ok)
+	assert.Equal(t, summaryData.Query.SearchString, "NBA")
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 3)
+	assert.Equal(t, summaryData.ClassifiedTweets[0].ID, "13")
+	assert.Equal(t, summaryData.ClassifiedTweets[0].ClassifiedImages[0].ImageURL, "http://img/1.jpg")
+	assert.Equal(t, summaryData.Stats.ImageCount, 4)
+}
+
+func TestPollerRollingWindow(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	poller := NewPoller(pollServer.summaryFn, 2, []string{})
+	poller.Poll(context.Background(), "NBA")
+	pollResult, err := poller.Poll(context.Background(), "NBA")
+	assert.NilError(t, err)
+	assert.Equal(t, pollResult.WindowTweets, 2)
+
+	summaryData, ok := poller.Summary("NBA")
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, []string{summaryData.ClassifiedTweets[0].ID, summaryData.ClassifiedTweets[1].ID}, []string{"13", "12"})
+	assert.Equal(t, summaryData.Stats.TweetCount, 2)
+
+	_, ok = poller.Summary("knative")
+	assert.Assert(t, !ok)
+}
+
+func TestPollerSubscribe(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	poller := NewPoller(pollServer.summaryFn, 0, []string{})
+
+	// the search is polled once subscribed, without waiting for the next poll
+	replay, events, unsubscribe, err := poller.Subscribe(context.Background(), "NBA")
+	assert.NilError(t, err)
+	defer unsubscribe()
+	assert.Equal(t, len(replay), 0)
+	assert.DeepEqual(t, poller.Searches(), []string{"NBA"})
+	assert.DeepEqual(t, receivedEventTypes(events, 5), []string{tweetEvent, classificationEvent, tweetEvent, classificationEvent, statsEvent})
+	assert.Equal(t, pollServer.searches(), 1)
+
+	poller.Poll(context.Background(), "NBA")
+	event := <-events
+	assert.Equal(t, event.Type, tweetEvent)
+	assert.Equal(t, event.Tweet.ID, "13")
+	assert.Equal(t, event.TweetIndex, 2)
+
+	replay, _, unsubscribeReplay, err := poller.Subscribe(context.Background(), "NBA")
+	assert.NilError(t, err)
+	defer unsubscribeReplay()
+	assert.Equal(t, len(replay), 3+4+1)
+	assert.Equal(t, replay[0].Tweet.ID, "11")
+}
+
+func TestPollerMaxSearches(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	poller := NewPoller(pollServer.summaryFn, 0, []string{"NBA"})
+	poller.MaxSearches = 2
+
+	_, err := poller.Poll(context.Background(), "NFL")
+	assert.NilError(t, err)
+	_, events, unsubscribe, err := poller.Subscribe(context.Background(), "MLB")
+	assert.NilError(t, err)
+	receivedEventTypes(events, 5)
+	assert.DeepEqual(t, poller.Searches(), []string{"NBA", "NFL", "MLB"})
+
+	// the least recently requested search without subscribers is dropped,
+	// never the watched ones
+	_, err = poller.Poll(context.Background(), "NHL")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, poller.Searches(), []string{"NBA", "MLB", "NHL"})
+
+	_, _, unsubscribeNHL, err := poller.Subscribe(context.Background(), "NHL")
+	assert.NilError(t, err)
+	defer unsubscribeNHL()
+
+	_, err = poller.Poll(context.Background(), "MLS")
+	assert.Equal(t, err, errTooManyPolledSearches)
+	_, _, _, err = poller.Subscribe(context.Background(), "MLS")
+	assert.Equal(t, err, errTooManyPolledSearches)
+
+	unsubscribe()
+	for range events {
+	}
+	_, err = poller.Poll(context.Background(), "MLS")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, poller.Searches(), []string{"NBA", "NHL", "MLS"})
+}
+
+func TestPollHandler(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	summaryFn := pollServer.summaryFn
+	summaryFn.poller = NewPoller(summaryFn, 0, []string{})
+
+	recorder := httptest.NewRecorder()
+	summaryFn.PollHandler(recorder, httptest.NewRequest("POST", "/poll", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.PollHandler(recorder, httptest.NewRequest("POST", "/poll?q=NBA", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/json")
+
+	pollResults := PollResults{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &pollResults))
+	assert.Equal(t, len(pollResults), 1)
+	assert.Equal(t, pollResults[0].NewTweets, 2)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.PollHandler(recorder, httptest.NewRequest("POST", "/poll", nil))
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &pollResults))
+	assert.Equal(t, pollResults[0].SearchString, "NBA")
+	assert.Equal(t, pollResults[0].NewTweets, 1)
+
+	searches := pollServer.searches()
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, pollServer.searches(), searches)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 3)
+
+	summaryFn.poller.MaxSearches = 1
+	_, _, unsubscribe, err := summaryFn.poller.Subscribe(context.Background(), "NBA")
+	assert.NilError(t, err)
+	defer unsubscribe()
+
+	recorder = httptest.NewRecorder()
+	summaryFn.PollHandler(recorder, httptest.NewRequest("POST", "/poll?q=NFL", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+}
+
+func TestPollEventsHandlerPollsNewSearches(t *testing.T) {
+	pollServer := newPollServer(t)
+	defer pollServer.close()
+
+	summaryFn := pollServer.summaryFn
+	summaryFn.poller = NewPoller(summaryFn, 0, []string{})
+
+	server := httptest.NewServer(http.HandlerFunc(summaryFn.PollEventsHandler))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/poll/events?q=NBA")
+	assert.NilError(t, err)
+	defer response.Body.Close()
+
+	// the window of the new search streams without a /poll
+	eventTypes := []string{}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() && !strings.HasSuffix(scanner.Text(), statsEvent) {
+		if strings.HasPrefix(scanner.Text(), "event: ") {
+			eventTypes = append(eventTypes, strings.TrimPrefix(scanner.Text(), "event: "))
+		}
+	}
+	assert.DeepEqual(t, eventTypes, []string{tweetEvent, classificationEvent, tweetEvent, classificationEvent})
+	assert.Equal(t, pollServer.searches(), 1)
+}
+
+func TestPollHandlerDisabled(t *testing.T) {
+	summaryFn := &SummaryFn{}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.PollHandler(recorder, httptest.NewRequest("POST", "/poll", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestNewerID(t *testing.T) {
+	assert.Assert(t, newerID("12", ""))
+	assert.Assert(t, newerID("100", "99"))
+	assert.Assert(t, !newerID("99", "100"))
+	assert.Assert(t, !newerID("12", "12"))
+	assert.Assert(t, !newerID("t3_abc", "12"))
+	assert.Assert(t, newerID("12", "t3_abc"))
+}
+
+// Private
+
+func receivedEventTypes(events <-chan SummaryEvent, count int) []string {
+	eventTypes := []string{}
+	for i := 0; i < count; i++ {
+		eventTypes = append(eventTypes, (<-events).Type)
+	}
+	return eventTypes
+}
@@ -0,0 +1,513 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	csvReport      = "csv"
+	markdownReport = "markdown"
+	htmlReport     = "html"
+
+	maxConcurrentThumbnails = 4
+	reportThumbnailSize     = 160
+	reportThumbnailTimeout  = 10
+	maxThumbnailImageBytes  = 10 << 20
+	maxThumbnailImagePixels = 50 * 1000 * 1000
+	maxReportChartLabels    = 10
+
+	reportChartWidth      = 600
+	reportChartLabelWidth = 150
+	reportChartBarHeight  = 20
+)
+
+var reportFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type ReportPageData struct {
+	PageTitle   string
+	GeneratedAt time.Time
+
+	Query  SummaryQuery
+	Stats  SummaryStats
+	Tweets []ReportTweet
+	Errors []string
+
+	Charts []ReportChart
+}
+
+type ReportTweet struct {
+	ClassifiedTweet
+
+	Images []ReportImage
+}
+
+// ReportImage is Embedded when its thumbnail is inlined as a data URL, and
+// links to the original image otherwise
+type ReportImage struct {
+	ClassifiedImage
+
+	Thumbnail template.URL
+	Embedded  bool
+}
+
+type ReportChart struct {
+	Title      string
+	Width      int
+	Height     int
+	LabelWidth int
+	BarHeight  int
+	Bars       []ReportBar
+}
+
+type ReportBar struct {
+	Label string
+	Text  string
+	Y     int
+	Width int
+	TextX int
+	TextY int
+}
+
+// WriteReport writes the summary as a CSV file with a row per image label, a
+// Markdown digest, or a self-contained HTML page with thumbnails and charts
+func (summaryFn *SummaryFn) WriteReport(writer io.Writer, format string, summaryData SummaryData) error {
+	switch format {
+	case csvReport:
+		return writeCSVReport(writer, summaryData)
+	case markdownReport:
+		return writeMarkdownReport(writer, summaryData, time.Now())
+	case htmlReport:
+		return summaryFn.writeHTMLReport(writer, summaryData, time.Now())
+	}
+	return fmt.Errorf("unknown report format '%s', expected csv, markdown, or html", format)
+}
+
+// ReportHandler downloads the report of the `q` search, or of the stored
+// search with the `id` query parameter, in the `format` query parameter
+func (summaryFn *SummaryFn) ReportHandler(writer http.ResponseWriter, request *http.Request) {
+	fn := summaryFn.requestFn(request)
+	format := fn.ExtractQueryStringParam(request, []string{"format", "f"}, htmlReport)
+	searchID := fn.ExtractQueryStringParam(request, []string{"id", "search-id"}, "")
+	log.Printf("SummaryFn.Report: s=\"%s\", c=\"%d\", id=\"%s\", format=\"%s\"", fn.SearchString, fn.Count, searchID, format)
+
+	if reportContentType(format) == "" {
+		http.Error(writer, fmt.Sprintf("unknown report format '%s', expected csv, markdown, or html", format), http.StatusBadRequest)
+		return
+	}
+
+	summaryData, status, err := fn.reportSummary(request.Context(), searchID)
+	if err != nil {
+		log.Printf("Error collecting the report summary: %s\n", err.Error())
+		http.Error(writer, err.Error(), status)
+		return
+	}
+
+	viewOptions := fn.viewQueryParams(request)
+	viewOptions.Page, viewOptions.PageSize = 1, 0
+	summaryData = viewOptions.Apply(summaryData)
+
+	report := bytes.NewBufferString("")
+	err = fn.WriteReport(report, format, summaryData)
+	if err != nil {
+		log.Printf("Error writing report: %s\n", err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Add("Content-Type", reportContentType(format))
+	writer.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", reportFileName(summaryData.Query.SearchString, format)))
+	writer.Write(report.Bytes())
+}
+
+// Private SummaryFn
+
+// reportSummary does not store the summary nor fire its alerts since a report
+// is only an export of the summary
+func (summaryFn *SummaryFn) reportSummary(ctx context.Context, searchID string) (SummaryData, int, error) {
+	if searchID != "" {
+		if summaryFn.store == nil {
+			return SummaryData{}, http.StatusNotFound, errors.New("history is disabled, start summary-fn with a --store-backend")
+		}
+
+		_, summaryData, err := summaryFn.store.LoadSearch(searchID)
+		if err != nil {
+			return SummaryData{}, storeErrorStatus(err), err
+		}
+		return summaryData, http.StatusOK, nil
+	}
+
+	if summaryData, ok := summaryFn.polledSummary(summaryFn.SearchString); ok {
+		return summaryData, http.StatusOK, nil
+	}
+
+	summaryData, err := summaryFn.Summary(ctx)
+	if err != nil {
+		return SummaryData{}, http.StatusBadGateway, err
+	}
+	return summaryData, http.StatusOK, nil
+}
+
+func (summaryFn *SummaryFn) writeHTMLReport(writer io.Writer, summaryData SummaryData, generatedAt time.Time) error {
+	thumbnails := summaryFn.fetchThumbnails(summaryData.ClassifiedTweets)
+
+	tweets := []ReportTweet{}
+	for _, cTweet := range summaryData.ClassifiedTweets {
+		reportTweet := ReportTweet{ClassifiedTweet: cTweet}
+		for _, cImage := range cTweet.ClassifiedImages {
+			thumbnail, embedded := thumbnails[cImage.ImageURL]
+			reportTweet.Images = append(reportTweet.Images, ReportImage{ClassifiedImage: cImage, Thumbnail: thumbnail, Embedded: embedded})
+		}
+		tweets = append(tweets, reportTweet)
+	}
+
+	return summaryFn.summaryTemplates().Execute(writer, reportLayoutTemplate, ReportPageData{
+		PageTitle:   fmt.Sprintf("Report of the tweets with images for search `%s`", summaryData.Query.SearchString),
+		GeneratedAt: generatedAt.UTC(),
+
+		Query:  summaryData.Query,
+		Stats:  summaryData.Stats,
+		Tweets: tweets,
+		Errors: summaryData.Errors,
+
+		Charts: []ReportChart{
+			newReportChart("Images per label", summaryData.Stats.Labels, func(lStats LabelStats) (float64, string) {
+				return float64(lStats.Count), strconv.Itoa(lStats.Count)
+			}),
+			newReportChart("Mean confidence per label", summaryData.Stats.Labels, func(lStats LabelStats) (float64, string) {
+				return lStats.MeanScore, fmt.Sprintf("%1.3f", lStats.MeanScore)
+			}),
+		},
+	})
+}
+
+// fetchThumbnails returns the data URLs of the images that could be fetched
+// and decoded, the report then links to the other images
+func (summaryFn *SummaryFn) fetchThumbnails(classifiedTweets []ClassifiedTweet) map[string]template.URL {
+	var (
+		wg         sync.WaitGroup
+		mutex      sync.Mutex
+		thumbnails = map[string]template.URL{}
+		semaphore  = make(chan struct{}, maxConcurrentThumbnails)
+	)
+
+	for _, cTweet := range classifiedTweets {
+		for _, cImage := range cTweet.ClassifiedImages {
+			mutex.Lock()
+			_, fetching := thumbnails[cImage.ImageURL]
+			thumbnails[cImage.ImageURL] = ""
+			mutex.Unlock()
+			if fetching {
+				continue
+			}
+
+			wg.Add(1)
+			go func(imageURL string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				thumbnail, err := fetchThumbnail(imageURL, reportThumbnailTimeout)
+				if err != nil {
+					log.Printf("Error inlining the thumbnail of '%s': %s\n", imageURL, err.Error())
+				}
+
+				mutex.Lock()
+				thumbnails[imageURL] = thumbnail
+				mutex.Unlock()
+			}(cImage.ImageURL)
+		}
+	}
+	wg.Wait()
+
+	for imageURL, thumbnail := range thumbnails {
+		if thumbnail == "" {
+			delete(thumbnails, imageURL)
+		}
+	}
+	return thumbnails
+}
+
+// Private functions
+
+func writeCSVReport(writer io.Writer, summaryData SummaryData) error {
+	csvWriter := csv.NewWriter(writer)
+
+	err := csvWriter.Write([]string{"tweet_id", "source", "created_at", "tweet", "image_url", "label", "score"})
+	if err != nil {
+		return err
+	}
+
+	for _, cTweet := range summaryData.ClassifiedTweets {
+		for _, cImage := range cTweet.ClassifiedImages {
+			tweetRow := []string{cTweet.ID, cTweet.Source, cTweet.CreatedAt, cTweet.Text, cImage.ImageURL}
+			if len(cImage.Labels) == 0 {
+				err = csvWriter.Write(append(tweetRow, "", ""))
+			}
+			for _, label := range cImage.Labels {
+				err = csvWriter.Write(append(tweetRow, label.Name, strconv.FormatFloat(float64(label.Score), 'f', 3, 32)))
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeMarkdownReport(writer io.Writer, summaryData SummaryData, generatedAt time.Time) error {
+	stats := summaryData.Stats
+
+	sb := bytes.NewBufferString("")
+	sb.WriteString(fmt.Sprintf("# Tweets with images for search `%s`\n\n", summaryData.Query.SearchString))
+	sb.WriteString(fmt.Sprintf("_Generated at %s from the sources %s._\n\n", generatedAt.UTC().Format(time.RFC3339), strings.Join(summaryData.Query.Sources, ", ")))
+	sb.WriteString(fmt.Sprintf("%d of %d tweets with images (%.1f%%), %d images.\n\n", stats.TweetsWithImages, stats.TweetCount, stats.ImagesShare*100, stats.ImageCount))
+
+	if len(stats.Labels) > 0 {
+		sb.WriteString("## Labels\n\n")
+		sb.WriteString("| label | images | mean confidence | max confidence |\n")
+		sb.WriteString("|---|---:|---:|---:|\n")
+		for _, lStats := range stats.Labels {
+			sb.WriteString(fmt.Sprintf("| %s | %d | %1.3f | %1.3f |\n", markdownTableCell(lStats.Name), lStats.Count, lStats.MeanScore, lStats.MaxScore))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("## Tweets\n")
+	for i, cTweet := range summaryData.ClassifiedTweets {
+		sb.WriteString(fmt.Sprintf("\n### %d. %s", i+1, cTweet.Source))
+		if cTweet.CreatedAt != "" {
+			sb.WriteString(fmt.Sprintf(", %s", cTweet.CreatedAt))
+		}
+		sb.WriteString(fmt.Sprintf("\n\n> %s\n\n", strings.ReplaceAll(strings.TrimSpace(cTweet.Text), "\n", "\n> ")))
+
+		if cTweet.Analysis != nil {
+			sb.WriteString(fmt.Sprintf("Sentiment: %s %s (%1.3f)\n\n", sentimentEmoji(cTweet.Analysis.Sentiment), cTweet.Analysis.Sentiment.Label, cTweet.Analysis.Sentiment.Score))
+		}
+
+		for _, cImage := range cTweet.ClassifiedImages {
+			labels := []string{}
+			for _, label := range cImage.Labels {
+				labels = append(labels, fmt.Sprintf("`%s` %1.3f", label.Name, label.Score))
+			}
+			sb.WriteString(fmt.Sprintf("- [![image](%s)](%s) %s\n", cImage.ImageURL, cImage.ImageURL, strings.Join(labels, ", ")))
+		}
+	}
+
+	if len(summaryData.Errors) > 0 {
+		sb.WriteString("\n## Errors\n\n")
+		for _, errorMessage := range summaryData.Errors {
+			sb.WriteString(fmt.Sprintf("- %s\n", errorMessage))
+		}
+	}
+
+	_, err := writer.Write(sb.Bytes())
+	return err
+}
+
+// fetchThumbnail only inlines http and https images, downsized to JPEG
+// thumbnails when they can be decoded
+func fetchThumbnail(imageURL string, timeout int) (template.URL, error) {
+	parsedURL, err := url.Parse(imageURL)
+	if err != nil {
+		return "", err
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", fmt.Errorf("unsupported image URL scheme '%s'", parsedURL.Scheme)
+	}
+
+	data, err := fetchThumbnailImage(imageURL, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	// checks the dimensions first since decoding allocates all the pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil && config.Width*config.Height > maxThumbnailImagePixels {
+		return "", fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	var img image.Image
+	if err == nil {
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		contentType := http.DetectContentType(data)
+		if !strings.HasPrefix(contentType, "image/") {
+			return "", fmt.Errorf("not an image but '%s'", contentType)
+		}
+		return dataURL(contentType, data), nil
+	}
+
+	thumbnail := bytes.NewBufferString("")
+	err = jpeg.Encode(thumbnail, thumbnailImage(img, reportThumbnailSize), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return "", err
+	}
+	return dataURL("image/jpeg", thumbnail.Bytes()), nil
+}
+
+// fetchThumbnailImage reads at most maxThumbnailImageBytes of the image
+func fetchThumbnailImage(imageURL string, timeout int) ([]byte, error) {
+	client := http.Client{
+		Timeout: time.Second * time.Duration(timeout),
+	}
+
+	res, err := client.Get(imageURL)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return []byte{}, fmt.Errorf("error fetching '%s': %s", imageURL, res.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxThumbnailImageBytes+1))
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(data) > maxThumbnailImageBytes {
+		return []byte{}, fmt.Errorf("image larger than %d bytes", maxThumbnailImageBytes)
+	}
+	return data, nil
+}
+
+// thumbnailImage scales the image down to fit in a size x size square with a
+// nearest neighbor sampling, which is good enough for report thumbnails
+func thumbnailImage(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	thumbnailWidth, thumbnailHeight := size, height*size/width
+	if height > width {
+		thumbnailWidth, thumbnailHeight = width*size/height, size
+	}
+	if thumbnailWidth < 1 {
+		thumbnailWidth = 1
+	}
+	if thumbnailHeight < 1 {
+		thumbnailHeight = 1
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, thumbnailHeight))
+	for y := 0; y < thumbnailHeight; y++ {
+		for x := 0; x < thumbnailWidth; x++ {
+			thumbnail.Set(x, y, img.At(bounds.Min.X+x*width/thumbnailWidth, bounds.Min.Y+y*height/thumbnailHeight))
+		}
+	}
+	return thumbnail
+}
+
+func dataURL(contentType string, data []byte) template.URL {
+	return template.URL(fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data)))
+}
+
+// newReportChart charts the first labels, which are sorted by image count
+func newReportChart(title string, labels []LabelStats, value func(LabelStats) (float64, string)) ReportChart {
+	if len(labels) > maxReportChartLabels {
+		labels = labels[:maxReportChartLabels]
+	}
+
+	maxValue := 0.0
+	for _, lStats := range labels {
+		if v, _ := value(lStats); v > maxValue {
+			maxValue = v
+		}
+	}
+
+	chart := ReportChart{
+		Title:      title,
+		Width:      reportChartWidth,
+		Height:     len(labels) * (reportChartBarHeight + 4),
+		LabelWidth: reportChartLabelWidth,
+		BarHeight:  reportChartBarHeight,
+	}
+
+	barsWidth := reportChartWidth - reportChartLabelWidth - 60
+	for i, lStats := range labels {
+		v, text := value(lStats)
+		bar := ReportBar{
+			Label: lStats.Name,
+			Text:  text,
+			Y:     i * (reportChartBarHeight + 4),
+			TextY: i*(reportChartBarHeight+4) + reportChartBarHeight*3/4,
+		}
+		if maxValue > 0 {
+			bar.Width = int(v / maxValue * float64(barsWidth))
+		}
+		bar.TextX = reportChartLabelWidth + bar.Width + 6
+		chart.Bars = append(chart.Bars, bar)
+	}
+	return chart
+}
+
+func reportContentType(format string) string {
+	switch format {
+	case csvReport:
+		return "text/csv; charset=utf-8"
+	case markdownReport:
+		return "text/markdown; charset=utf-8"
+	case htmlReport:
+		return "text/html; charset=utf-8"
+	}
+	return ""
+}
+
+func reportFileName(searchString string, format string) string {
+	extension := format
+	if format == markdownReport {
+		extension = "md"
+	}
+
+	name := strings.Trim(reportFileNameRegexp.ReplaceAllString(searchString, "-"), "-")
+	if name == "" {
+		name = "summary"
+	}
+	return fmt.Sprintf("knfun-report-%s.%s", name, extension)
+}
+
+func markdownTableCell(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
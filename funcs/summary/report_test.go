@@ -0,0 +1,183 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestWriteCSVReport(t *testing.T) {
+	report := bytes.NewBufferString("")
+	assert.NilError(t, writeCSVReport(report, newReportSummaryData("http://img/1.jpg")))
+
+	records, err := csv.NewReader(report).ReadAll()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, records, [][]string{
+		[]string{"tweet_id", "source", "created_at", "tweet", "image_url", "label", "score"},
+		[]string{"1", "twitter", "2019-10-01T10:00:00Z", "dunk, \"wow\"", "http://img/1.jpg", "ball", "0.900"},
+		[]string{"1", "twitter", "2019-10-01T10:00:00Z", "dunk, \"wow\"", "http://img/1.jpg", "sport | game", "0.500"},
+	})
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	report := bytes.NewBufferString("")
+	generatedAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	assert.NilError(t, writeMarkdownReport(report, newReportSummaryData("http://img/1.jpg"), generatedAt))
+
+	markdown := report.String()
+	assert.Assert(t, strings.HasPrefix(markdown, "# Tweets with images for search `NBA`\n"))
+	assert.Assert(t, strings.Contains(markdown, "_Generated at 2019-10-01T12:00:00Z from the sources twitter._"))
+	assert.Assert(t, strings.Contains(markdown, "| ball | 1 | 0.900 | 0.900 |"))
+	assert.Assert(t, strings.Contains(markdown, "| sport \\| game | 1 | 0.500 | 0.500 |"))
+	assert.Assert(t, strings.Contains(markdown, "> dunk, \"wow\""))
+	assert.Assert(t, strings.Contains(markdown, "- [![image](http://img/1.jpg)](http://img/1.jpg) `ball` 0.900, `sport | game` 0.500"))
+	assert.Assert(t, strings.Contains(markdown, "## Errors\n\n- broken: oops\n"))
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	imageServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/1.png" {
+			http.NotFound(writer, request)
+			return
+		}
+		png.Encode(writer, image.NewRGBA(image.Rect(0, 0, 640, 320)))
+	}))
+	defer imageServer.Close()
+
+	summaryData := newReportSummaryData(imageServer.URL + "/1.png")
+	summaryData.ClassifiedTweets = append(summaryData.ClassifiedTweets, ClassifiedTweet{
+		Text:             "missing",
+		ClassifiedImages: []ClassifiedImage{ClassifiedImage{ImageURL: imageServer.URL + "/missing.png"}},
+	})
+
+	summaryFn := &SummaryFn{}
+	summaryFn.Timeout = 5
+
+	report := bytes.NewBufferString("")
+	assert.NilError(t, summaryFn.WriteReport(report, htmlReport, summaryData))
+
+	html := report.String()
+	assert.Assert(t, strings.Contains(html, "<svg class=\"chart\""))
+	assert.Assert(t, strings.Contains(html, "Images per label"))
+	assert.Assert(t, strings.Contains(html, "<img src=\"data:image/jpeg;base64,"))
+	assert.Assert(t, !strings.Contains(html, "src=\""+imageServer.URL))
+	assert.Assert(t, strings.Contains(html, "<a href=\""+imageServer.URL+"/missing.png\">"))
+	assert.Assert(t, !strings.Contains(html, "/static/"))
+}
+
+func TestWriteReportUnknownFormat(t *testing.T) {
+	summaryFn := &SummaryFn{}
+	err := summaryFn.WriteReport(bytes.NewBufferString(""), "pdf", SummaryData{})
+	assert.ErrorContains(t, err, "unknown report format 'pdf'")
+}
+
+func TestThumbnailImage(t *testing.T) {
+	thumbnail := thumbnailImage(image.NewRGBA(image.Rect(0, 0, 640, 320)), 160)
+	assert.Equal(t, thumbnail.Bounds().Dx(), 160)
+	assert.Equal(t, thumbnail.Bounds().Dy(), 80)
+
+	thumbnail = thumbnailImage(image.NewRGBA(image.Rect(0, 0, 100, 400)), 160)
+	assert.Equal(t, thumbnail.Bounds().Dx(), 40)
+	assert.Equal(t, thumbnail.Bounds().Dy(), 160)
+
+	small := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	assert.Equal(t, thumbnailImage(small, 160), image.Image(small))
+}
+
+func TestFetchThumbnailLimits(t *testing.T) {
+	hugeGIF := bytes.NewBufferString("")
+	assert.NilError(t, gif.Encode(hugeGIF, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil))
+	// declares a 65535x65535 logical screen
+	data := hugeGIF.Bytes()
+	data[6], data[7], data[8], data[9] = 0xff, 0xff, 0xff, 0xff
+
+	imageServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/huge.gif":
+			writer.Write(data)
+		case "/large.png":
+			writer.Write(make([]byte, maxThumbnailImageBytes+1))
+		default:
+			png.Encode(writer, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+		}
+	}))
+	defer imageServer.Close()
+
+	_, err := fetchThumbnail(imageServer.URL+"/huge.gif", 5)
+	assert.ErrorContains(t, err, "image of 65535x65535 pixels is too large")
+
+	_, err = fetchThumbnail(imageServer.URL+"/large.png", 5)
+	assert.ErrorContains(t, err, "image larger than")
+
+	thumbnail, err := fetchThumbnail(imageServer.URL+"/small.png", 5)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(string(thumbnail), "data:image/jpeg;base64,"))
+}
+
+func TestReportHandler(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.ReportHandler(recorder, httptest.NewRequest("GET", "/report?q=%23NBA&format=csv", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+	assert.Equal(t, recorder.Header().Get("Content-Disposition"), "attachment; filename=\"knfun-report-NBA.csv\"")
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, len(records), 2)
+	assert.Equal(t, records[1][5], "ball")
+
+	recorder = httptest.NewRecorder()
+	summaryFn.ReportHandler(recorder, httptest.NewRequest("GET", "/report?q=NBA&format=pdf", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.ReportHandler(recorder, httptest.NewRequest("GET", "/report?id=20191001T100000.000000000Z", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestReportHandlerStoredSearch(t *testing.T) {
+	store, err := NewStore("memory", "")
+	assert.NilError(t, err)
+
+	storedSearch, err := store.SaveSearch(time.Now(), newReportSummaryData("http://img/1.jpg"))
+	assert.NilError(t, err)
+
+	summaryFn := &SummaryFn{store: store}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.ReportHandler(recorder, httptest.NewRequest("GET", "/report?format=markdown&id="+storedSearch.ID, nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Disposition"), "attachment; filename=\"knfun-report-NBA.md\"")
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "| ball | 1 | 0.900 | 0.900 |"))
+
+	recorder = httptest.NewRecorder()
+	summaryFn.ReportHandler(recorder, httptest.NewRequest("GET", "/report?id=unknown", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
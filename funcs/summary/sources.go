@@ -0,0 +1,188 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultSourceName = "twitter"
+
+type ContentSource struct {
+	Name   string            `yaml:"name" json:"name" mapstructure:"name"`
+	URL    string            `yaml:"url" json:"url" mapstructure:"url"`
+	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty" mapstructure:"params"`
+	Weight int               `yaml:"weight,omitempty" json:"weight,omitempty" mapstructure:"weight"`
+}
+
+type sourceResult struct {
+	source ContentSource
+	tweets []Tweet
+	err    error
+}
+
+// Private SummaryFn
+
+func (summaryFn *SummaryFn) contentSources() []ContentSource {
+	if len(summaryFn.Sources) > 0 {
+		return summaryFn.Sources
+	}
+
+	if summaryFn.TwitterFnURL == "" {
+		return []ContentSource{}
+	}
+
+	return []ContentSource{
+		ContentSource{
+			Name: defaultSourceName,
+			URL:  summaryFn.TwitterFnURL,
+		},
+	}
+}
+
+func (summaryFn *SummaryFn) collectTweets(ctx context.Context, searchString string, count int) ([]Tweet, error) {
+	tweets, _, err := summaryFn.collectTweetsWithErrors(ctx, searchString, count)
+	return tweets, err
+}
+
+// collectTweetsWithErrors also returns the errors of the sources that failed
+// when at least one other source succeeded
+func (summaryFn *SummaryFn) collectTweetsWithErrors(ctx context.Context, searchString string, count int) ([]Tweet, []string, error) {
+	return summaryFn.collectSourcesTweets(ctx, summaryFn.contentSources(), searchString, count)
+}
+
+func (summaryFn *SummaryFn) collectSourcesTweets(ctx context.Context, sources []ContentSource, searchString string, count int) ([]Tweet, []string, error) {
+	if len(sources) == 0 {
+		return []Tweet{}, []string{}, errors.New("you must configure at least one content source or a TwitterFn URL")
+	}
+
+	results := make([]sourceResult, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source ContentSource) {
+			defer wg.Done()
+			tweets, err := summaryFn.searchTweets(ctx, source, searchString, sourceCount(source, sources, count))
+			results[i] = sourceResult{source: source, tweets: tweets, err: err}
+		}(i, source)
+	}
+	wg.Wait()
+
+	errorMessages := []string{}
+	for _, result := range results {
+		if result.err != nil {
+			log.Printf("Error collecting tweets from source '%s': %s\n", result.source.Name, result.err.Error())
+			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", result.source.Name, result.err.Error()))
+		}
+	}
+
+	if len(errorMessages) == len(results) {
+		return []Tweet{}, errorMessages, fmt.Errorf("error collecting tweets from all sources: %s", strings.Join(errorMessages, ", "))
+	}
+
+	return mergeSourceResults(results), errorMessages, nil
+}
+
+// Private functions
+
+func parseContentSources(sourceURLs map[string]string, sourceWeights map[string]int) []ContentSource {
+	names := []string{}
+	for name := range sourceURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sources := []ContentSource{}
+	for _, name := range names {
+		sources = append(sources, ContentSource{
+			Name:   name,
+			URL:    sourceURLs[name],
+			Weight: sourceWeights[name],
+		})
+	}
+	return sources
+}
+
+func sourceWeight(source ContentSource) int {
+	if source.Weight <= 0 {
+		return 1
+	}
+	return source.Weight
+}
+
+func sourceCount(source ContentSource, sources []ContentSource, count int) int {
+	totalWeight := 0
+	for _, s := range sources {
+		totalWeight += sourceWeight(s)
+	}
+
+	sCount := count * sourceWeight(source) / totalWeight
+	if sCount < 1 {
+		return 1
+	}
+	return sCount
+}
+
+// mergeSourceResults interleaves the tweets of each source proportionally to
+// its weight and drops tweets whose text or images were already seen
+func mergeSourceResults(results []sourceResult) []Tweet {
+	seenTexts := map[string]bool{}
+	seenImageURLs := map[string]bool{}
+
+	merged := []Tweet{}
+	for round := 0; ; round++ {
+		added := false
+		for _, result := range results {
+			weight := sourceWeight(result.source)
+			for i := round * weight; i < (round+1)*weight && i < len(result.tweets); i++ {
+				added = true
+
+				tweet := result.tweets[i]
+				tweet.Source = result.source.Name
+				if isDuplicateTweet(tweet, seenTexts, seenImageURLs) {
+					continue
+				}
+				merged = append(merged, tweet)
+			}
+		}
+
+		if !added {
+			return merged
+		}
+	}
+}
+
+func isDuplicateTweet(tweet Tweet, seenTexts map[string]bool, seenImageURLs map[string]bool) bool {
+	text := strings.ToLower(strings.Join(strings.Fields(tweet.Text), " "))
+	duplicate := text != "" && seenTexts[text]
+	for _, imageURL := range tweet.ImageURLs {
+		duplicate = duplicate || seenImageURLs[imageURL]
+	}
+
+	if !duplicate {
+		seenTexts[text] = true
+		for _, imageURL := range tweet.ImageURLs {
+			seenImageURLs[imageURL] = true
+		}
+	}
+	return duplicate
+}
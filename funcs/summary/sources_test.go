@@ -0,0 +1,77 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/maximilien/knfun/funcs/common"
+	"gotest.tools/assert"
+)
+
+func TestCollectTweetsFromSources(t *testing.T) {
+	twitterServer := newSourceServer(t, `[
+		{"text": "t1", "image-urls": ["http://img/1.jpg"]},
+		{"text": "t2", "image-urls": []},
+		{"text": "t3", "image-urls": ["http://img/3.jpg"]}
+	]`)
+	defer twitterServer.Close()
+
+	mastodonServer := newSourceServer(t, `[
+		{"text": "T1 ", "image-urls": []},
+		{"text": "m2", "image-urls": ["http://img/3.jpg"]},
+		{"text": "m3", "image-urls": ["http://img/m3.jpg"]}
+	]`)
+	defer mastodonServer.Close()
+
+	summaryFn := &SummaryFn{
+		CommonFn: common.CommonFn{SearchString: "#NBA"},
+		Sources: []ContentSource{
+			ContentSource{Name: "twitter", URL: twitterServer.URL, Weight: 2},
+			ContentSource{Name: "mastodon", URL: mastodonServer.URL + "?q=ignored", Params: map[string]string{"q": "knative"}},
+		},
+	}
+
+	tweets, err := summaryFn.collectTweets(context.Background(), summaryFn.SearchString, 6)
+	assert.NilError(t, err)
+
+	texts := []string{}
+	for _, tweet := range tweets {
+		texts = append(texts, fmt.Sprintf("%s:%s", tweet.Source, tweet.Text))
+	}
+	assert.DeepEqual(t, texts, []string{"twitter:t1", "twitter:t2", "twitter:t3", "mastodon:m3"})
+}
+
+func TestCollectTweetsWithFailingSource(t *testing.T) {
+	server := newSourceServer(t, `[{"text": "t1", "image-urls": []}]`)
+	defer server.Close()
+
+	summaryFn := &SummaryFn{
+		Sources: []ContentSource{
+			ContentSource{Name: "twitter", URL: server.URL},
+			ContentSource{Name: "broken", URL: "http://127.0.0.1:0"},
+		},
+	}
+
+	tweets, err := summaryFn.collectTweets(context.Background(), "NBA", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tweets), 1)
+
+	summaryFn.Sources = summaryFn.Sources[1:]
+	_, err = summaryFn.collectTweets(context.Background(), "NBA", 10)
+	assert.ErrorContains(t, err, "broken")
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright © 2019 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+ * Minimal jQuery word cloud: $('#cloud').wordCloud(words) renders the
+ * {text, weight, link} words with one of ten sizes based on their weight,
+ * heaviest first. Calling it again re-renders the cloud with the new words.
+ */
+(function($) {
+  $.fn.wordCloud = function(words) {
+    var sorted = words.slice().sort(function(a, b) {
+      return b.weight - a.weight;
+    });
+    var max = sorted.length > 0 ? sorted[0].weight : 0;
+    var min = sorted.length > 0 ? sorted[sorted.length - 1].weight : 0;
+
+    return this.each(function() {
+      var cloud = $(this).empty().addClass('wordcloud');
+      sorted.forEach(function(word) {
+        var size = max > min ? Math.round((word.weight - min) / (max - min) * 9) + 1 : 5;
+        var element = word.link ? $('<a>').attr('href', word.link) : $('<span>');
+        element.text(word.text).attr('title', word.weight).addClass('w' + size);
+        cloud.append(element).append(' ');
+      });
+    });
+  };
+})(jQuery);
@@ -0,0 +1,163 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+const maxCoOccurrences = 20
+
+var statsFuncs = template.FuncMap{
+	"join": strings.Join,
+	"percent": func(share float64) float64 {
+		return share * 100
+	},
+	"commonLabel": isCommonLabel,
+}
+
+type LabelStats struct {
+	Name      string  `yaml:"name" json:"name"`
+	Count     int     `yaml:"count" json:"count"`
+	MeanScore float64 `yaml:"mean-score" json:"mean-score"`
+	MaxScore  float64 `yaml:"max-score" json:"max-score"`
+}
+
+type LabelCoOccurrence struct {
+	Labels []string `yaml:"labels" json:"labels"`
+	Count  int      `yaml:"count" json:"count"`
+}
+
+type SummaryStats struct {
+	TweetCount       int                 `yaml:"tweet-count" json:"tweet-count"`
+	TweetsWithImages int                 `yaml:"tweets-with-images" json:"tweets-with-images"`
+	ImagesShare      float64             `yaml:"images-share" json:"images-share"`
+	ImageCount       int                 `yaml:"image-count" json:"image-count"`
+	Labels           []LabelStats        `yaml:"labels" json:"labels"`
+	CoOccurrences    []LabelCoOccurrence `yaml:"co-occurrences" json:"co-occurrences"`
+}
+
+// Private functions
+
+// computeStats aggregates the labels of all classified images. A label counts
+// once per image, and two labels co-occur when they classify the same image
+func computeStats(tweetCount int, classifiedTweets []ClassifiedTweet) SummaryStats {
+	stats := SummaryStats{
+		TweetCount:       tweetCount,
+		TweetsWithImages: len(classifiedTweets),
+		Labels:           []LabelStats{},
+		CoOccurrences:    []LabelCoOccurrence{},
+	}
+
+	if tweetCount > 0 {
+		stats.ImagesShare = float64(len(classifiedTweets)) / float64(tweetCount)
+	}
+
+	labelStats := map[string]*LabelStats{}
+	coOccurrences := map[string]*LabelCoOccurrence{}
+	for _, cTweet := range classifiedTweets {
+		for _, cImage := range cTweet.ClassifiedImages {
+			stats.ImageCount++
+
+			names := []string{}
+			scores := map[string]float64{}
+			for _, label := range cImage.Labels {
+				name := strings.TrimSpace(label.Name)
+				if name == "" {
+					continue
+				}
+				if _, ok := scores[name]; !ok {
+					names = append(names, name)
+				}
+				if float64(label.Score) > scores[name] {
+					scores[name] = float64(label.Score)
+				}
+			}
+			sort.Strings(names)
+
+			for i, name := range names {
+				lStats, ok := labelStats[name]
+				if !ok {
+					lStats = &LabelStats{Name: name}
+					labelStats[name] = lStats
+				}
+				lStats.Count++
+				lStats.MeanScore += scores[name]
+				if scores[name] > lStats.MaxScore {
+					lStats.MaxScore = scores[name]
+				}
+
+				for _, other := range names[i+1:] {
+					key := name + "\x00" + other
+					coOccurrence, ok := coOccurrences[key]
+					if !ok {
+						coOccurrence = &LabelCoOccurrence{Labels: []string{name, other}}
+						coOccurrences[key] = coOccurrence
+					}
+					coOccurrence.Count++
+				}
+			}
+		}
+	}
+
+	for _, lStats := range labelStats {
+		lStats.MeanScore = lStats.MeanScore / float64(lStats.Count)
+		stats.Labels = append(stats.Labels, *lStats)
+	}
+	sort.Slice(stats.Labels, func(i, j int) bool {
+		if stats.Labels[i].Count != stats.Labels[j].Count {
+			return stats.Labels[i].Count > stats.Labels[j].Count
+		}
+		return stats.Labels[i].Name < stats.Labels[j].Name
+	})
+
+	for _, coOccurrence := range coOccurrences {
+		stats.CoOccurrences = append(stats.CoOccurrences, *coOccurrence)
+	}
+	sort.Slice(stats.CoOccurrences, func(i, j int) bool {
+		if stats.CoOccurrences[i].Count != stats.CoOccurrences[j].Count {
+			return stats.CoOccurrences[i].Count > stats.CoOccurrences[j].Count
+		}
+		return strings.Join(stats.CoOccurrences[i].Labels, ",") < strings.Join(stats.CoOccurrences[j].Labels, ",")
+	})
+	if len(stats.CoOccurrences) > maxCoOccurrences {
+		stats.CoOccurrences = stats.CoOccurrences[:maxCoOccurrences]
+	}
+
+	return stats
+}
+
+// Public SummaryStats
+
+func (stats SummaryStats) ToText() string {
+	sb := bytes.NewBufferString("")
+	sb.WriteString("\n📊 stats\n")
+	sb.WriteString(fmt.Sprintf("tweets: `%d`, with images: `%d` (`%1.1f%%`), images: `%d`\n", stats.TweetCount, stats.TweetsWithImages, stats.ImagesShare*100, stats.ImageCount))
+	sb.WriteString("------\n")
+	for _, lStats := range stats.Labels {
+		sb.WriteString(fmt.Sprintf("🏷  `%s` in `%d` images, mean `%1.3f`, max `%1.3f` confidence\n", lStats.Name, lStats.Count, lStats.MeanScore, lStats.MaxScore))
+	}
+	if len(stats.CoOccurrences) > 0 {
+		sb.WriteString("------\n")
+	}
+	for _, coOccurrence := range stats.CoOccurrences {
+		sb.WriteString(fmt.Sprintf("🔗 `%s` together in `%d` images\n", strings.Join(coOccurrence.Labels, "` and `"), coOccurrence.Count))
+	}
+	return sb.String()
+}
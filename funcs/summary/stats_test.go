@@ -0,0 +1,85 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	classifiedTweets := []ClassifiedTweet{
+		ClassifiedTweet{Text: "t1", ClassifiedImages: []ClassifiedImage{
+			ClassifiedImage{ImageURL: "1.jpg", Labels: []Label{
+				Label{Name: "ball", Score: 0.9},
+				Label{Name: "court", Score: 0.5},
+			}},
+			ClassifiedImage{ImageURL: "2.jpg", Labels: []Label{
+				Label{Name: "ball", Score: 0.7},
+			}},
+		}},
+		ClassifiedTweet{Text: "t2", ClassifiedImages: []ClassifiedImage{
+			ClassifiedImage{ImageURL: "3.jpg", Labels: []Label{
+				Label{Name: "court", Score: 0.6},
+				Label{Name: "ball", Score: 0.5},
+				Label{Name: "ball", Score: 0.8},
+				Label{Name: "player", Score: 0.4},
+			}},
+		}},
+	}
+
+	stats := computeStats(4, classifiedTweets)
+	assert.Equal(t, stats.TweetCount, 4)
+	assert.Equal(t, stats.TweetsWithImages, 2)
+	assert.Equal(t, stats.ImagesShare, 0.5)
+	assert.Equal(t, stats.ImageCount, 3)
+
+	assert.Equal(t, len(stats.Labels), 3)
+	assert.Equal(t, stats.Labels[0].Name, "ball")
+	assert.Equal(t, stats.Labels[0].Count, 3)
+	assert.Assert(t, stats.Labels[0].MaxScore > 0.89 && stats.Labels[0].MaxScore < 0.91)
+	assert.Assert(t, stats.Labels[0].MeanScore > 0.79 && stats.Labels[0].MeanScore < 0.81)
+	assert.Equal(t, stats.Labels[1].Name, "court")
+	assert.Equal(t, stats.Labels[2].Name, "player")
+
+	assert.DeepEqual(t, stats.CoOccurrences, []LabelCoOccurrence{
+		LabelCoOccurrence{Labels: []string{"ball", "court"}, Count: 2},
+		LabelCoOccurrence{Labels: []string{"ball", "player"}, Count: 1},
+		LabelCoOccurrence{Labels: []string{"court", "player"}, Count: 1},
+	})
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	stats := computeStats(0, []ClassifiedTweet{})
+	assert.Equal(t, stats.ImagesShare, 0.0)
+	assert.DeepEqual(t, stats.Labels, []LabelStats{})
+}
+
+func TestLayoutRendersStats(t *testing.T) {
+	classifiedTweets := []ClassifiedTweet{
+		ClassifiedTweet{Text: "t1", ClassifiedImages: []ClassifiedImage{
+			ClassifiedImage{ImageURL: "1.jpg", Labels: []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "court", Score: 0.5}}},
+		}},
+	}
+
+	out := bytes.NewBufferString("")
+	err := NewSummaryTemplates("", false, statsFuncs).Execute(out, layoutTemplate, SummaryPageData{ClassifiedTweets: classifiedTweets, Stats: computeStats(2, classifiedTweets)})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(out.String(), "1 of 2 tweets with images (50.0%)"))
+	assert.Assert(t, strings.Contains(out.String(), "<td>ball, court</td>"))
+}
@@ -0,0 +1,456 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltStoreBackend   = "bolt"
+	memoryStoreBackend = "memory"
+
+	searchesBucket = "searches"
+	tweetsBucket   = "tweets"
+	imagesBucket   = "images"
+
+	searchIDFormat = "20060102T150405.000000000Z"
+	storeTimeout   = 5 * time.Second
+)
+
+// StoredSearch is a past summary, its classified tweets are stored once per
+// tweet key and referenced by all the searches that found them, while their
+// classified images are stored per search and tweet key
+type StoredSearch struct {
+	ID         string       `yaml:"id" json:"id"`
+	SearchedAt time.Time    `yaml:"searched-at" json:"searched-at"`
+	Query      SummaryQuery `yaml:"query" json:"query"`
+	TweetCount int          `yaml:"tweet-count" json:"tweet-count"`
+	TweetKeys  []string     `yaml:"tweet-keys" json:"tweet-keys"`
+	Errors     []string     `yaml:"errors,omitempty" json:"errors,omitempty"`
+}
+
+type StoredSearches []StoredSearch
+
+type StoredTweet struct {
+	Key       string        `yaml:"key" json:"key"`
+	ID        string        `yaml:"id,omitempty" json:"id,omitempty"`
+	Text      string        `yaml:"text" json:"text"`
+	Source    string        `yaml:"source" json:"source"`
+	CreatedAt string        `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+	Analysis  *TextAnalysis `yaml:"analysis,omitempty" json:"analysis,omitempty"`
+	ImageURLs []string      `yaml:"image-urls" json:"image-urls"`
+	FirstSeen time.Time     `yaml:"first-seen" json:"first-seen"`
+	LastSeen  time.Time     `yaml:"last-seen" json:"last-seen"`
+}
+
+type StoredImage struct {
+	ClassifiedImage `yaml:",inline"`
+	ClassifiedAt    time.Time `yaml:"classified-at" json:"classified-at"`
+}
+
+// Store persists the summaries in a BoltDB file or in memory
+type Store struct {
+	backend storeBackend
+}
+
+type storeBackend interface {
+	update(fn func(tx storeTx) error) error
+	view(fn func(tx storeTx) error) error
+	close() error
+}
+
+type storeTx interface {
+	get(bucket string, key string) []byte
+	put(bucket string, key string, value []byte) error
+	// forEach visits the keys of the bucket in descending order until fn
+	// returns false
+	forEach(bucket string, fn func(key string, value []byte) bool) error
+}
+
+func NewStore(backend string, path string) (*Store, error) {
+	switch backend {
+	case boltStoreBackend:
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: storeTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("error opening store '%s': %s", path, err.Error())
+		}
+
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, bucket := range []string{searchesBucket, tweetsBucket, imagesBucket} {
+				if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error initializing store '%s': %s", path, err.Error())
+		}
+
+		return &Store{backend: &boltBackend{db: db}}, nil
+	case memoryStoreBackend:
+		return &Store{backend: &memoryBackend{buckets: map[string]map[string][]byte{}}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown store backend '%s', use `%s` or `%s`", backend, boltStoreBackend, memoryStoreBackend)
+}
+
+// SaveSearch stores the summary with its classified images, its tweets are
+// deduplicated with the ones of previous searches
+func (store *Store) SaveSearch(searchedAt time.Time, summaryData SummaryData) (StoredSearch, error) {
+	searchedAt = searchedAt.UTC()
+	storedSearch := StoredSearch{
+		SearchedAt: searchedAt,
+		Query:      summaryData.Query,
+		TweetCount: summaryData.Stats.TweetCount,
+		TweetKeys:  []string{},
+		Errors:     summaryData.Errors,
+	}
+	storedSearch.Query.View = nil
+
+	err := store.backend.update(func(tx storeTx) error {
+		storedSearch.ID = searchedAt.Format(searchIDFormat)
+		for tx.get(searchesBucket, storedSearch.ID) != nil {
+			searchedAt = searchedAt.Add(time.Nanosecond)
+			storedSearch.ID = searchedAt.Format(searchIDFormat)
+		}
+		storedSearch.SearchedAt = searchedAt
+
+		for _, cTweet := range summaryData.ClassifiedTweets {
+			storedTweet := StoredTweet{FirstSeen: searchedAt}
+			key := tweetKey(cTweet)
+			if value := tx.get(tweetsBucket, key); value != nil {
+				err := json.Unmarshal(value, &storedTweet)
+				if err != nil {
+					return err
+				}
+			}
+
+			storedTweet.Key = key
+			storedTweet.ID = cTweet.ID
+			storedTweet.Text = cTweet.Text
+			storedTweet.Source = cTweet.Source
+			storedTweet.CreatedAt = cTweet.CreatedAt
+			storedTweet.Analysis = cTweet.Analysis
+			storedTweet.ImageURLs = []string{}
+			storedTweet.LastSeen = searchedAt
+
+			storedImages := []StoredImage{}
+			for _, cImage := range cTweet.ClassifiedImages {
+				storedTweet.ImageURLs = append(storedTweet.ImageURLs, cImage.ImageURL)
+				storedImages = append(storedImages, StoredImage{ClassifiedImage: cImage, ClassifiedAt: searchedAt})
+			}
+
+			err := putJSON(tx, imagesBucket, imagesKey(storedSearch.ID, key), storedImages)
+			if err != nil {
+				return err
+			}
+
+			err = putJSON(tx, tweetsBucket, key, storedTweet)
+			if err != nil {
+				return err
+			}
+			storedSearch.TweetKeys = append(storedSearch.TweetKeys, key)
+		}
+
+		return putJSON(tx, searchesBucket, storedSearch.ID, storedSearch)
+	})
+	if err != nil {
+		return StoredSearch{}, fmt.Errorf("error saving search: %s", err.Error())
+	}
+
+	return storedSearch, nil
+}
+
+// Searches returns the most recent searches first, all when limit is 0
+func (store *Store) Searches(limit int) (StoredSearches, error) {
+	storedSearches := StoredSearches{}
+	err := store.backend.view(func(tx storeTx) error {
+		var err error
+		tx.forEach(searchesBucket, func(key string, value []byte) bool {
+			storedSearch := StoredSearch{}
+			err = json.Unmarshal(value, &storedSearch)
+			if err != nil {
+				return false
+			}
+
+			storedSearches = append(storedSearches, storedSearch)
+			return limit <= 0 || len(storedSearches) < limit
+		})
+		return err
+	})
+	if err != nil {
+		return StoredSearches{}, fmt.Errorf("error listing searches: %s", err.Error())
+	}
+
+	return storedSearches, nil
+}
+
+// LoadSearch returns the stored search and its summary with the images as
+// they were classified by this search, the text analyses of the tweets are the
+// ones of the latest search that found them
+func (store *Store) LoadSearch(id string) (StoredSearch, SummaryData, error) {
+	storedSearch := StoredSearch{}
+	classifiedTweets := []ClassifiedTweet{}
+
+	err := store.backend.view(func(tx storeTx) error {
+		value := tx.get(searchesBucket, id)
+		if value == nil {
+			return fmt.Errorf("%w: no search with ID '%s'", errSearchNotFound, id)
+		}
+
+		err := json.Unmarshal(value, &storedSearch)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range storedSearch.TweetKeys {
+			storedTweet := StoredTweet{}
+			err := getJSON(tx, tweetsBucket, key, &storedTweet)
+			if err != nil {
+				return err
+			}
+
+			cTweet := ClassifiedTweet{
+				ID:               storedTweet.ID,
+				Text:             storedTweet.Text,
+				Source:           storedTweet.Source,
+				CreatedAt:        storedTweet.CreatedAt,
+				Analysis:         storedTweet.Analysis,
+				ClassifiedImages: []ClassifiedImage{},
+			}
+
+			storedImages := []StoredImage{}
+			err = getJSON(tx, imagesBucket, imagesKey(storedSearch.ID, key), &storedImages)
+			if err != nil {
+				return err
+			}
+			for _, storedImage := range storedImages {
+				cTweet.ClassifiedImages = append(cTweet.ClassifiedImages, storedImage.ClassifiedImage)
+			}
+			classifiedTweets = append(classifiedTweets, cTweet)
+		}
+		return nil
+	})
+	if err != nil {
+		return StoredSearch{}, SummaryData{}, err
+	}
+
+	return storedSearch, SummaryData{
+		Query:            storedSearch.Query,
+		Stats:            computeStats(storedSearch.TweetCount, classifiedTweets),
+		ClassifiedTweets: classifiedTweets,
+		Errors:           storedSearch.Errors,
+	}, nil
+}
+
+func (store *Store) Close() error {
+	return store.backend.close()
+}
+
+// StoredSearches
+
+func (storedSearches StoredSearches) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, storedSearch := range storedSearches {
+		sb.WriteString(fmt.Sprintf("🔎 %s  %s  `%s`  %d classified of %d tweets", storedSearch.ID, storedSearch.SearchedAt.Format(time.RFC3339), storedSearch.Query.SearchString, len(storedSearch.TweetKeys), storedSearch.TweetCount))
+		if len(storedSearch.Errors) > 0 {
+			sb.WriteString(fmt.Sprintf(", %d errors", len(storedSearch.Errors)))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Private boltBackend
+
+type boltBackend struct {
+	db *bolt.DB
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (backend *boltBackend) update(fn func(tx storeTx) error) error {
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+func (backend *boltBackend) view(fn func(tx storeTx) error) error {
+	return backend.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+func (backend *boltBackend) close() error {
+	return backend.db.Close()
+}
+
+func (tx *boltTx) get(bucket string, key string) []byte {
+	value := tx.tx.Bucket([]byte(bucket)).Get([]byte(key))
+	if value == nil {
+		return nil
+	}
+	// values are only valid during the transaction
+	return append([]byte{}, value...)
+}
+
+func (tx *boltTx) put(bucket string, key string, value []byte) error {
+	return tx.tx.Bucket([]byte(bucket)).Put([]byte(key), value)
+}
+
+func (tx *boltTx) forEach(bucket string, fn func(key string, value []byte) bool) error {
+	cursor := tx.tx.Bucket([]byte(bucket)).Cursor()
+	for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+		if !fn(string(key), value) {
+			break
+		}
+	}
+	return nil
+}
+
+// Private memoryBackend
+
+type memoryBackend struct {
+	mutex   sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// memoryTx reads the committed buckets and writes to copies of the buckets it
+// changes, which replace the committed ones unless the update fails
+type memoryTx struct {
+	buckets map[string]map[string][]byte
+	written map[string]map[string][]byte
+}
+
+func (backend *memoryBackend) update(fn func(tx storeTx) error) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	tx := &memoryTx{buckets: backend.buckets, written: map[string]map[string][]byte{}}
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	for name, bucket := range tx.written {
+		backend.buckets[name] = bucket
+	}
+	return nil
+}
+
+func (backend *memoryBackend) view(fn func(tx storeTx) error) error {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	return fn(&memoryTx{buckets: backend.buckets})
+}
+
+func (backend *memoryBackend) close() error {
+	return nil
+}
+
+func (tx *memoryTx) get(bucket string, key string) []byte {
+	return tx.bucket(bucket)[key]
+}
+
+func (tx *memoryTx) put(bucket string, key string, value []byte) error {
+	written, ok := tx.written[bucket]
+	if !ok {
+		written = map[string][]byte{}
+		for key, value := range tx.buckets[bucket] {
+			written[key] = value
+		}
+		tx.written[bucket] = written
+	}
+	written[key] = value
+	return nil
+}
+
+func (tx *memoryTx) forEach(bucket string, fn func(key string, value []byte) bool) error {
+	values := tx.bucket(bucket)
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	for _, key := range keys {
+		if !fn(key, values[key]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (tx *memoryTx) bucket(bucket string) map[string][]byte {
+	if written, ok := tx.written[bucket]; ok {
+		return written
+	}
+	return tx.buckets[bucket]
+}
+
+// Private functions
+
+// tweetKey identifies a tweet by its source and ID, or by a hash of its text
+// and images for the sources without IDs
+func tweetKey(cTweet ClassifiedTweet) string {
+	if cTweet.ID != "" {
+		return fmt.Sprintf("%s/%s", cTweet.Source, cTweet.ID)
+	}
+
+	hash := sha1.New()
+	hash.Write([]byte(cTweet.Text))
+	for _, cImage := range cTweet.ClassifiedImages {
+		hash.Write([]byte("\n" + cImage.ImageURL))
+	}
+	return fmt.Sprintf("%s/sha1:%s", cTweet.Source, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// imagesKey identifies the classified images of a tweet in a search, since
+// later searches may classify them with other labels
+func imagesKey(searchID string, tweetKey string) string {
+	return fmt.Sprintf("%s/%s", searchID, tweetKey)
+}
+
+func putJSON(tx storeTx, bucket string, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return tx.put(bucket, key, data)
+}
+
+func getJSON(tx storeTx, bucket string, key string, value interface{}) error {
+	data := tx.get(bucket, key)
+	if data == nil {
+		return fmt.Errorf("no '%s' in %s", key, bucket)
+	}
+	return json.Unmarshal(data, value)
+}
@@ -0,0 +1,210 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestStoreBackends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summary-store")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, backend := range []string{memoryStoreBackend, boltStoreBackend} {
+		store, err := NewStore(backend, filepath.Join(dir, "summary.db"))
+		assert.NilError(t, err, backend)
+		testStore(t, store)
+		assert.NilError(t, store.Close())
+	}
+
+	_, err = NewStore("sqlite", "")
+	assert.ErrorContains(t, err, "unknown store backend 'sqlite'")
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summary-store")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "summary.db")
+	store, err := NewStore(boltStoreBackend, path)
+	assert.NilError(t, err)
+
+	storedSearch, err := store.SaveSearch(time.Now(), newStoreSummaryData("NBA", "t1"))
+	assert.NilError(t, err)
+	assert.NilError(t, store.Close())
+
+	store, err = NewStore(boltStoreBackend, path)
+	assert.NilError(t, err)
+	defer store.Close()
+
+	_, summaryData, err := store.LoadSearch(storedSearch.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, summaryData.ClassifiedTweets[0].Text, "t1")
+}
+
+func TestHistoryHandler(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	summaryFn.StoreBackend = memoryStoreBackend
+	assert.NilError(t, summaryFn.openStore())
+	defer summaryFn.closeStore()
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history?o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	storedSearches := StoredSearches{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &storedSearches))
+	assert.Equal(t, len(storedSearches), 1)
+	assert.Equal(t, storedSearches[0].Query.SearchString, "NBA")
+	assert.Equal(t, storedSearches[0].TweetCount, 3)
+	assert.Equal(t, len(storedSearches[0].Errors), 2)
+
+	// the classifier is no longer called to render a stored search
+	summaryFn.WatsonFnURL = "http://127.0.0.1:0"
+
+	recorder = httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history/"+storedSearches[0].ID+"?o=json", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 1)
+	assert.Equal(t, summaryData.ClassifiedTweets[0].ClassifiedImages[0].Labels[0].Name, "ball")
+	assert.Equal(t, summaryData.Stats.TweetCount, 3)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `<a href="/history/`+storedSearches[0].ID+`">NBA</a>`))
+
+	recorder = httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history/missing", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	// the server's files can't be read with the CLI only outputs
+	recorder = httptest.NewRecorder()
+	summaryFn.HistoryHandler(recorder, httptest.NewRequest("GET", "/history?o=go-template-file%3D%2Fetc%2Fpasswd", nil))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Equal(t, recorder.Body.String(), "the go-template-file output is only available on the command line\n")
+}
+
+func TestMemoryStoreRollback(t *testing.T) {
+	store, err := NewStore(memoryStoreBackend, "")
+	assert.NilError(t, err)
+	defer store.Close()
+
+	_, err = store.SaveSearch(time.Now(), newStoreSummaryData("NBA", "t1"))
+	assert.NilError(t, err)
+
+	err = store.backend.update(func(tx storeTx) error {
+		assert.NilError(t, tx.put(tweetsBucket, "twitter/t1", []byte("{}")))
+		assert.DeepEqual(t, tx.get(tweetsBucket, "twitter/t1"), []byte("{}"))
+		return errors.New("failed")
+	})
+	assert.ErrorContains(t, err, "failed")
+
+	storedSearches, err := store.Searches(0)
+	assert.NilError(t, err)
+	_, summaryData, err := store.LoadSearch(storedSearches[0].ID)
+	assert.NilError(t, err)
+	assert.Equal(t, summaryData.ClassifiedTweets[0].Text, "t1")
+}
+
+func TestTweetKey(t *testing.T) {
+	assert.Equal(t, tweetKey(ClassifiedTweet{ID: "1", Source: "twitter"}), "twitter/1")
+
+	noID := ClassifiedTweet{Text: "t", Source: "rss", ClassifiedImages: []ClassifiedImage{ClassifiedImage{ImageURL: "1.jpg"}}}
+	assert.Assert(t, strings.HasPrefix(tweetKey(noID), "rss/sha1:"))
+	assert.Equal(t, tweetKey(noID), tweetKey(noID))
+
+	noID.ClassifiedImages[0].ImageURL = "2.jpg"
+	assert.Assert(t, tweetKey(noID) != tweetKey(ClassifiedTweet{Text: "t", Source: "rss"}))
+}
+
+// Private
+
+func testStore(t *testing.T, store *Store) {
+	searchedAt := time.Date(2019, 11, 21, 19, 0, 0, 0, time.UTC)
+
+	first, err := store.SaveSearch(searchedAt, newStoreSummaryData("NBA", "t1", "t2"))
+	assert.NilError(t, err)
+	assert.Equal(t, first.ID, "20191121T190000.000000000Z")
+
+	second, err := store.SaveSearch(searchedAt, newStoreSummaryData("Knative", "t2", "t3"))
+	assert.NilError(t, err)
+	assert.Equal(t, second.ID, "20191121T190000.000000001Z")
+
+	storedSearches, err := store.Searches(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(storedSearches), 2)
+	assert.Equal(t, storedSearches[0].Query.SearchString, "Knative")
+	assert.Equal(t, storedSearches[1].Query.SearchString, "NBA")
+	assert.Assert(t, storedSearches[1].Query.View == nil)
+
+	storedSearches, err = store.Searches(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(storedSearches), 1)
+
+	// the images classified again keep their labels in the first search
+	third := newStoreSummaryData("NBA", "t1")
+	third.ClassifiedTweets[0].ClassifiedImages[0].Labels[0].Name = "basketball"
+	_, err = store.SaveSearch(searchedAt, third)
+	assert.NilError(t, err)
+
+	storedSearch, summaryData, err := store.LoadSearch(first.ID)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, storedSearch.TweetKeys, []string{"twitter/t1", "twitter/t2"})
+	assert.Equal(t, summaryData.Query.SearchString, "NBA")
+	assert.Equal(t, summaryData.Stats.TweetCount, 5)
+	assert.Equal(t, summaryData.Stats.ImageCount, 2)
+	assert.DeepEqual(t, summaryData.ClassifiedTweets, newStoreSummaryData("NBA", "t1", "t2").ClassifiedTweets)
+	assert.DeepEqual(t, summaryData.Errors, []string{"broken: unavailable"})
+
+	err = store.backend.view(func(tx storeTx) error {
+		storedTweet := StoredTweet{}
+		assert.NilError(t, getJSON(tx, tweetsBucket, "twitter/t2", &storedTweet))
+		assert.Equal(t, storedTweet.FirstSeen, first.SearchedAt)
+		assert.Equal(t, storedTweet.LastSeen, second.SearchedAt)
+		return nil
+	})
+	assert.NilError(t, err)
+
+	_, _, err = store.LoadSearch("missing")
+	assert.ErrorContains(t, err, "no search with ID 'missing'")
+	assert.Equal(t, storeErrorStatus(err), http.StatusNotFound)
+}
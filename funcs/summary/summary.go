@@ -12,49 +12,103 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package summary
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"html/template"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
-	"path"
-	"time"
+	"strings"
 
+	"github.com/maximilien/knfun/funcs/client"
 	"github.com/maximilien/knfun/funcs/common"
-
-	yaml "gopkg.in/yaml.v2"
 )
 
 type ClassifiedTweet struct {
-	Text             string            `json:"text"`
-	ClassifiedImages []ClassifiedImage `json:"classified-images"`
+	ID               string            `yaml:"id,omitempty" json:"id,omitempty"`
+	Text             string            `yaml:"text" json:"text"`
+	Source           string            `yaml:"source" json:"source"`
+	CreatedAt        string            `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+	Analysis         *TextAnalysis     `yaml:"analysis,omitempty" json:"analysis,omitempty"`
+	ClassifiedImages []ClassifiedImage `yaml:"classified-images" json:"classified-images"`
 }
 
-type Tweet struct {
-	Text      string   `json:"text"`
-	ImageURLs []string `json:"image-urls"`
-}
+// Tweet, ClassifiedImage, and Label are the types of the func clients
+type (
+	Tweet           = client.Tweet
+	ClassifiedImage = client.ClassifiedImage
+	Label           = client.Label
+)
+
+type SummaryQuery struct {
+	SearchString string   `yaml:"search-string" json:"search-string"`
+	Count        int      `yaml:"count" json:"count"`
+	Sources      []string `yaml:"sources" json:"sources"`
+	Classifier   string   `yaml:"classifier,omitempty" json:"classifier,omitempty"`
+	MaxLabels    int      `yaml:"max-labels" json:"max-labels"`
+	MinScore     float64  `yaml:"min-score" json:"min-score"`
 
-type ClassifiedImage struct {
-	ImageURL string  `json:"ImageURL"`
-	Labels   []Label `json:"labels"`
+	View *ViewOptions `yaml:"view,omitempty" json:"view,omitempty"`
 }
 
-type Label struct {
-	Name  string  `json:"name"`
-	Score float32 `json:"score"`
+type SummaryData struct {
+	Query            SummaryQuery       `yaml:"query" json:"query"`
+	Stats            SummaryStats       `yaml:"stats" json:"stats"`
+	ClassifiedTweets []ClassifiedTweet  `yaml:"classified-tweets" json:"classified-tweets"`
+	Pagination       *SummaryPagination `yaml:"pagination,omitempty" json:"pagination,omitempty"`
+	Errors           []string           `yaml:"errors,omitempty" json:"errors,omitempty"`
 }
 
 type SummaryFn struct {
 	common.CommonFn
+	common.ClassifyFn
 
 	TwitterFnURL string
 	WatsonFnURL  string
+	NLUFnURL     string
+
+	Classifier        string
+	Classifiers       []ClassifierBackend
+	CompareClassifier string
+	ClassifierHeaders map[string]string
+	ClassifyTimeout   int
+	ClassifyCacheTTL  int
+
+	Sources []ContentSource
+
+	ViewOptions ViewOptions
+
+	TemplatesDir string
+	Dev          bool
+
+	StoreBackend string
+	StorePath    string
+
+	Watch           []string
+	PollInterval    int
+	PollWindow      int
+	PollMaxSearches int
+
+	AlertRulesFile string
+
+	Async            bool
+	JobWorkers       int
+	JobQueue         int
+	JobCallbackHosts []string
+
+	sourceURLs     map[string]string
+	sourceWeights  map[string]int
+	classifierURLs map[string]string
+	templates      *SummaryTemplates
+	store          *Store
+	poller         *Poller
+	alerts         *AlertEngine
+	jobs           *JobManager
+	classifyCache  *classifyCache
 }
 
 type SummaryPageData struct {
@@ -62,71 +116,111 @@ type SummaryPageData struct {
 
 	Tweets           []Tweet
 	ClassifiedTweets []ClassifiedTweet
+	Stats            SummaryStats
+	Errors           []string
+
+	ViewOptions ViewOptions
+	Pagination  SummaryPagination
+	Links       SummaryLinks
+
+	EventsURL string
+	Poll      bool
+	Analyze   bool
+
+	// Context is the context of the request, passed to the template funcs
+	// calling other funcs, e.g., ClassifyImage
+	Context context.Context
 
-	WatsonFnURL string
-	Timeout     int
+	Classifier string
+
+	MaxLabels int
+	MinScore  float64
 }
 
-func (summaryFn *SummaryFn) Summary() ([]ClassifiedTweet, error) {
-	return summaryFn.collectClassifiedTweets()
+// Summary returns the partial summary with its errors when some sources or
+// images fail, and an error only when no tweets could be collected
+func (summaryFn *SummaryFn) Summary(ctx context.Context) (SummaryData, error) {
+	return summaryFn.classifiedSummary(ctx, "")
 }
 
 func (summaryFn *SummaryFn) SummaryHandler(writer http.ResponseWriter, request *http.Request) {
-	summaryFn.InitCommonQueryParams(request)
-	log.Printf("SummaryFn.Summary: s=\"%s\", c=\"%d\", o=\"%s\"", summaryFn.SearchString, summaryFn.Count, summaryFn.Output)
+	fn := summaryFn.requestFn(request)
+	output := fn.NegotiateOutput(request, "html")
+	log.Printf("SummaryFn.Summary: s=\"%s\", c=\"%d\", o=\"%s\"", fn.SearchString, fn.Count, output)
 
-	classifiedTweets, err := summaryFn.Summary()
-	if err != nil {
-		log.Printf("Error collecting classified tweets: %s\n", err.Error())
+	classifier, ok := fn.classifierQueryParam(writer, request)
+	if !ok {
 		return
 	}
 
-	tmpl := template.Must(template.ParseFiles("./funcs/summary/layout.html"))
-	data := SummaryPageData{
-		PageTitle:        fmt.Sprintf("Recent tweets with images for search `%s`", summaryFn.SearchString),
-		ClassifiedTweets: classifiedTweets,
+	if compare := fn.ExtractQueryStringParam(request, []string{"compare"}, ""); compare != "" {
+		fn.writeComparison(request.Context(), writer, output, classifier, compare)
+		return
 	}
 
-	err = tmpl.Execute(writer, data)
-	if err != nil {
-		log.Printf("Error executing template with classified tweets: %s\n", err.Error())
-		return
+	viewOptions := fn.viewQueryParams(request)
+
+	status := http.StatusOK
+	summaryData, polled := SummaryData{}, false
+	if fn.classifierName(classifier) == fn.classifierName("") {
+		summaryData, polled = fn.polledSummary(fn.SearchString)
+	}
+	if !polled {
+		var err error
+		summaryData, err = fn.classifiedSummary(request.Context(), classifier)
+		if err != nil {
+			log.Printf("Error collecting classified tweets: %s\n", err.Error())
+			status = http.StatusBadGateway
+		} else {
+			fn.summarized(summaryData)
+		}
 	}
+
+	fn.writeSummary(writer, request, status, output, viewOptions.Apply(summaryData))
 }
 
 func (summaryFn *SummaryFn) SummaryAsyncHandler(writer http.ResponseWriter, request *http.Request) {
-	summaryFn.InitCommonQueryParams(request)
-	log.Printf("SummaryFn.Summary: s=\"%s\", c=\"%d\", o=\"%s\"", summaryFn.SearchString, summaryFn.Count, summaryFn.Output)
-
-	tweets, err := summaryFn.searchTweets(summaryFn.SearchString, summaryFn.Count)
-	if err != nil {
-		log.Printf("Error collecting tweets: %s\n", err.Error())
+	if summaryFn.NegotiateOutput(request, "html") != "html" || summaryFn.ExtractQueryStringParam(request, []string{"compare"}, "") != "" {
+		summaryFn.SummaryHandler(writer, request)
 		return
 	}
 
-	tmplName := path.Base("./funcs/summary/async_layout.html")
-	tmpl := template.New(tmplName)
-	tmpl.Funcs(template.FuncMap{
-		"ClassifyImage": func(watsonFnURL string, imageURL string, timeout int) (ClassifiedImage, error) {
-			return classifyImage(watsonFnURL, imageURL, timeout)
-		},
-	})
+	fn := summaryFn.requestFn(request)
+	log.Printf("SummaryFn.Summary: s=\"%s\", c=\"%d\", o=\"%s\"", fn.SearchString, fn.Count, fn.Output)
+
+	classifier, ok := fn.classifierQueryParam(writer, request)
+	if !ok {
+		return
+	}
 
-	tmpl, err = tmpl.ParseFiles("./funcs/summary/async_layout.html")
+	tweets, err := fn.collectTweets(request.Context(), fn.SearchString, fn.Count)
 	if err != nil {
-		log.Printf("Error parsing Golang template for tweets: %s\n", err.Error())
+		log.Printf("Error collecting tweets: %s\n", err.Error())
+		http.Error(writer, err.Error(), http.StatusBadGateway)
 		return
 	}
 
+	viewOptions := fn.viewQueryParams(request)
+	tweets, pagination := viewOptions.ApplyToTweets(tweets)
+
 	data := SummaryPageData{
-		PageTitle: fmt.Sprintf("Recent tweets for search `%s`", summaryFn.SearchString),
+		PageTitle: fmt.Sprintf("Recent tweets for search `%s`", fn.SearchString),
 		Tweets:    tweets,
 
-		WatsonFnURL: summaryFn.WatsonFnURL,
-		Timeout:     summaryFn.Timeout,
+		ViewOptions: viewOptions,
+		Pagination:  pagination,
+		Links:       newSummaryLinks(request, viewOptions, pagination),
+
+		Analyze:    fn.NLUFnURL != "",
+		Classifier: classifier,
+		Context:    request.Context(),
+
+		MaxLabels: fn.MaxLabels,
+		MinScore:  fn.MinScore,
 	}
 
-	err = tmpl.Execute(writer, data)
+	writer.Header().Add("Content-Type", common.OutputContentType("html"))
+	err = fn.summaryTemplates().Execute(writer, asyncLayoutTemplate, data)
 	if err != nil {
 		log.Printf("Error executing template with tweets: %s\n", err.Error())
 		return
@@ -135,38 +229,132 @@ func (summaryFn *SummaryFn) SummaryAsyncHandler(writer http.ResponseWriter, requ
 
 // Private SummaryFn
 
-func (summaryFn *SummaryFn) searchTweets(searchString string, count int) ([]Tweet, error) {
-	var err error
+// requestFn returns a copy of the summaryFn with the common and classify query
+// params of the request, which thus never change the summaryFn of the server
+func (summaryFn *SummaryFn) requestFn(request *http.Request) *SummaryFn {
+	fn := *summaryFn
+	fn.InitCommonQueryParams(request)
+	fn.InitClassifyQueryParams(request)
+	return &fn
+}
 
-	twitterFnClient := http.Client{
-		Timeout: time.Second * time.Duration(summaryFn.Timeout),
+// viewed applies the default view options to a summary without view or
+// pagination, e.g., one that was not paged, so that it can be rendered
+func (summaryFn *SummaryFn) viewed(summaryData SummaryData) SummaryData {
+	if summaryData.Query.View == nil || summaryData.Pagination == nil {
+		return summaryFn.ViewOptions.Apply(summaryData)
 	}
+	return summaryData
+}
 
-	url := fmt.Sprintf("%s?q=%s&c=%d&o=json", summaryFn.TwitterFnURL, searchString, count)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// classifiedSummary is the Summary with the named classifier, the default one
+// when empty
+func (summaryFn *SummaryFn) classifiedSummary(ctx context.Context, classifier string) (SummaryData, error) {
+	summaryData := SummaryData{
+		Query:            summaryFn.summaryQuery(),
+		Stats:            computeStats(0, []ClassifiedTweet{}),
+		ClassifiedTweets: []ClassifiedTweet{},
+	}
+	summaryData.Query.Classifier = summaryFn.classifierName(classifier)
+
+	tweets, errorMessages, err := summaryFn.collectTweetsWithErrors(ctx, summaryFn.SearchString, summaryFn.Count)
+	summaryData.Errors = append(summaryData.Errors, errorMessages...)
 	if err != nil {
-		return []Tweet{}, err
+		summaryData.Errors = append(summaryData.Errors, err.Error())
+		return summaryData, err
 	}
 
-	req.Header.Set("User-Agent", "application/json")
+	classifiedTweets, errorMessages := summaryFn.collectClassifiedTweets(ctx, tweets, classifier)
+	summaryData.Errors = append(summaryData.Errors, errorMessages...)
 
-	res, err := twitterFnClient.Do(req)
-	if err != nil {
-		return []Tweet{}, err
+	summaryData.ClassifiedTweets = classifiedTweets
+	summaryData.Stats = computeStats(len(tweets), classifiedTweets)
+
+	return summaryData, nil
+}
+
+func (summaryFn *SummaryFn) summaryTemplates() *SummaryTemplates {
+	if summaryFn.templates == nil {
+		summaryFn.templates = NewSummaryTemplates(summaryFn.TemplatesDir, summaryFn.Dev, summaryFn.templateFuncs())
 	}
+	return summaryFn.templates
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return []Tweet{}, err
+func (summaryFn *SummaryFn) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"ClassifyImage": func(ctx context.Context, watsonFnURL string, imageURL string, timeout int) (ClassifiedImage, error) {
+			return summaryFn.watsonClient(watsonFnURL, timeout).Classify(ctx, imageURL, summaryFn.ClassifyFn)
+		},
+	}
+	for name, fn := range statsFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+func (summaryFn *SummaryFn) summaryQuery() SummaryQuery {
+	return summaryFn.newSummaryQuery(summaryFn.SearchString, summaryFn.Count, "", summaryFn.ClassifyFn)
+}
+
+// newSummaryQuery is the query of a summary with the content sources and the
+// named classifier of the summaryFn, the default one when empty
+func (summaryFn *SummaryFn) newSummaryQuery(searchString string, count int, classifier string, classifyFn common.ClassifyFn) SummaryQuery {
+	sourceNames := []string{}
+	for _, source := range summaryFn.contentSources() {
+		sourceNames = append(sourceNames, source.Name)
+	}
+
+	return SummaryQuery{
+		SearchString: searchString,
+		Count:        count,
+		Sources:      sourceNames,
+		Classifier:   summaryFn.classifierName(classifier),
+		MaxLabels:    classifyFn.MaxLabels,
+		MinScore:     classifyFn.MinScore,
+	}
+}
+
+func (summaryFn *SummaryFn) writeSummary(writer http.ResponseWriter, request *http.Request, status int, output string, summaryData SummaryData) {
+	if output != "html" {
+		common.RespondOutput(writer, status, &summaryData, output, summaryData.ToText)
+		return
 	}
 
-	tweets := []Tweet{}
-	err = json.Unmarshal(body, &tweets)
+	if status != http.StatusOK {
+		http.Error(writer, strings.Join(summaryData.Errors, "\n"), status)
+		return
+	}
+
+	summaryData = summaryFn.viewed(summaryData)
+	writer.Header().Add("Content-Type", common.OutputContentType("html"))
+	err := summaryFn.renderHTML(request.Context(), writer, summaryData, newSummaryLinks(request, *summaryData.Query.View, *summaryData.Pagination))
 	if err != nil {
-		return []Tweet{}, err
+		log.Printf("Error executing template with classified tweets: %s\n", err.Error())
 	}
+}
+
+func (summaryFn *SummaryFn) renderHTML(ctx context.Context, writer io.Writer, summaryData SummaryData, links SummaryLinks) error {
+	summaryData = summaryFn.viewed(summaryData)
+	return summaryFn.summaryTemplates().Execute(writer, layoutTemplate, SummaryPageData{
+		PageTitle:        fmt.Sprintf("Recent tweets with images for search `%s`", summaryData.Query.SearchString),
+		ClassifiedTweets: summaryData.ClassifiedTweets,
+		Stats:            summaryData.Stats,
+		Errors:           summaryData.Errors,
+
+		ViewOptions: *summaryData.Query.View,
+		Pagination:  *summaryData.Pagination,
+		Links:       links,
 
-	return tweets, nil
+		Context: ctx,
+	})
+}
+
+func (summaryFn *SummaryFn) searchTweets(ctx context.Context, source ContentSource, searchString string, count int) ([]Tweet, error) {
+	return client.NewTwitterClient(source.URL, summaryFn.Timeout).Search(ctx, client.SearchParams{
+		Query:  searchString,
+		Count:  count,
+		Params: source.Params,
+	})
 }
 
 func (summaryFn *SummaryFn) collectTweetsWithImages(tweets []Tweet) []Tweet {
@@ -179,68 +367,55 @@ func (summaryFn *SummaryFn) collectTweetsWithImages(tweets []Tweet) []Tweet {
 	return tweetsWithImages
 }
 
-func (summaryFn *SummaryFn) collectClassifiedTweets() ([]ClassifiedTweet, error) {
-	tweets, err := summaryFn.searchTweets(summaryFn.SearchString, summaryFn.Count)
-	if err != nil {
-		return []ClassifiedTweet{}, err
-	}
-
+func (summaryFn *SummaryFn) collectClassifiedTweets(ctx context.Context, tweets []Tweet, classifier string) ([]ClassifiedTweet, []string) {
 	tweetsWithImages := summaryFn.collectTweetsWithImages(tweets)
 	classifiedTweets := []ClassifiedTweet{}
+	errorMessages := []string{}
 	for _, tweet := range tweetsWithImages {
 		classifiedImages := []ClassifiedImage{}
 		for _, imageURL := range tweet.ImageURLs {
-			classifiedImage, err := classifyImage(summaryFn.WatsonFnURL, imageURL, summaryFn.Timeout)
+			classifiedImage, err := summaryFn.classifyImage(ctx, classifier, imageURL, summaryFn.ClassifyFn)
 			if err != nil {
-				return []ClassifiedTweet{}, err
+				log.Printf("Error classifying image '%s': %s\n", imageURL, err.Error())
+				errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", imageURL, err.Error()))
+				continue
 			}
 			classifiedImages = append(classifiedImages, classifiedImage)
 		}
+
+		if len(classifiedImages) == 0 {
+			continue
+		}
+
 		classifiedTweet := ClassifiedTweet{
+			ID:               tweet.ID,
 			Text:             tweet.Text,
+			Source:           tweet.Source,
+			CreatedAt:        tweet.CreatedAt,
+			Analysis:         summaryFn.analyzeTweet(ctx, tweet),
 			ClassifiedImages: classifiedImages,
 		}
 		classifiedTweets = append(classifiedTweets, classifiedTweet)
 	}
-	return classifiedTweets, nil
+	return classifiedTweets, errorMessages
 }
 
-// Private function
-
-func classifyImage(watsonFnURL string, imageURL string, timeout int) (ClassifiedImage, error) {
-	var err error
-
-	watsonFnClient := http.Client{
-		Timeout: time.Second * time.Duration(timeout),
-	}
-
-	url := fmt.Sprintf("%s?q=%s&o=json", watsonFnURL, imageURL)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return ClassifiedImage{}, err
-	}
-
-	req.Header.Set("User-Agent", "application/json")
+// SummaryData
 
-	res, err := watsonFnClient.Do(req)
-	if err != nil {
-		return ClassifiedImage{}, err
+func (summaryData SummaryData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, cTweet := range summaryData.ClassifiedTweets {
+		sb.WriteString(fmt.Sprintf("%s\n", cTweet.ToText()))
+		sb.WriteString("=======\n\n")
 	}
-
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return ClassifiedImage{}, err
+	if summaryData.Pagination != nil {
+		sb.WriteString(summaryData.Pagination.ToText())
 	}
-
-	fmt.Println(string(body[:])) //DEBUG
-
-	classifiedImage := ClassifiedImage{}
-	err = json.Unmarshal(body, &classifiedImage)
-	if err != nil {
-		return ClassifiedImage{}, err
+	sb.WriteString(summaryData.Stats.ToText())
+	for _, errorMessage := range summaryData.Errors {
+		sb.WriteString(fmt.Sprintf("⚠️  %s\n", errorMessage))
 	}
-
-	return classifiedImage, nil
+	return sb.String()
 }
 
 // ClassifiedTweet
@@ -248,6 +423,12 @@ func classifyImage(watsonFnURL string, imageURL string, timeout int) (Classified
 func (cTweet ClassifiedTweet) ToText() string {
 	sb := bytes.NewBufferString("")
 	sb.WriteString(fmt.Sprintf("\n🐦 %s\n", cTweet.Text))
+	if cTweet.Source != "" {
+		sb.WriteString(fmt.Sprintf("source: `%s`\n", cTweet.Source))
+	}
+	if cTweet.Analysis != nil {
+		sb.WriteString(fmt.Sprintf("sentiment: %s `%s` with `%1.3f` score\n", sentimentEmoji(cTweet.Analysis.Sentiment), cTweet.Analysis.Sentiment.Label, cTweet.Analysis.Sentiment.Score))
+	}
 	for i, cImage := range cTweet.ClassifiedImages {
 		sb.WriteString(fmt.Sprintf("\n%d.  📸 URL: `%s`\n", i, cImage.ImageURL))
 		for _, label := range cImage.Labels {
@@ -258,35 +439,8 @@ func (cTweet ClassifiedTweet) ToText() string {
 	return sb.String()
 }
 
-func (cTweet ClassifiedTweet) ToYAML() string {
-	data, err := yaml.Marshal(&cTweet)
-	if err != nil {
-		panic("Error YAML marshalling ClassifiedTweet")
-	}
-
-	return string(data)
-}
-
-func (cTweet ClassifiedTweet) ToJSON() string {
-	data, err := json.MarshalIndent(&cTweet, "", "  ")
-	if err != nil {
-		panic("Error JSON marshalling ClassifiedTweet")
-	}
-
-	return string(data)
-}
-
-func (cTweet ClassifiedTweet) Flatten(output string) string {
-	outputData := ""
-	switch output {
-	case "yaml":
-		outputData = cTweet.ToYAML()
-		break
-	case "json":
-		outputData = cTweet.ToJSON()
-		break
-	default:
-		outputData = cTweet.ToText()
-	}
-	return outputData
+func (cTweet ClassifiedTweet) Flatten(output string) (string, error) {
+	return common.Flatten(&cTweet, output, func(in interface{}) string {
+		return cTweet.ToText()
+	})
 }
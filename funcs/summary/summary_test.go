@@ -0,0 +1,214 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+	"gotest.tools/assert"
+)
+
+func TestSummaryHandlerJSON(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	request := httptest.NewRequest("GET", "/?q=NBA&c=5", nil)
+	request.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, request)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/json")
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Query.SearchString, "NBA")
+	assert.DeepEqual(t, summaryData.Query.Sources, []string{"twitter", "broken"})
+	assert.Equal(t, summaryData.Stats.TweetCount, 3)
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 1)
+	assert.Equal(t, summaryData.ClassifiedTweets[0].ClassifiedImages[0].Labels[0].Name, "ball")
+	assert.Equal(t, len(summaryData.Errors), 2)
+	assert.Assert(t, strings.HasPrefix(summaryData.Errors[0], "broken:"))
+	assert.Assert(t, strings.HasPrefix(summaryData.Errors[1], "http://img/fail.jpg:"))
+}
+
+func TestSummaryHandlerYAML(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=yaml", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/yaml")
+
+	summaryData := SummaryData{}
+	assert.NilError(t, yaml.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Stats.TweetsWithImages, 1)
+}
+
+func TestSummaryHandlerRequestParams(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+	summaryFn.SearchString, summaryFn.Count, summaryFn.MaxLabels = "NBA", 10, 5
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=knative&c=2&max=1&threshold=0.5&o=json", nil))
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Query.SearchString, "knative")
+	assert.Equal(t, summaryData.Query.Count, 2)
+	assert.Equal(t, summaryData.Query.MaxLabels, 1)
+
+	// the params of a request never stick to the next ones
+	assert.Equal(t, summaryFn.SearchString, "NBA")
+	assert.Equal(t, summaryFn.Count, 10)
+	assert.Equal(t, summaryFn.MaxLabels, 5)
+	assert.Equal(t, summaryFn.MinScore, 0.0)
+	assert.Equal(t, summaryFn.Output, "")
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?o=json", nil))
+	summaryData = SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, summaryData.Query.SearchString, "NBA")
+	assert.Equal(t, summaryData.Query.Count, 10)
+	assert.Equal(t, summaryData.Query.MaxLabels, 5)
+}
+
+func TestSummaryHandlerTemplateOutputs(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=jsonpath%3D%7B.query.search-string%7D%3A%7B.stats.tweet-count%7D", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, recorder.Body.String(), "NBA:3\n")
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=custom-columns%3DTEXT%3A.text", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.HasPrefix(recorder.Body.String(), "TEXT"))
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=xml", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "unknown output 'xml'"))
+}
+
+func TestWriteSummaryWithoutView(t *testing.T) {
+	summaryFn := &SummaryFn{}
+	summaryFn.ViewOptions.PageSize = 1
+	summaryData := SummaryData{
+		Query: SummaryQuery{SearchString: "NBA"},
+		ClassifiedTweets: []ClassifiedTweet{
+			ClassifiedTweet{Text: "t1", ClassifiedImages: []ClassifiedImage{ClassifiedImage{ImageURL: "http://img/1.jpg"}}},
+			ClassifiedTweet{Text: "t2", ClassifiedImages: []ClassifiedImage{ClassifiedImage{ImageURL: "http://img/2.jpg"}}},
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.writeSummary(recorder, httptest.NewRequest("GET", "/?q=NBA", nil), http.StatusOK, "html", summaryData)
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "page 1 of 2"))
+
+	html := bytes.NewBufferString("")
+	assert.NilError(t, summaryFn.renderHTML(context.Background(), html, summaryData, SummaryLinks{}))
+	assert.Assert(t, strings.Contains(html.String(), "page 1 of 2"))
+}
+
+func TestSummaryHandlerAllSourcesFail(t *testing.T) {
+	summaryFn := &SummaryFn{Sources: []ContentSource{ContentSource{Name: "broken", URL: "http://127.0.0.1:0"}}}
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusBadGateway)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 0)
+	assert.Assert(t, len(summaryData.Errors) > 0)
+}
+
+func TestSummaryHandlerCancelled(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	// the sources are not searched once the client went away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json", nil).WithContext(ctx))
+	assert.Equal(t, recorder.Code, http.StatusBadGateway)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Assert(t, strings.Contains(summaryData.Errors[0], "context canceled"))
+}
+
+func TestSummaryStdin(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+	summaryFn.Output = "ndjson"
+	summaryFn.Concurrency = 2
+
+	writer := bytes.NewBufferString("")
+	err := summaryFn.ProcessLines(strings.NewReader("NBA\n\nknative\n"), writer, summaryFn.summaryLine)
+	assert.NilError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	for i, searchString := range []string{"NBA", "knative"} {
+		summaryData := SummaryData{}
+		assert.NilError(t, json.Unmarshal([]byte(lines[i]), &summaryData))
+		assert.Equal(t, summaryData.Query.SearchString, searchString)
+		assert.Equal(t, summaryData.Stats.TweetCount, 3)
+	}
+	assert.Equal(t, summaryFn.SearchString, "")
+}
+
+func TestNegotiateOutput(t *testing.T) {
+	summaryFn := &SummaryFn{}
+	for _, tc := range []struct {
+		url    string
+		accept string
+		output string
+	}{
+		{"/?o=json", "text/html", "json"},
+		{"/", "application/yaml, text/html", "yaml"},
+		{"/", "text/html,application/xhtml+xml,*/*;q=0.8", "html"},
+		{"/", "text/plain", "text"},
+		{"/", "text/csv;q=0.9, text/html;q=0.8", "csv"},
+		{"/", "*/*", "html"},
+		{"/", "", "html"},
+	} {
+		request := httptest.NewRequest("GET", tc.url, nil)
+		request.Header.Set("Accept", tc.accept)
+		assert.Equal(t, summaryFn.NegotiateOutput(request, "html"), tc.output, tc.url+" "+tc.accept)
+	}
+}
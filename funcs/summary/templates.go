@@ -0,0 +1,160 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/maximilien/knfun/funcs/common"
+)
+
+const (
+	layoutTemplate        = "layout.html"
+	asyncLayoutTemplate   = "async_layout.html"
+	liveLayoutTemplate    = "live_layout.html"
+	historyLayoutTemplate = "history_layout.html"
+	reportLayoutTemplate  = "report_layout.html"
+	compareLayoutTemplate = "compare_layout.html"
+	staticDir             = "static"
+)
+
+// embeddedFiles holds the default theme, overridden with --templates-dir
+//
+//go:embed layout.html async_layout.html live_layout.html history_layout.html report_layout.html compare_layout.html static
+var embeddedFiles embed.FS
+
+// SummaryTemplates parses the summary pages once, from the embedded theme or
+// from a custom templates directory, and re-parses them on every render in
+// dev mode so that theme edits show up without restarting the func
+type SummaryTemplates struct {
+	TemplatesDir string
+	Dev          bool
+
+	funcs template.FuncMap
+
+	mutex     sync.Mutex
+	templates map[string]*template.Template
+}
+
+func NewSummaryTemplates(templatesDir string, dev bool, funcs template.FuncMap) *SummaryTemplates {
+	return &SummaryTemplates{
+		TemplatesDir: templatesDir,
+		Dev:          dev,
+		funcs:        funcs,
+	}
+}
+
+// Load parses all the templates, returning the first parsing error
+func (summaryTemplates *SummaryTemplates) Load() error {
+	summaryTemplates.mutex.Lock()
+	defer summaryTemplates.mutex.Unlock()
+
+	return summaryTemplates.load()
+}
+
+// Execute renders the named template, loading the templates first when not
+// yet loaded or when in dev mode
+func (summaryTemplates *SummaryTemplates) Execute(writer io.Writer, name string, data interface{}) error {
+	summaryTemplates.mutex.Lock()
+	if summaryTemplates.templates == nil || summaryTemplates.Dev {
+		err := summaryTemplates.load()
+		if err != nil {
+			summaryTemplates.mutex.Unlock()
+			return err
+		}
+	}
+	tmpl, ok := summaryTemplates.templates[name]
+	summaryTemplates.mutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no template named '%s'", name)
+	}
+
+	return tmpl.Execute(writer, data)
+}
+
+// StaticHandler serves the `static` assets of the templates directory, falling
+// back to the embedded asset of the same name when the directory does not have
+// it, so custom themes can override a single asset
+func (summaryTemplates *SummaryTemplates) StaticHandler() http.Handler {
+	embeddedStaticFS, err := fs.Sub(embeddedFiles, staticDir)
+	if err != nil {
+		panic(fmt.Sprintf("error reading embedded static assets: %s", err.Error()))
+	}
+
+	staticFS := fallbackFS{embeddedStaticFS}
+	if summaryTemplates.TemplatesDir != "" {
+		dir := filepath.Join(summaryTemplates.TemplatesDir, staticDir)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			log.Printf("Serving static assets from '%s'", dir)
+			staticFS = fallbackFS{os.DirFS(dir), embeddedStaticFS}
+		}
+	}
+
+	return http.StripPrefix("/static/", http.FileServer(http.FS(staticFS)))
+}
+
+// Private SummaryTemplates
+
+func (summaryTemplates *SummaryTemplates) load() error {
+	templates := map[string]*template.Template{}
+	for _, name := range []string{layoutTemplate, asyncLayoutTemplate, liveLayoutTemplate, historyLayoutTemplate, reportLayoutTemplate, compareLayoutTemplate} {
+		tmpl, err := template.New(name).Funcs(summaryTemplates.funcs).ParseFS(summaryTemplates.templateFS(name), name)
+		if err != nil {
+			return fmt.Errorf("error parsing template '%s': %s", name, err.Error())
+		}
+		templates[name] = tmpl
+	}
+
+	summaryTemplates.templates = templates
+	return nil
+}
+
+// templateFS returns the templates directory when it has the named template and
+// the embedded theme otherwise, so custom themes can override a single page
+func (summaryTemplates *SummaryTemplates) templateFS(name string) fs.FS {
+	if summaryTemplates.TemplatesDir != "" {
+		if common.FileExists(filepath.Join(summaryTemplates.TemplatesDir, name)) {
+			return os.DirFS(summaryTemplates.TemplatesDir)
+		}
+		log.Printf("No template '%s' in '%s', using the embedded one", name, summaryTemplates.TemplatesDir)
+	}
+	return embeddedFiles
+}
+
+// fallbackFS opens the files from the first file system that has them
+type fallbackFS []fs.FS
+
+func (fallback fallbackFS) Open(name string) (fs.File, error) {
+	var err error
+	for _, fileSystem := range fallback {
+		var file fs.File
+		file, err = fileSystem.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return file, err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,126 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestSummaryTemplatesEmbedded(t *testing.T) {
+	summaryTemplates := NewSummaryTemplates("", false, statsFuncs)
+	assert.NilError(t, summaryTemplates.Load())
+
+	out := bytes.NewBufferString("")
+	err := summaryTemplates.Execute(out, layoutTemplate, SummaryPageData{PageTitle: "embedded"})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(out.String(), "<h1>embedded</h1>"))
+	assert.Assert(t, strings.Contains(out.String(), `src="/static/jquery.min.js"`))
+
+	err = summaryTemplates.Execute(out, "missing.html", SummaryPageData{})
+	assert.ErrorContains(t, err, "no template named 'missing.html'")
+}
+
+func TestSummaryTemplatesStaticHandler(t *testing.T) {
+	handler := NewSummaryTemplates("", false, statsFuncs).StaticHandler()
+
+	for _, asset := range []string{"jquery.min.js", "wordcloud.js", "wordcloud.css"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/static/"+asset, nil))
+		assert.Equal(t, recorder.Code, http.StatusOK, asset)
+		assert.Assert(t, recorder.Body.Len() > 0, asset)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/static/missing.js", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestSummaryTemplatesDir(t *testing.T) {
+	dir := newTestTemplatesDir(t, "<h1>custom {{.PageTitle}}</h1>")
+	defer os.RemoveAll(dir)
+
+	summaryTemplates := NewSummaryTemplates(dir, false, statsFuncs)
+	assert.NilError(t, summaryTemplates.Load())
+
+	out := bytes.NewBufferString("")
+	assert.NilError(t, summaryTemplates.Execute(out, layoutTemplate, SummaryPageData{PageTitle: "theme"}))
+	assert.Equal(t, out.String(), "<h1>custom theme</h1>")
+
+	out.Reset()
+	assert.NilError(t, summaryTemplates.Execute(out, asyncLayoutTemplate, SummaryPageData{PageTitle: "async"}))
+	assert.Assert(t, strings.Contains(out.String(), "async"))
+
+	recorder := httptest.NewRecorder()
+	summaryTemplates.StaticHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/static/theme.css", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "h1 { color: red; }")
+
+	// the assets missing from the theme are the embedded ones
+	recorder = httptest.NewRecorder()
+	summaryTemplates.StaticHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/static/jquery.min.js", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), "jQuery v3.6.1"))
+
+	recorder = httptest.NewRecorder()
+	summaryTemplates.StaticHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/static/missing.js", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestSummaryTemplatesDevReload(t *testing.T) {
+	dir := newTestTemplatesDir(t, "v1")
+	defer os.RemoveAll(dir)
+
+	prodTemplates := NewSummaryTemplates(dir, false, statsFuncs)
+	devTemplates := NewSummaryTemplates(dir, true, statsFuncs)
+	assert.NilError(t, prodTemplates.Load())
+	assert.NilError(t, devTemplates.Load())
+
+	err := ioutil.WriteFile(filepath.Join(dir, layoutTemplate), []byte("v2"), 0644)
+	assert.NilError(t, err)
+
+	out := bytes.NewBufferString("")
+	assert.NilError(t, prodTemplates.Execute(out, layoutTemplate, SummaryPageData{}))
+	assert.Equal(t, out.String(), "v1")
+
+	out.Reset()
+	assert.NilError(t, devTemplates.Execute(out, layoutTemplate, SummaryPageData{}))
+	assert.Equal(t, out.String(), "v2")
+
+	err = ioutil.WriteFile(filepath.Join(dir, layoutTemplate), []byte("{{.Missing"), 0644)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, devTemplates.Execute(out, layoutTemplate, SummaryPageData{}), "error parsing template 'layout.html'")
+}
+
+// Private
+
+func newTestTemplatesDir(t *testing.T, layout string) string {
+	dir, err := ioutil.TempDir("", "summary-templates")
+	assert.NilError(t, err)
+
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, layoutTemplate), []byte(layout), 0644))
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, staticDir), 0755))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, staticDir, "theme.css"), []byte("h1 { color: red; }"), 0644))
+
+	return dir
+}
@@ -0,0 +1,339 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	listView  = "list"
+	tableView = "table"
+
+	sortByTime       = "time"
+	sortByConfidence = "confidence"
+	sortByLabel      = "label"
+
+	defaultPageSize = 20
+)
+
+// ViewOptions select, order and page the classified tweets of a summary. The
+// stats always cover all the classified tweets
+type ViewOptions struct {
+	View          string   `yaml:"view" json:"view"`
+	Sort          string   `yaml:"sort" json:"sort"`
+	Labels        []string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	MinConfidence float64  `yaml:"min-confidence,omitempty" json:"min-confidence,omitempty"`
+	Page          int      `yaml:"page" json:"page"`
+	PageSize      int      `yaml:"page-size" json:"page-size"`
+}
+
+type SummaryPagination struct {
+	Page     int `yaml:"page" json:"page"`
+	PageSize int `yaml:"page-size" json:"page-size"`
+	Pages    int `yaml:"pages" json:"pages"`
+	Total    int `yaml:"total" json:"total"`
+}
+
+// SummaryLinks are the relative URLs of the HTML pages to switch views, sort,
+// and page through the same summary
+type SummaryLinks struct {
+	List             string
+	Table            string
+	SortByTime       string
+	SortByConfidence string
+	SortByLabel      string
+	Previous         string
+	Next             string
+
+	// FormParams are the query parameters kept by the filter form
+	FormParams url.Values
+}
+
+// Private SummaryFn
+
+// viewQueryParams returns the view options of the request, defaulting to the
+// ones of the command line, without changing the latter
+func (summaryFn *SummaryFn) viewQueryParams(request *http.Request) ViewOptions {
+	viewOptions := summaryFn.ViewOptions
+	viewOptions.View = summaryFn.ExtractQueryStringParam(request, []string{"view"}, viewOptions.View)
+	viewOptions.Sort = summaryFn.ExtractQueryStringParam(request, []string{"sort"}, viewOptions.Sort)
+	viewOptions.Labels = splitLabels(summaryFn.ExtractQueryStringSliceParam(request, []string{"label", "labels"}, viewOptions.Labels))
+	viewOptions.MinConfidence = summaryFn.ExtractQueryFloatParam(request, []string{"min-confidence"}, viewOptions.MinConfidence)
+	viewOptions.Page = summaryFn.ExtractQueryIntParam(request, []string{"page"}, viewOptions.Page)
+	viewOptions.PageSize = summaryFn.ExtractQueryIntParam(request, []string{"page-size"}, viewOptions.PageSize)
+	return viewOptions.normalize()
+}
+
+// ViewOptions
+
+// Apply returns the summary with only the page of classified tweets that
+// match the filters, in the requested order
+func (viewOptions ViewOptions) Apply(summaryData SummaryData) SummaryData {
+	viewOptions = viewOptions.normalize()
+
+	classifiedTweets := []ClassifiedTweet{}
+	for _, cTweet := range summaryData.ClassifiedTweets {
+		if viewOptions.matches(cTweet) {
+			classifiedTweets = append(classifiedTweets, cTweet)
+		}
+	}
+	viewOptions.sortClassifiedTweets(classifiedTweets)
+
+	pagination, start, end := viewOptions.paginate(len(classifiedTweets))
+
+	summaryData.ClassifiedTweets = classifiedTweets[start:end]
+	summaryData.Query.View = &viewOptions
+	summaryData.Pagination = &pagination
+	return summaryData
+}
+
+// ApplyToTweets orders by time and pages the tweets with images, since the
+// other sorts and filters need the classified images
+func (viewOptions ViewOptions) ApplyToTweets(tweets []Tweet) ([]Tweet, SummaryPagination) {
+	viewOptions = viewOptions.normalize()
+
+	tweetsWithImages := []Tweet{}
+	for _, tweet := range tweets {
+		if len(tweet.ImageURLs) > 0 {
+			tweetsWithImages = append(tweetsWithImages, tweet)
+		}
+	}
+	sort.SliceStable(tweetsWithImages, func(i, j int) bool {
+		return newerThan(tweetsWithImages[i].CreatedAt, tweetsWithImages[j].CreatedAt)
+	})
+
+	pagination, start, end := viewOptions.paginate(len(tweetsWithImages))
+	return tweetsWithImages[start:end], pagination
+}
+
+// Private ViewOptions
+
+func (viewOptions ViewOptions) normalize() ViewOptions {
+	if viewOptions.View != tableView {
+		viewOptions.View = listView
+	}
+
+	switch viewOptions.Sort {
+	case sortByTime, sortByConfidence, sortByLabel:
+	default:
+		viewOptions.Sort = sortByTime
+	}
+
+	if viewOptions.Page < 1 {
+		viewOptions.Page = 1
+	}
+
+	if viewOptions.PageSize < 0 {
+		viewOptions.PageSize = defaultPageSize
+	}
+
+	return viewOptions
+}
+
+// matches is true when one of the labels of the tweet's images is one of the
+// filtered labels, if any, with at least the min confidence
+func (viewOptions ViewOptions) matches(cTweet ClassifiedTweet) bool {
+	if len(viewOptions.Labels) == 0 && viewOptions.MinConfidence <= 0 {
+		return true
+	}
+
+	for _, label := range cTweet.TopLabels(0) {
+		if float64(label.Score) < viewOptions.MinConfidence {
+			continue
+		}
+
+		if len(viewOptions.Labels) == 0 {
+			return true
+		}
+
+		for _, name := range viewOptions.Labels {
+			if strings.EqualFold(label.Name, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (viewOptions ViewOptions) sortClassifiedTweets(classifiedTweets []ClassifiedTweet) {
+	sort.SliceStable(classifiedTweets, func(i, j int) bool {
+		switch viewOptions.Sort {
+		case sortByConfidence:
+			return topLabel(classifiedTweets[i]).Score > topLabel(classifiedTweets[j]).Score
+		case sortByLabel:
+			labelI, labelJ := topLabel(classifiedTweets[i]), topLabel(classifiedTweets[j])
+			if labelI.Name == "" || labelJ.Name == "" {
+				return labelI.Name != ""
+			}
+
+			if !strings.EqualFold(labelI.Name, labelJ.Name) {
+				return strings.ToLower(labelI.Name) < strings.ToLower(labelJ.Name)
+			}
+			return labelI.Score > labelJ.Score
+		default:
+			return newerThan(classifiedTweets[i].CreatedAt, classifiedTweets[j].CreatedAt)
+		}
+	})
+}
+
+// paginate returns the pagination and the bounds of the page, a page size of
+// 0 puts all the items in a single page
+func (viewOptions ViewOptions) paginate(total int) (SummaryPagination, int, int) {
+	pagination := SummaryPagination{
+		Page:     viewOptions.Page,
+		PageSize: viewOptions.PageSize,
+		Pages:    1,
+		Total:    total,
+	}
+
+	if viewOptions.PageSize == 0 {
+		pagination.Page = 1
+		return pagination, 0, total
+	}
+
+	if total > 0 {
+		pagination.Pages = (total + viewOptions.PageSize - 1) / viewOptions.PageSize
+	}
+
+	if pagination.Page > pagination.Pages {
+		pagination.Page = pagination.Pages
+	}
+
+	start := (pagination.Page - 1) * viewOptions.PageSize
+	end := start + viewOptions.PageSize
+	if end > total {
+		end = total
+	}
+
+	return pagination, start, end
+}
+
+// ClassifiedTweet
+
+// TopLabels returns the n labels with the highest scores over all the images
+// of the tweet, each label once with its highest score, or all when n is 0
+func (cTweet ClassifiedTweet) TopLabels(n int) []Label {
+	scores := map[string]float32{}
+	for _, cImage := range cTweet.ClassifiedImages {
+		for _, label := range cImage.Labels {
+			if score, ok := scores[label.Name]; !ok || label.Score > score {
+				scores[label.Name] = label.Score
+			}
+		}
+	}
+
+	labels := []Label{}
+	for name, score := range scores {
+		labels = append(labels, Label{Name: name, Score: score})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Score != labels[j].Score {
+			return labels[i].Score > labels[j].Score
+		}
+		return labels[i].Name < labels[j].Name
+	})
+
+	if n > 0 && len(labels) > n {
+		labels = labels[:n]
+	}
+	return labels
+}
+
+// SummaryPagination
+
+func (pagination SummaryPagination) ToText() string {
+	return fmt.Sprintf("📄 page %d of %d, %d classified tweets\n", pagination.Page, pagination.Pages, pagination.Total)
+}
+
+// Private functions
+
+func newSummaryLinks(request *http.Request, viewOptions ViewOptions, pagination SummaryPagination) SummaryLinks {
+	links := SummaryLinks{
+		List:             linkWith(request, map[string]string{"view": listView}),
+		Table:            linkWith(request, map[string]string{"view": tableView}),
+		SortByTime:       linkWith(request, map[string]string{"sort": sortByTime}),
+		SortByConfidence: linkWith(request, map[string]string{"sort": sortByConfidence}),
+		SortByLabel:      linkWith(request, map[string]string{"sort": sortByLabel}),
+		FormParams:       url.Values{},
+	}
+
+	if pagination.Page > 1 {
+		links.Previous = linkWith(request, map[string]string{"page": strconv.Itoa(pagination.Page - 1)})
+	}
+
+	if pagination.Page < pagination.Pages {
+		links.Next = linkWith(request, map[string]string{"page": strconv.Itoa(pagination.Page + 1)})
+	}
+
+	for name, values := range request.URL.Query() {
+		switch name {
+		case "label", "labels", "min-confidence", "page":
+		default:
+			links.FormParams[name] = values
+		}
+	}
+
+	return links
+}
+
+// linkWith returns the request's relative URL with the params set, going back
+// to the first page unless the page is one of them
+func linkWith(request *http.Request, params map[string]string) string {
+	query := request.URL.Query()
+	query.Del("page")
+	for name, value := range params {
+		query.Set(name, value)
+	}
+	return request.URL.Path + "?" + query.Encode()
+}
+
+func topLabel(cTweet ClassifiedTweet) Label {
+	labels := cTweet.TopLabels(1)
+	if len(labels) == 0 {
+		return Label{}
+	}
+	return labels[0]
+}
+
+// newerThan orders the tweets with a creation time from the newest and keeps
+// the collected order for the others, after them
+func newerThan(createdAt string, otherCreatedAt string) bool {
+	time1, err1 := time.Parse(time.RFC3339, createdAt)
+	time2, err2 := time.Parse(time.RFC3339, otherCreatedAt)
+	if err1 != nil || err2 != nil {
+		return err1 == nil && err2 != nil
+	}
+	return time1.After(time2)
+}
+
+func splitLabels(values []string) []string {
+	labels := []string{}
+	for _, value := range values {
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
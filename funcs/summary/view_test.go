@@ -0,0 +1,135 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestViewOptionsApply(t *testing.T) {
+	summaryData := SummaryData{ClassifiedTweets: []ClassifiedTweet{
+		newClassifiedTweet("dog", "2019-11-21T19:00:00Z", Label{Name: "dog", Score: 0.6}),
+		newClassifiedTweet("ball", "2019-11-21T21:00:00Z", Label{Name: "ball", Score: 0.9}, Label{Name: "Court", Score: 0.4}),
+		newClassifiedTweet("court", "", Label{Name: "court", Score: 0.7}),
+		newClassifiedTweet("cat", "2019-11-21T20:00:00Z", Label{Name: "cat", Score: 0.3}),
+	}}
+
+	assert.DeepEqual(t, texts(ViewOptions{}.Apply(summaryData)), []string{"ball", "cat", "dog", "court"})
+	assert.DeepEqual(t, texts(ViewOptions{Sort: sortByConfidence}.Apply(summaryData)), []string{"ball", "court", "dog", "cat"})
+	assert.DeepEqual(t, texts(ViewOptions{Sort: sortByLabel}.Apply(summaryData)), []string{"ball", "cat", "court", "dog"})
+
+	assert.DeepEqual(t, texts(ViewOptions{Labels: []string{"court"}}.Apply(summaryData)), []string{"ball", "court"})
+	assert.DeepEqual(t, texts(ViewOptions{Labels: []string{"court"}, MinConfidence: 0.5}.Apply(summaryData)), []string{"court"})
+	assert.DeepEqual(t, texts(ViewOptions{MinConfidence: 0.65}.Apply(summaryData)), []string{"ball", "court"})
+
+	pagedData := ViewOptions{Sort: sortByConfidence, Page: 2, PageSize: 3}.Apply(summaryData)
+	assert.DeepEqual(t, texts(pagedData), []string{"cat"})
+	assert.DeepEqual(t, *pagedData.Pagination, SummaryPagination{Page: 2, PageSize: 3, Pages: 2, Total: 4})
+	assert.Equal(t, pagedData.Query.View.Sort, sortByConfidence)
+
+	pagedData = ViewOptions{Page: 5, PageSize: 3}.Apply(summaryData)
+	assert.Equal(t, pagedData.Pagination.Page, 2)
+
+	emptyData := ViewOptions{Labels: []string{"none"}, PageSize: 3}.Apply(summaryData)
+	assert.DeepEqual(t, emptyData.ClassifiedTweets, []ClassifiedTweet{})
+	assert.DeepEqual(t, *emptyData.Pagination, SummaryPagination{Page: 1, PageSize: 3, Pages: 1, Total: 0})
+}
+
+func TestViewOptionsApplyToTweets(t *testing.T) {
+	tweets := []Tweet{
+		Tweet{Text: "old", ImageURLs: []string{"1.jpg"}, CreatedAt: "2019-11-21T19:00:00Z"},
+		Tweet{Text: "no image", CreatedAt: "2019-11-21T22:00:00Z"},
+		Tweet{Text: "unknown", ImageURLs: []string{"2.jpg"}},
+		Tweet{Text: "new", ImageURLs: []string{"3.jpg"}, CreatedAt: "2019-11-21T21:00:00Z"},
+	}
+
+	pagedTweets, pagination := ViewOptions{Sort: sortByConfidence, PageSize: 2}.ApplyToTweets(tweets)
+	assert.Equal(t, len(pagedTweets), 2)
+	assert.Equal(t, pagedTweets[0].Text, "new")
+	assert.Equal(t, pagedTweets[1].Text, "old")
+	assert.DeepEqual(t, pagination, SummaryPagination{Page: 1, PageSize: 2, Pages: 2, Total: 3})
+}
+
+func TestTopLabels(t *testing.T) {
+	cTweet := ClassifiedTweet{ClassifiedImages: []ClassifiedImage{
+		ClassifiedImage{Labels: []Label{Label{Name: "ball", Score: 0.5}, Label{Name: "court", Score: 0.8}}},
+		ClassifiedImage{Labels: []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "crowd", Score: 0.2}}},
+	}}
+
+	assert.DeepEqual(t, cTweet.TopLabels(2), []Label{Label{Name: "ball", Score: 0.9}, Label{Name: "court", Score: 0.8}})
+	assert.Equal(t, len(cTweet.TopLabels(0)), 3)
+	assert.DeepEqual(t, ClassifiedTweet{}.TopLabels(3), []Label{})
+}
+
+func TestSummaryHandlerView(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json&label=ball&page-size=5", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	summaryData := SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.DeepEqual(t, summaryData.Query.View.Labels, []string{"ball"})
+	assert.DeepEqual(t, *summaryData.Pagination, SummaryPagination{Page: 1, PageSize: 5, Pages: 1, Total: 1})
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=json&label=dog", nil))
+	summaryData = SummaryData{}
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &summaryData))
+	assert.Equal(t, len(summaryData.ClassifiedTweets), 0)
+	assert.Equal(t, summaryData.Stats.ImageCount, 1)
+	assert.Equal(t, len(summaryFn.ViewOptions.Labels), 0)
+
+	recorder = httptest.NewRecorder()
+	summaryFn.SummaryHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&o=html&view=table", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `<table id="tweets-table">`))
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `<td><div>ball</div></td>`))
+	assert.Assert(t, strings.Contains(recorder.Body.String(), `href="/?o=html&amp;q=NBA&amp;sort=confidence&amp;view=table"`))
+}
+
+func TestSummaryAsyncHandlerView(t *testing.T) {
+	summaryFn, closeServers := newTestSummaryFn(t)
+	defer closeServers()
+
+	recorder := httptest.NewRecorder()
+	summaryFn.SummaryAsyncHandler(recorder, httptest.NewRequest("GET", "/?q=NBA&view=table&page-size=1", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+
+	body := recorder.Body.String()
+	assert.Assert(t, strings.Contains(body, `<tbody id="tweets">`))
+	assert.Assert(t, strings.Contains(body, `src="http://img/1.jpg"`))
+	assert.Assert(t, !strings.Contains(body, `src="http://img/fail.jpg"`))
+	assert.Assert(t, strings.Contains(body, "page 1 of 2 (2 tweets with images)"))
+	assert.Assert(t, strings.Contains(body, `<a href="/?page=2&amp;page-size=1&amp;q=NBA&amp;view=table">next</a>`))
+}
+
+// Private
+
+func texts(summaryData SummaryData) []string {
+	texts := []string{}
+	for _, cTweet := range summaryData.ClassifiedTweets {
+		texts = append(texts, cTweet.Text)
+	}
+	return texts
+}
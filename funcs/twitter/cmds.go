@@ -12,10 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package twitter
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"os"
 
@@ -65,6 +65,7 @@ and responds with with content of that tweet`,
 	}
 
 	searchFn.AddCommonCmdFlags(searchCmd)
+	searchCmd.Flags().StringVar(&searchFn.SinceID, "since-id", "", "only search for tweets newer than this tweet ID")
 	searchFn.addTwitterCmdFlags(twitterCmd)
 
 	twitterCmd.AddCommand(searchCmd)
@@ -76,24 +77,60 @@ func Execute() error {
 	return NewTwitterCmd().Execute()
 }
 
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if searchFn == nil {
+		NewTwitterCmd()
+	}
+
+	searchFn.initTwitterKeysFlags()
+	return searchFn.handler(), nil
+}
+
 // Private
 
 func (searchFn *SearchFn) search(cmd *cobra.Command, args []string) error {
 	if searchFn.StartServer {
-		http.HandleFunc("/", searchFn.SearchHandler)
-		return http.ListenAndServe(fmt.Sprintf(":%d", searchFn.Port), nil)
+		return searchFn.ListenAndServe(searchFn.handler())
+	} else if searchFn.Stdin {
+		return searchFn.ProcessLines(os.Stdin, os.Stdout, searchFn.searchLine)
 	} else {
 		tweetsData, err := searchFn.Search()
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("%s\n", common.Flatten(&tweetsData, searchFn.Output, tweetsData.ToText))
+		err = common.PrintOutput(os.Stdout, &tweetsData, searchFn.Output, tweetsData.ToText)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// searchLine searches for a line of the standard input as search string
+func (searchFn *SearchFn) searchLine(line string) (string, error) {
+	lineFn := *searchFn
+	lineFn.SearchString = line
+
+	tweetsData, err := lineFn.Search()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&tweetsData, lineFn.Output, tweetsData.ToText)
+}
+
+func (searchFn *SearchFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", searchFn.HealthzHandler)
+	mux.HandleFunc("/", searchFn.SearchHandler)
+	return mux
+}
+
 func (searchFn *SearchFn) addTwitterCmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&searchFn.keys.twitterAPIKey, "twitter-api-key", "", "twitter API key")
 	cmd.PersistentFlags().StringVar(&searchFn.keys.twitterAPISecretKey, "twitter-api-secret-key", "", "twitter API secret key")
@@ -12,13 +12,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package twitter
 
 import (
 	"bytes"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/maximilien/knfun/funcs/common"
 
@@ -34,8 +36,10 @@ type keys struct {
 }
 
 type TweetData struct {
+	ID        string   `yaml:"id,omitempty" json:"id,omitempty"`
 	Text      string   `yaml:"text" json:"text"`
 	ImageURLs []string `yaml:"image-urls" json:"image-urls"`
+	CreatedAt string   `yaml:"created-at,omitempty" json:"created-at,omitempty"`
 }
 
 type TweetsData []TweetData
@@ -43,15 +47,28 @@ type TweetsData []TweetData
 type SearchFn struct {
 	common.CommonFn
 
+	// SinceID restricts the search to tweets newer than that tweet ID
+	SinceID string
+
 	keys keys
 }
 
 func (searchFn *SearchFn) Search() (TweetsData, error) {
-	client := searchFn.createTwitterClient()
-	results, _, err := client.Search.Tweets(&twitter.SearchTweetParams{
+	searchTweetParams := &twitter.SearchTweetParams{
 		Query: searchFn.SearchString,
 		Count: searchFn.Count,
-	})
+	}
+
+	if searchFn.SinceID != "" {
+		sinceID, err := strconv.ParseInt(searchFn.SinceID, 10, 64)
+		if err != nil {
+			return []TweetData{}, fmt.Errorf("invalid since ID '%s': %s", searchFn.SinceID, err.Error())
+		}
+		searchTweetParams.SinceID = sinceID
+	}
+
+	client := searchFn.createTwitterClient()
+	results, _, err := client.Search.Tweets(searchTweetParams)
 	if err != nil {
 		return []TweetData{}, err
 	}
@@ -61,7 +78,8 @@ func (searchFn *SearchFn) Search() (TweetsData, error) {
 
 func (searchFn *SearchFn) SearchHandler(writer http.ResponseWriter, request *http.Request) {
 	searchFn.InitCommonQueryParams(request)
-	log.Printf("TwitterFn.Search: q=\"%s\", c=\"%d\", o=\"%s\"", searchFn.SearchString, searchFn.Count, searchFn.Output)
+	searchFn.SinceID = searchFn.ExtractQueryStringParam(request, []string{"since-id", "since_id"}, "")
+	log.Printf("TwitterFn.Search: q=\"%s\", c=\"%d\", o=\"%s\", since-id=\"%s\"", searchFn.SearchString, searchFn.Count, searchFn.Output, searchFn.SinceID)
 
 	tweetsData, err := searchFn.Search()
 	if err != nil {
@@ -69,8 +87,7 @@ func (searchFn *SearchFn) SearchHandler(writer http.ResponseWriter, request *htt
 		return
 	}
 
-	writer.Header().Add("Content-Type", searchFn.OutputContentType(searchFn.Output))
-	fmt.Fprintf(writer, "%s\n", common.Flatten(&tweetsData, searchFn.Output, tweetsData.ToText))
+	common.RespondOutput(writer, http.StatusOK, &tweetsData, searchFn.Output, tweetsData.ToText)
 }
 
 // Private SearchFn
@@ -85,7 +102,10 @@ func (searchFn *SearchFn) createTwitterClient() *twitter.Client {
 func (searchFn *SearchFn) collectTweetsData(tweets []twitter.Tweet) TweetsData {
 	tweetsData := TweetsData{}
 	for _, tweet := range tweets {
-		tweetData := TweetData{Text: tweet.Text}
+		tweetData := TweetData{ID: tweet.IDStr, Text: tweet.Text}
+		if createdAt, err := tweet.CreatedAtTime(); err == nil {
+			tweetData.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		}
 		imageURLs := []string{}
 		for _, media := range tweet.Entities.Media {
 			if media.MediaURL != "photo" {
@@ -0,0 +1,328 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maximilien/knfun/funcs/common"
+
+	vr3 "github.com/watson-developer-cloud/go-sdk/visualrecognitionv3"
+)
+
+const (
+	ClassifierStatusReady      = "ready"
+	ClassifierStatusTraining   = "training"
+	ClassifierStatusRetraining = "retraining"
+	ClassifierStatusFailed     = "failed"
+)
+
+type ClassifierData struct {
+	vr3.Classifier
+}
+
+type ClassifiersData []ClassifierData
+
+type DeletedClassifierData struct {
+	ClassifierID string `yaml:"classifier_id" json:"classifier_id"`
+	Deleted      bool   `yaml:"deleted" json:"deleted"`
+}
+
+type ClassifiersFn struct {
+	common.CommonFn
+
+	ClassifierID     string
+	Name             string
+	PositiveExamples map[string]string
+	NegativeExamples string
+	Verbose          bool
+
+	Wait         bool
+	WaitTimeout  int
+	PollInterval int
+
+	keys *keys
+}
+
+func (classifiersFn *ClassifiersFn) ListClassifiers() (ClassifiersData, error) {
+	vr, err := newWatsonClient(*classifiersFn.keys)
+	if err != nil {
+		return ClassifiersData{}, err
+	}
+
+	listClassifiersOptions := vr.NewListClassifiersOptions()
+	listClassifiersOptions.SetVerbose(classifiersFn.Verbose)
+
+	classifiers, _, err := vr.ListClassifiers(listClassifiersOptions)
+	if err != nil {
+		return ClassifiersData{}, fmt.Errorf("error listing classifiers: %s", err.Error())
+	}
+
+	classifiersData := ClassifiersData{}
+	for _, classifier := range classifiers.Classifiers {
+		classifiersData = append(classifiersData, ClassifierData{classifier})
+	}
+	return classifiersData, nil
+}
+
+func (classifiersFn *ClassifiersFn) GetClassifier() (ClassifierData, error) {
+	vr, err := newWatsonClient(*classifiersFn.keys)
+	if err != nil {
+		return ClassifierData{}, err
+	}
+
+	classifier, _, err := vr.GetClassifier(vr.NewGetClassifierOptions(classifiersFn.ClassifierID))
+	if err != nil {
+		return ClassifierData{}, fmt.Errorf("error getting classifier '%s': %s", classifiersFn.ClassifierID, err.Error())
+	}
+
+	return ClassifierData{*classifier}, nil
+}
+
+func (classifiersFn *ClassifiersFn) CreateClassifier() (ClassifierData, error) {
+	vr, err := newWatsonClient(*classifiersFn.keys)
+	if err != nil {
+		return ClassifierData{}, err
+	}
+
+	createClassifierOptions := vr.NewCreateClassifierOptions(classifiersFn.Name)
+
+	positiveExamples, err := classifiersFn.openPositiveExamples()
+	defer closeExamples(positiveExamples)
+	if err != nil {
+		return ClassifierData{}, err
+	}
+	createClassifierOptions.PositiveExamples = positiveExamples
+
+	if classifiersFn.NegativeExamples != "" {
+		negativeExamples, err := os.Open(classifiersFn.NegativeExamples)
+		if err != nil {
+			return ClassifierData{}, fmt.Errorf("error opening negative examples: %s", err.Error())
+		}
+		defer negativeExamples.Close()
+
+		createClassifierOptions.SetNegativeExamples(negativeExamples)
+		createClassifierOptions.SetNegativeExamplesFilename(filepath.Base(classifiersFn.NegativeExamples))
+	}
+
+	classifier, _, err := vr.CreateClassifier(createClassifierOptions)
+	if err != nil {
+		return ClassifierData{}, fmt.Errorf("error creating classifier '%s': %s", classifiersFn.Name, err.Error())
+	}
+
+	classifiersFn.ClassifierID = *classifier.ClassifierID
+	return ClassifierData{*classifier}, nil
+}
+
+func (classifiersFn *ClassifiersFn) UpdateClassifier() (ClassifierData, error) {
+	vr, err := newWatsonClient(*classifiersFn.keys)
+	if err != nil {
+		return ClassifierData{}, err
+	}
+
+	updateClassifierOptions := vr.NewUpdateClassifierOptions(classifiersFn.ClassifierID)
+
+	positiveExamples, err := classifiersFn.openPositiveExamples()
+	defer closeExamples(positiveExamples)
+	if err != nil {
+		return ClassifierData{}, err
+	}
+	if len(positiveExamples) > 0 {
+		updateClassifierOptions.PositiveExamples = positiveExamples
+	}
+
+	if classifiersFn.NegativeExamples != "" {
+		negativeExamples, err := os.Open(classifiersFn.NegativeExamples)
+		if err != nil {
+			return ClassifierData{}, fmt.Errorf("error opening negative examples: %s", err.Error())
+		}
+		defer negativeExamples.Close()
+
+		updateClassifierOptions.SetNegativeExamples(negativeExamples)
+		updateClassifierOptions.SetNegativeExamplesFilename(filepath.Base(classifiersFn.NegativeExamples))
+	}
+
+	classifier, _, err := vr.UpdateClassifier(updateClassifierOptions)
+	if err != nil {
+		return ClassifierData{}, fmt.Errorf("error updating classifier '%s': %s", classifiersFn.ClassifierID, err.Error())
+	}
+
+	return ClassifierData{*classifier}, nil
+}
+
+func (classifiersFn *ClassifiersFn) DeleteClassifier() (DeletedClassifierData, error) {
+	vr, err := newWatsonClient(*classifiersFn.keys)
+	if err != nil {
+		return DeletedClassifierData{}, err
+	}
+
+	_, err = vr.DeleteClassifier(vr.NewDeleteClassifierOptions(classifiersFn.ClassifierID))
+	if err != nil {
+		return DeletedClassifierData{}, fmt.Errorf("error deleting classifier '%s': %s", classifiersFn.ClassifierID, err.Error())
+	}
+
+	return DeletedClassifierData{ClassifierID: classifiersFn.ClassifierID, Deleted: true}, nil
+}
+
+// WaitForClassifier polls the classifier until it is no longer training and
+// errors if training failed or did not finish within the wait timeout
+func (classifiersFn *ClassifiersFn) WaitForClassifier() (ClassifierData, error) {
+	return classifiersFn.waitForClassifier(classifiersFn.GetClassifier)
+}
+
+// Private ClassifiersFn
+
+func (classifiersFn *ClassifiersFn) waitForClassifier(getClassifier func() (ClassifierData, error)) (ClassifierData, error) {
+	deadline := time.Now().Add(time.Duration(classifiersFn.WaitTimeout) * time.Second)
+
+	for {
+		classifierData, err := getClassifier()
+		if err != nil {
+			return ClassifierData{}, err
+		}
+
+		switch classifierData.status() {
+		case ClassifierStatusTraining, ClassifierStatusRetraining:
+			log.Printf("Classifier '%s' is %s", classifiersFn.ClassifierID, classifierData.status())
+		case ClassifierStatusFailed:
+			return classifierData, fmt.Errorf("classifier '%s' failed training: %s", classifiersFn.ClassifierID, classifierData.explanation())
+		default:
+			return classifierData, nil
+		}
+
+		if time.Now().Add(classifiersFn.pollInterval()).After(deadline) {
+			return classifierData, fmt.Errorf("timed out after %ds waiting for classifier '%s' to be %s", classifiersFn.WaitTimeout, classifiersFn.ClassifierID, ClassifierStatusReady)
+		}
+		time.Sleep(classifiersFn.pollInterval())
+	}
+}
+
+func (classifiersFn *ClassifiersFn) pollInterval() time.Duration {
+	if classifiersFn.PollInterval <= 0 {
+		return time.Second
+	}
+	return time.Duration(classifiersFn.PollInterval) * time.Second
+}
+
+// openPositiveExamples opens the zip file of each class, sorted by class name
+// so errors are reported deterministically
+func (classifiersFn *ClassifiersFn) openPositiveExamples() (map[string]io.ReadCloser, error) {
+	classNames := []string{}
+	for className := range classifiersFn.PositiveExamples {
+		classNames = append(classNames, className)
+	}
+	sort.Strings(classNames)
+
+	positiveExamples := map[string]io.ReadCloser{}
+	for _, className := range classNames {
+		examplesPath := classifiersFn.PositiveExamples[className]
+		if strings.TrimSpace(className) == "" {
+			return positiveExamples, fmt.Errorf("missing class name for positive examples '%s'", examplesPath)
+		}
+
+		examples, err := os.Open(examplesPath)
+		if err != nil {
+			return positiveExamples, fmt.Errorf("error opening positive examples for class '%s': %s", className, err.Error())
+		}
+		positiveExamples[className] = examples
+	}
+
+	return positiveExamples, nil
+}
+
+// Private functions
+
+func closeExamples(examples map[string]io.ReadCloser) {
+	for _, example := range examples {
+		example.Close()
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// Private ClassifierData
+
+func (classifierData ClassifierData) status() string {
+	if classifierData.Status == nil {
+		return ""
+	}
+	return *classifierData.Status
+}
+
+func (classifierData ClassifierData) explanation() string {
+	if classifierData.Explanation == nil {
+		return "no explanation"
+	}
+	return *classifierData.Explanation
+}
+
+// Public ClassifierData
+
+func (classifierData ClassifierData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+
+	sb.WriteString(fmt.Sprintf("name: %s\n", stringValue(classifierData.Name)))
+	sb.WriteString(fmt.Sprintf("ID: %s\n", stringValue(classifierData.ClassifierID)))
+	if classifierData.Owner != nil {
+		sb.WriteString(fmt.Sprintf("owner: %s\n", *classifierData.Owner))
+	}
+	if classifierData.Status != nil {
+		sb.WriteString(fmt.Sprintf("status: %s\n", *classifierData.Status))
+	}
+	if classifierData.Explanation != nil && *classifierData.Explanation != "" {
+		sb.WriteString(fmt.Sprintf("explanation: %s\n", *classifierData.Explanation))
+	}
+	if classifierData.Created != nil {
+		sb.WriteString(fmt.Sprintf("created: %s\n", classifierData.Created.String()))
+	}
+	if classifierData.Retrained != nil {
+		sb.WriteString(fmt.Sprintf("retrained: %s\n", classifierData.Retrained.String()))
+	}
+	for _, class := range classifierData.Classes {
+		sb.WriteString(fmt.Sprintf("- class: %s\n", stringValue(class.Class)))
+	}
+
+	return sb.String()
+}
+
+// Public ClassifiersData
+
+func (classifiersData ClassifiersData) ToText(in interface{}) string {
+	sb := bytes.NewBufferString("")
+	for _, classifierData := range classifiersData {
+		sb.WriteString(classifierData.ToText(classifierData))
+		sb.WriteString("----\n")
+	}
+	return sb.String()
+}
+
+// Public DeletedClassifierData
+
+func (deletedData DeletedClassifierData) ToText(in interface{}) string {
+	return fmt.Sprintf("classifier '%s' deleted\n", deletedData.ClassifierID)
+}
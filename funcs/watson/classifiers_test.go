@@ -0,0 +1,93 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watson
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/core"
+	vr3 "github.com/watson-developer-cloud/go-sdk/visualrecognitionv3"
+	"gotest.tools/assert"
+)
+
+func TestWaitForClassifier(t *testing.T) {
+	classifiersFn := &ClassifiersFn{ClassifierID: "products_1", WaitTimeout: 60, PollInterval: -1}
+
+	statuses := []string{ClassifierStatusTraining, ClassifierStatusRetraining, ClassifierStatusReady}
+	calls := 0
+	classifierData, err := classifiersFn.waitForClassifier(func() (ClassifierData, error) {
+		calls++
+		return newClassifierData(statuses[calls-1], ""), nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+	assert.Equal(t, classifierData.status(), ClassifierStatusReady)
+}
+
+func TestWaitForClassifierFailed(t *testing.T) {
+	classifiersFn := &ClassifiersFn{ClassifierID: "products_1", WaitTimeout: 60}
+
+	_, err := classifiersFn.waitForClassifier(func() (ClassifierData, error) {
+		return newClassifierData(ClassifierStatusFailed, "not enough examples"), nil
+	})
+	assert.ErrorContains(t, err, "not enough examples")
+
+	_, err = classifiersFn.waitForClassifier(func() (ClassifierData, error) {
+		return ClassifierData{}, errors.New("not found")
+	})
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestWaitForClassifierTimeout(t *testing.T) {
+	classifiersFn := &ClassifiersFn{ClassifierID: "products_1", WaitTimeout: 0}
+
+	_, err := classifiersFn.waitForClassifier(func() (ClassifierData, error) {
+		return newClassifierData(ClassifierStatusTraining, ""), nil
+	})
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestOpenPositiveExamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watson")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	shoesPath := filepath.Join(dir, "shoes.zip")
+	assert.NilError(t, ioutil.WriteFile(shoesPath, []byte("zip"), 0600))
+
+	classifiersFn := &ClassifiersFn{PositiveExamples: map[string]string{"shoes": shoesPath}}
+	positiveExamples, err := classifiersFn.openPositiveExamples()
+	assert.NilError(t, err)
+	assert.Equal(t, len(positiveExamples), 1)
+	closeExamples(positiveExamples)
+
+	classifiersFn.PositiveExamples["hats"] = filepath.Join(dir, "hats.zip")
+	positiveExamples, err = classifiersFn.openPositiveExamples()
+	closeExamples(positiveExamples)
+	assert.ErrorContains(t, err, "class 'hats'")
+}
+
+func newClassifierData(status string, explanation string) ClassifierData {
+	return ClassifierData{vr3.Classifier{
+		ClassifierID: core.StringPtr("products_1"),
+		Name:         core.StringPtr("products"),
+		Status:       core.StringPtr(status),
+		Explanation:  core.StringPtr(explanation),
+	}}
+}
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package watson
 
 import (
 	"bytes"
@@ -20,6 +20,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/maximilien/knfun/funcs/common"
 
@@ -31,17 +35,32 @@ type keys struct {
 	watsonAPIKey     string
 	watsonAPIURL     string
 	watsonAPIVersion string
+
+	// iamURL is the IAM token endpoint, the IBM Cloud one when empty
+	iamURL string
 }
 
 type ClassifyImageData struct {
 	vr3.ClassifiedImage
 	Warnings []vr3.WarningInfo
+
+	CustomClasses   *int64                `yaml:"custom_classes,omitempty" json:"custom_classes,omitempty"`
+	ImagesProcessed *int64                `yaml:"images_processed,omitempty" json:"images_processed,omitempty"`
+	Images          []vr3.ClassifiedImage `yaml:"images,omitempty" json:"images,omitempty"`
 }
 
 type ClassifyImageFn struct {
 	common.CommonFn
+	common.ClassifyFn
+	common.CORSFn
+
+	ImageURL  string
+	ImageURLs []string
 
-	ImageURL string
+	ClassifierIDs  []string
+	Owners         []string
+	Threshold      float64
+	AcceptLanguage string
 
 	keys keys
 }
@@ -52,60 +71,203 @@ func (classifyImageFn *ClassifyImageFn) ClassifyImage() (ClassifyImageData, erro
 		return ClassifyImageData{}, err
 	}
 
-	classifiedImages, _, err := vr.Classify(
-		&vr3.ClassifyOptions{
-			URL: core.StringPtr(classifyImageFn.ImageURL),
-		},
-	)
-	if err != nil {
-		return ClassifyImageData{}, errors.New(fmt.Sprintf("Error classifying image: %s\n", err.Error()))
+	results := []*vr3.ClassifiedImages{}
+	for _, imageURL := range classifyImageFn.imageURLs() {
+		classifyOptions, err := classifyImageFn.classifyOptions(imageURL)
+		if err != nil {
+			return ClassifyImageData{}, err
+		}
+
+		classifiedImages, _, err := vr.Classify(classifyOptions)
+		if classifyOptions.ImagesFile != nil {
+			classifyOptions.ImagesFile.Close()
+		}
+		if err != nil {
+			return ClassifyImageData{}, errors.New(fmt.Sprintf("Error classifying image: %s\n", err.Error()))
+		}
+
+		results = append(results, classifiedImages)
 	}
 
-	return classifyImageFn.collectClassifyImageData(classifiedImages), nil
+	return classifyImageFn.collectClassifyImageData(results), nil
 }
 
 func (classifyImageFn *ClassifyImageFn) ClassifyHandler(writer http.ResponseWriter, request *http.Request) {
-	classifyImageFn.initQueryParams(request)
-	log.Printf("WatsonFn.Classify: q=\"%s\", o=\"%s\"", classifyImageFn.ImageURL, classifyImageFn.Output)
+	if classifyImageFn.WriteCORSHeaders(writer, request) {
+		return
+	}
 
-	classifiedImageData, err := classifyImageFn.ClassifyImage()
+	fn := classifyImageFn.requestFn(request)
+	for _, imageURL := range fn.imageURLs() {
+		if err := common.ValidateHTTPURL(imageURL); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	log.Printf("WatsonFn.Classify: q=\"%s\", classifier-ids=\"%s\", owners=\"%s\", max=\"%d\", threshold=\"%1.3f\", o=\"%s\"", strings.Join(fn.imageURLs(), ","), strings.Join(fn.ClassifierIDs, ","), strings.Join(fn.Owners, ","), fn.MaxLabels, fn.threshold(), fn.Output)
+
+	classifiedImageData, err := fn.ClassifyImage()
 	if err != nil {
-		log.Printf(err.Error())
+		log.Print(err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	writer.Header().Add("Access-Control-Allow-Origin", "*")
-	writer.Header().Add("Access-Control-Allow-Headers", "x-requested-with")
-
-	writer.Header().Add("Content-Type", classifyImageFn.OutputContentType(classifyImageFn.Output))
-	fmt.Fprintf(writer, "%s\n", common.Flatten(&classifiedImageData, classifyImageFn.Output, classifiedImageData.ToText))
+	common.RespondOutput(writer, http.StatusOK, &classifiedImageData, fn.Output, classifiedImageData.ToText)
 }
 
 // Private classifyImageFn
 
-func (classifyImageFn *ClassifyImageFn) initQueryParams(request *http.Request) {
-	classifyImageFn.ImageURL = classifyImageFn.ExtractQueryStringParam(request, []string{"query", "q", "image-url", "u"}, classifyImageFn.ImageURL)
-	classifyImageFn.Output = classifyImageFn.ExtractQueryStringParam(request, []string{"o", "output"}, classifyImageFn.Output)
+// requestFn returns a copy of the flag values with the request query params applied
+func (classifyImageFn *ClassifyImageFn) requestFn(request *http.Request) *ClassifyImageFn {
+	fn := *classifyImageFn
+	fn.ImageURLs = fn.ExtractQueryStringSliceParam(request, []string{"query", "q", "image-url", "u"}, fn.ImageURLs)
+	fn.Output = fn.ExtractQueryStringParam(request, []string{"o", "output"}, fn.Output)
+	fn.InitClassifyQueryParams(request)
+
+	fn.ClassifierIDs = splitValues(fn.ExtractQueryStringSliceParam(request, []string{"classifier-ids", "classifier_ids"}, fn.ClassifierIDs))
+	fn.Owners = splitValues(fn.ExtractQueryStringSliceParam(request, []string{"owners"}, fn.Owners))
+	fn.Threshold = fn.ExtractQueryFloatParam(request, []string{"threshold"}, fn.Threshold)
+	if acceptLanguage := request.Header.Get("Accept-Language"); acceptLanguage != "" {
+		fn.AcceptLanguage = acceptLanguage
+	}
+	fn.AcceptLanguage = fn.ExtractQueryStringParam(request, []string{"accept-language", "lang"}, fn.AcceptLanguage)
+	return &fn
+}
+
+func (classifyImageFn *ClassifyImageFn) imageURLs() []string {
+	if len(classifyImageFn.ImageURLs) > 0 {
+		return classifyImageFn.ImageURLs
+	}
+	return []string{classifyImageFn.ImageURL}
+}
+
+// classifyOptions sends remote images (or zip files) by URL and uploads local
+// image or zip files
+func (classifyImageFn *ClassifyImageFn) classifyOptions(imageURL string) (*vr3.ClassifyOptions, error) {
+	classifyOptions := &vr3.ClassifyOptions{}
+
+	// only the CLI uploads local files, the servers classify URLs
+	if strings.HasPrefix(imageURL, "http") || classifyImageFn.StartServer || !common.FileExists(imageURL) {
+		classifyOptions.SetURL(imageURL)
+	} else {
+		imagesFile, err := os.Open(imageURL)
+		if err != nil {
+			return nil, fmt.Errorf("error opening images file: %s", err.Error())
+		}
+		classifyOptions.SetImagesFile(imagesFile)
+		classifyOptions.SetImagesFilename(filepath.Base(imageURL))
+	}
+
+	if threshold := classifyImageFn.threshold(); threshold > 0 {
+		classifyOptions.SetThreshold(float32(threshold))
+	}
+
+	if len(classifyImageFn.ClassifierIDs) > 0 {
+		classifyOptions.SetClassifierIds(classifyImageFn.ClassifierIDs)
+	}
+
+	if len(classifyImageFn.Owners) > 0 {
+		classifyOptions.SetOwners(classifyImageFn.Owners)
+	}
+
+	if classifyImageFn.AcceptLanguage != "" {
+		classifyOptions.SetAcceptLanguage(classifyImageFn.AcceptLanguage)
+	}
+
+	return classifyOptions, nil
+}
+
+func (classifyImageFn *ClassifyImageFn) threshold() float64 {
+	if classifyImageFn.Threshold > 0 {
+		return classifyImageFn.Threshold
+	}
+	return classifyImageFn.MinScore
 }
 
 func (classifyImageFn *ClassifyImageFn) createWatsonClient() (*vr3.VisualRecognitionV3, error) {
+	return newWatsonClient(classifyImageFn.keys)
+}
+
+func (classifyImageFn *ClassifyImageFn) collectClassifyImageData(results []*vr3.ClassifiedImages) ClassifyImageData {
+	cIData := ClassifyImageData{}
+
+	var customClasses, imagesProcessed int64
+	images := []vr3.ClassifiedImage{}
+	for _, classifiedImages := range results {
+		images = append(images, classifiedImages.Images...)
+		cIData.Warnings = append(cIData.Warnings, classifiedImages.Warnings...)
+		if classifiedImages.CustomClasses != nil {
+			customClasses += *classifiedImages.CustomClasses
+		}
+		if classifiedImages.ImagesProcessed != nil {
+			imagesProcessed += *classifiedImages.ImagesProcessed
+		}
+	}
+
+	for i := range images {
+		for j, classifier := range images[i].Classifiers {
+			images[i].Classifiers[j].Classes = classifyImageFn.filterClasses(classifier.Classes)
+		}
+	}
+
+	if len(images) >= 1 {
+		cIData.ClassifiedImage = images[0]
+	}
+
+	if len(images) > 1 || customClasses > 0 {
+		cIData.CustomClasses = core.Int64Ptr(customClasses)
+		cIData.ImagesProcessed = core.Int64Ptr(imagesProcessed)
+		cIData.Images = images
+	}
+
+	return cIData
+}
+
+func (classifyImageFn *ClassifyImageFn) filterClasses(classes []vr3.ClassResult) []vr3.ClassResult {
+	sort.SliceStable(classes, func(i, j int) bool {
+		return classScore(classes[i]) > classScore(classes[j])
+	})
+
+	filteredClasses := []vr3.ClassResult{}
+	for _, class := range classes {
+		if classifyImageFn.Accept(len(filteredClasses), classScore(class)) {
+			filteredClasses = append(filteredClasses, class)
+		}
+	}
+	return filteredClasses
+}
+
+// Private functions
+
+func newWatsonClient(keys keys) (*vr3.VisualRecognitionV3, error) {
 	return vr3.NewVisualRecognitionV3(&vr3.VisualRecognitionV3Options{
-		URL:     classifyImageFn.keys.watsonAPIURL,
-		Version: classifyImageFn.keys.watsonAPIVersion,
+		URL:     keys.watsonAPIURL,
+		Version: keys.watsonAPIVersion,
 		Authenticator: &core.IamAuthenticator{
-			ApiKey: classifyImageFn.keys.watsonAPIKey,
+			ApiKey: keys.watsonAPIKey,
+			URL:    keys.iamURL,
 		},
 	})
 }
 
-func (classifyImageFn *ClassifyImageFn) collectClassifyImageData(classifiedImages *vr3.ClassifiedImages) ClassifyImageData {
-	cIData := ClassifyImageData{}
-	if *classifiedImages.ImagesProcessed >= 1 {
-		cIData.ClassifiedImage = classifiedImages.Images[0]
-		cIData.Warnings = classifiedImages.Warnings
+func splitValues(values []string) []string {
+	splitValues := []string{}
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				splitValues = append(splitValues, v)
+			}
+		}
 	}
+	return splitValues
+}
 
-	return cIData
+func classScore(class vr3.ClassResult) float64 {
+	if class.Score == nil {
+		return 0.0
+	}
+	return float64(*class.Score)
 }
 
 // Private ClassifyImageData
@@ -113,9 +275,35 @@ func (classifyImageFn *ClassifyImageFn) collectClassifyImageData(classifiedImage
 func (cIData ClassifyImageData) ToText(in interface{}) string {
 	sb := bytes.NewBufferString("")
 
-	sb.WriteString(fmt.Sprintf("source URL: %s\n", *cIData.ClassifiedImage.SourceURL))
-	sb.WriteString(fmt.Sprintf("resolved URL: %s\n", *cIData.ClassifiedImage.ResolvedURL))
-	for _, classifier := range cIData.ClassifiedImage.Classifiers {
+	if len(cIData.Images) == 0 {
+		writeClassifiedImageText(sb, cIData.ClassifiedImage)
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("images processed: %d\n", *cIData.ImagesProcessed))
+	sb.WriteString(fmt.Sprintf("custom classes: %d\n", *cIData.CustomClasses))
+	for _, classifiedImage := range cIData.Images {
+		sb.WriteString("====\n")
+		writeClassifiedImageText(sb, classifiedImage)
+	}
+
+	return sb.String()
+}
+
+func writeClassifiedImageText(sb *bytes.Buffer, classifiedImage vr3.ClassifiedImage) {
+	if classifiedImage.SourceURL != nil {
+		sb.WriteString(fmt.Sprintf("source URL: %s\n", *classifiedImage.SourceURL))
+	}
+	if classifiedImage.ResolvedURL != nil {
+		sb.WriteString(fmt.Sprintf("resolved URL: %s\n", *classifiedImage.ResolvedURL))
+	}
+	if classifiedImage.Image != nil {
+		sb.WriteString(fmt.Sprintf("image: %s\n", *classifiedImage.Image))
+	}
+	if classifiedImage.Error != nil && classifiedImage.Error.Description != nil {
+		sb.WriteString(fmt.Sprintf("error: %s\n", *classifiedImage.Error.Description))
+	}
+	for _, classifier := range classifiedImage.Classifiers {
 		sb.WriteString("----\n")
 		sb.WriteString(fmt.Sprintf("name: %s\n", *classifier.Name))
 		sb.WriteString(fmt.Sprintf("ID: %s\n", *classifier.ClassifierID))
@@ -133,6 +321,4 @@ func (cIData ClassifyImageData) ToText(in interface{}) string {
 		}
 		sb.WriteString("----\n")
 	}
-
-	return sb.String()
 }
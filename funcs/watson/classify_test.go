@@ -0,0 +1,257 @@
+// Copyright © 2019 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/core"
+	vr3 "github.com/watson-developer-cloud/go-sdk/visualrecognitionv3"
+	"gotest.tools/assert"
+)
+
+func TestClassifyOptions(t *testing.T) {
+	classifyImageFn := &ClassifyImageFn{
+		ClassifierIDs:  []string{"default", "food"},
+		Owners:         []string{"IBM", "me"},
+		Threshold:      0.6,
+		AcceptLanguage: "fr",
+	}
+	classifyImageFn.MinScore = 0.2
+
+	classifyOptions, err := classifyImageFn.classifyOptions("https://images.example/nba.jpg")
+	assert.NilError(t, err)
+	assert.Equal(t, *classifyOptions.URL, "https://images.example/nba.jpg")
+	assert.Equal(t, *classifyOptions.Threshold, float32(0.6))
+	assert.DeepEqual(t, classifyOptions.ClassifierIds, []string{"default", "food"})
+	assert.DeepEqual(t, classifyOptions.Owners, []string{"IBM", "me"})
+	assert.Equal(t, *classifyOptions.AcceptLanguage, "fr")
+	assert.Assert(t, classifyOptions.ImagesFile == nil)
+}
+
+func TestClassifyOptionsImagesFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "images*.zip")
+	assert.NilError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	classifyImageFn := &ClassifyImageFn{}
+	classifyImageFn.MinScore = 0.2
+
+	classifyOptions, err := classifyImageFn.classifyOptions(file.Name())
+	assert.NilError(t, err)
+	defer classifyOptions.ImagesFile.Close()
+
+	assert.Assert(t, classifyOptions.URL == nil)
+	assert.Assert(t, classifyOptions.ImagesFile != nil)
+	assert.Equal(t, *classifyOptions.Threshold, float32(0.2))
+	assert.Assert(t, classifyOptions.ClassifierIds == nil)
+
+	// the servers never upload their local files
+	classifyImageFn.StartServer = true
+	classifyOptions, err = classifyImageFn.classifyOptions(file.Name())
+	assert.NilError(t, err)
+	assert.Equal(t, *classifyOptions.URL, file.Name())
+	assert.Assert(t, classifyOptions.ImagesFile == nil)
+}
+
+func TestClassifyHandlerRejectsFiles(t *testing.T) {
+	for _, query := range []string{"q=%2Fetc%2Fhostname", "q=file%3A%2F%2F%2Fetc%2Fhostname", "q=https%3A%2F%2Fimages.example%2Fnba.jpg&q=%2Fetc%2Fhostname", ""} {
+		classifyImageFn := &ClassifyImageFn{}
+
+		recorder := httptest.NewRecorder()
+		classifyImageFn.ClassifyHandler(recorder, httptest.NewRequest("GET", "/?"+query, nil))
+		assert.Equal(t, recorder.Code, http.StatusBadRequest, query)
+		assert.Assert(t, strings.Contains(recorder.Body.String(), "expected an http or https URL"), query)
+	}
+}
+
+func TestRequestFn(t *testing.T) {
+	classifyImageFn := &ClassifyImageFn{
+		ImageURLs: []string{"https://images.example/flag.jpg"},
+		Owners:    []string{"IBM"},
+	}
+	classifyImageFn.MaxLabels = 10
+
+	request := httptest.NewRequest("GET", "/?q=https%3A%2F%2Fimages.example%2Fnba.jpg&owners=me&threshold=0.5&max=2", nil)
+	request.Header.Set("Accept-Language", "fr")
+	fn := classifyImageFn.requestFn(request)
+	assert.DeepEqual(t, fn.ImageURLs, []string{"https://images.example/nba.jpg"})
+	assert.DeepEqual(t, fn.Owners, []string{"me"})
+	assert.Equal(t, fn.Threshold, 0.5)
+	assert.Equal(t, fn.MaxLabels, 2)
+	assert.Equal(t, fn.AcceptLanguage, "fr")
+
+	// the params of a request never stick to the next ones
+	request = httptest.NewRequest("GET", "/?lang=de", nil)
+	request.Header.Set("Accept-Language", "es")
+	fn = classifyImageFn.requestFn(request)
+	assert.DeepEqual(t, fn.ImageURLs, []string{"https://images.example/flag.jpg"})
+	assert.DeepEqual(t, fn.Owners, []string{"IBM"})
+	assert.Equal(t, fn.Threshold, 0.0)
+	assert.Equal(t, fn.MaxLabels, 10)
+	assert.Equal(t, fn.AcceptLanguage, "de")
+
+	fn = classifyImageFn.requestFn(httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, fn.AcceptLanguage, "")
+}
+
+func TestCollectClassifyImageData(t *testing.T) {
+	classifyImageFn := &ClassifyImageFn{}
+	classifyImageFn.MaxLabels = 1
+
+	single := classifyImageFn.collectClassifyImageData([]*vr3.ClassifiedImages{
+		newClassifiedImages(0, "a.jpg"),
+	})
+	assert.Equal(t, *single.SourceURL, "a.jpg")
+	assert.Equal(t, len(single.Classifiers[0].Classes), 1)
+	assert.Equal(t, *single.Classifiers[0].Classes[0].Class, "ball")
+	assert.Assert(t, single.Images == nil)
+	assert.Assert(t, single.ImagesProcessed == nil)
+
+	multiple := classifyImageFn.collectClassifyImageData([]*vr3.ClassifiedImages{
+		newClassifiedImages(2, "a.jpg", "b.jpg"),
+		newClassifiedImages(1, "c.jpg"),
+	})
+	assert.Equal(t, *multiple.SourceURL, "a.jpg")
+	assert.Equal(t, len(multiple.Images), 3)
+	assert.Equal(t, *multiple.Images[2].SourceURL, "c.jpg")
+	assert.Equal(t, *multiple.ImagesProcessed, int64(3))
+	assert.Equal(t, *multiple.CustomClasses, int64(3))
+	assert.Equal(t, len(multiple.Images[1].Classifiers[0].Classes), 1)
+}
+
+func TestClassifyStdin(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	log.SetOutput(ioutil.Discard)
+
+	watsonServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/identity/token" {
+			fmt.Fprintf(writer, `{"access_token": "token", "token_type": "Bearer", "expires_in": 3600, "expiration": %d}`, time.Now().Add(time.Hour).Unix())
+			return
+		}
+
+		assert.Equal(t, request.URL.Path, "/v3/classify")
+		assert.Equal(t, request.Header.Get("Authorization"), "Bearer token")
+		imageURL := request.FormValue("url")
+		if imageURL == "https://images.example/fail.jpg" {
+			http.Error(writer, `{"error": "cannot classify"}`, http.StatusBadRequest)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"images": [{"source_url": "%s", "classifiers": [{"classifier_id": "default", "name": "default", "classes": [{"class": "ball", "score": 0.9}]}]}], "images_processed": 1}`, imageURL)
+	}))
+	defer watsonServer.Close()
+
+	classifyImageFn := &ClassifyImageFn{
+		keys: keys{
+			watsonAPIKey:     "key",
+			watsonAPIURL:     watsonServer.URL,
+			watsonAPIVersion: "2018-03-19",
+			iamURL:           watsonServer.URL + "/identity/token",
+		},
+	}
+	classifyImageFn.Output = "ndjson"
+	classifyImageFn.Concurrency = 2
+
+	writer := bytes.NewBufferString("")
+	input := "https://images.example/a.jpg\n\nhttps://images.example/fail.jpg\nhttps://images.example/b.jpg\n"
+	err := classifyImageFn.ProcessLines(strings.NewReader(input), writer, classifyImageFn.classifyLine)
+	assert.Error(t, err, "1 of 3 lines failed")
+
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	for i, imageURL := range []string{"https://images.example/a.jpg", "https://images.example/b.jpg"} {
+		classifyData := ClassifyImageData{}
+		assert.NilError(t, json.Unmarshal([]byte(lines[i]), &classifyData))
+		assert.Equal(t, *classifyData.SourceURL, imageURL)
+		assert.Equal(t, *classifyData.Classifiers[0].Classes[0].Class, "ball")
+	}
+	assert.Equal(t, classifyImageFn.ImageURL, "")
+}
+
+func TestFilterClasses(t *testing.T) {
+	newClasses := func() []vr3.ClassResult {
+		return []vr3.ClassResult{
+			vr3.ClassResult{Class: core.StringPtr("court"), Score: core.Float32Ptr(0.5)},
+			vr3.ClassResult{Class: core.StringPtr("ball"), Score: core.Float32Ptr(0.9)},
+			vr3.ClassResult{Class: core.StringPtr("crowd")},
+		}
+	}
+	classNames := func(classes []vr3.ClassResult) []string {
+		names := []string{}
+		for _, class := range classes {
+			names = append(names, *class.Class)
+		}
+		return names
+	}
+
+	for _, test := range []struct {
+		maxLabels int
+		minScore  float64
+		expected  []string
+	}{
+		{maxLabels: 0, minScore: 0.0, expected: []string{"ball", "court", "crowd"}},
+		{maxLabels: 0, minScore: 0.5, expected: []string{"ball", "court"}},
+		{maxLabels: 0, minScore: 0.51, expected: []string{"ball"}},
+		{maxLabels: 1, minScore: 0.0, expected: []string{"ball"}},
+		{maxLabels: 2, minScore: 0.9, expected: []string{"ball"}},
+		{maxLabels: 0, minScore: 0.95, expected: []string{}},
+	} {
+		classifyImageFn := &ClassifyImageFn{}
+		classifyImageFn.MaxLabels = test.maxLabels
+		classifyImageFn.MinScore = test.minScore
+
+		filteredClasses := classifyImageFn.filterClasses(newClasses())
+		assert.DeepEqual(t, classNames(filteredClasses), test.expected)
+	}
+}
+
+func TestSplitValues(t *testing.T) {
+	assert.DeepEqual(t, splitValues([]string{"default, food", "", "me"}), []string{"default", "food", "me"})
+}
+
+func newClassifiedImages(customClasses int64, sourceURLs ...string) *vr3.ClassifiedImages {
+	classifiedImages := &vr3.ClassifiedImages{
+		CustomClasses:   core.Int64Ptr(customClasses),
+		ImagesProcessed: core.Int64Ptr(int64(len(sourceURLs))),
+	}
+	for _, sourceURL := range sourceURLs {
+		classifiedImages.Images = append(classifiedImages.Images, vr3.ClassifiedImage{
+			SourceURL: core.StringPtr(sourceURL),
+			Classifiers: []vr3.ClassifierResult{
+				vr3.ClassifierResult{
+					Name:         core.StringPtr("default"),
+					ClassifierID: core.StringPtr("default"),
+					Classes: []vr3.ClassResult{
+						vr3.ClassResult{Class: core.StringPtr("court"), Score: core.Float32Ptr(0.5)},
+						vr3.ClassResult{Class: core.StringPtr("ball"), Score: core.Float32Ptr(0.9)},
+					},
+				},
+			},
+		})
+	}
+	return classifiedImages
+}
@@ -12,9 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package watson
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -27,12 +28,16 @@ import (
 
 var (
 	classifyImageFn *ClassifyImageFn
+	classifiersFn   *ClassifiersFn
 )
 
 func NewWatsonCmd() *cobra.Command {
 	classifyImageFn = &ClassifyImageFn{
 		keys: keys{},
 	}
+	classifiersFn = &ClassifiersFn{
+		keys: &classifyImageFn.keys,
+	}
 
 	cobra.OnInitialize(classifyImageFn.InitConfig)
 
@@ -42,6 +47,7 @@ func NewWatsonCmd() *cobra.Command {
 		Long:  `Various functions over the Watson API`,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			classifyImageFn.initWatsonKeysFlags()
+			classifyImageFn.InitCORSInputFlags()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
@@ -58,9 +64,11 @@ func NewWatsonCmd() *cobra.Command {
 	}
 
 	classifyCmd := &cobra.Command{
-		Use:   "classify [IMAGE_URL]",
+		Use:   "classify [IMAGE_URL...]",
 		Short: "classify image",
-		Long:  `classify an image (via its URL) using the Watson APIs`,
+		Long: `classify one or more images (via their URLs) using the Watson APIs.
+An IMAGE_URL can also be a local image or zip file, in which case it is uploaded
+and every image it contains is classified`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			classifyImageFn.initWatsonKeysFlags()
 			return classifyImageFn.initClassifyCmdInputFlags(args)
@@ -72,10 +80,13 @@ func NewWatsonCmd() *cobra.Command {
 
 	classifyImageFn.AddCommonCmdFlags(classifyCmd)
 	classifyImageFn.addWatsonCmdFlags(watsonCmd)
+	classifyImageFn.AddCORSCmdFlags(watsonCmd)
 	classifyImageFn.addClassifyCmdFlags(classifyCmd)
+	classifyImageFn.AddClassifyCmdFlags(classifyCmd)
 
 	watsonCmd.AddCommand(vrCmd)
 	vrCmd.AddCommand(classifyCmd)
+	vrCmd.AddCommand(newClassifiersCmd())
 
 	return watsonCmd
 }
@@ -84,24 +95,194 @@ func Execute() error {
 	return NewWatsonCmd().Execute()
 }
 
+// Handler configures the func from the config file and the environment, like
+// its commands do, and returns the handler of its server, e.g., to serve it
+// along other funcs in the same process
+func Handler(ctx context.Context) (http.Handler, error) {
+	if classifyImageFn == nil {
+		NewWatsonCmd()
+	}
+
+	classifyImageFn.StartServer = true
+	classifyImageFn.initWatsonKeysFlags()
+	classifyImageFn.InitCORSInputFlags()
+	return classifyImageFn.handler(), nil
+}
+
 // Private
 
+func newClassifiersCmd() *cobra.Command {
+	classifiersCmd := &cobra.Command{
+		Use:   "classifiers",
+		Short: "manage custom classifiers",
+		Long:  `list, get, create, update, and delete custom visual recognition classifiers`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list classifiers",
+		Long:  `list the custom classifiers`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			classifiersData, err := classifiersFn.ListClassifiers()
+			if err != nil {
+				return err
+			}
+
+			return common.PrintOutput(os.Stdout, &classifiersData, classifiersFn.Output, classifiersData.ToText)
+		},
+	}
+	listCmd.Flags().BoolVar(&classifiersFn.Verbose, "verbose", false, "list the details of each classifier, e.g., its classes and status")
+
+	getCmd := &cobra.Command{
+		Use:   "get CLASSIFIER_ID",
+		Short: "get classifier",
+		Long:  `get the details of a custom classifier, optionally waiting for its training to complete`,
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			classifiersFn.ClassifierID = args[0]
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return classifiersFn.printClassifier(classifiersFn.GetClassifier)
+		},
+	}
+	classifiersFn.addWaitCmdFlags(getCmd)
+
+	createCmd := &cobra.Command{
+		Use:   "create NAME",
+		Short: "create classifier",
+		Long: `create and train a custom classifier from zip files of positive examples
+for each class, e.g., --positive-examples shoes=shoes.zip,hats=hats.zip, and
+an optional zip file of negative examples`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			classifiersFn.Name = args[0]
+			if len(classifiersFn.PositiveExamples) == 0 {
+				return errors.New("you must pass at least one class of positive examples with --positive-examples")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return classifiersFn.printClassifier(classifiersFn.CreateClassifier)
+		},
+	}
+	classifiersFn.addExamplesCmdFlags(createCmd)
+	classifiersFn.addWaitCmdFlags(createCmd)
+
+	updateCmd := &cobra.Command{
+		Use:   "update CLASSIFIER_ID",
+		Short: "update classifier",
+		Long:  `retrain a custom classifier with new positive and/or negative examples zip files`,
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			classifiersFn.ClassifierID = args[0]
+			if len(classifiersFn.PositiveExamples) == 0 && classifiersFn.NegativeExamples == "" {
+				return errors.New("you must pass --positive-examples and/or --negative-examples")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return classifiersFn.printClassifier(classifiersFn.UpdateClassifier)
+		},
+	}
+	classifiersFn.addExamplesCmdFlags(updateCmd)
+	classifiersFn.addWaitCmdFlags(updateCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete CLASSIFIER_ID",
+		Short: "delete classifier",
+		Long:  `delete a custom classifier`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			classifiersFn.ClassifierID = args[0]
+
+			deletedData, err := classifiersFn.DeleteClassifier()
+			if err != nil {
+				return err
+			}
+
+			return common.PrintOutput(os.Stdout, &deletedData, classifiersFn.Output, deletedData.ToText)
+		},
+	}
+
+	classifiersCmd.PersistentFlags().StringVarP(&classifiersFn.Output, "output", "o", "text", common.OutputUsage())
+
+	classifiersCmd.AddCommand(listCmd)
+	classifiersCmd.AddCommand(getCmd)
+	classifiersCmd.AddCommand(createCmd)
+	classifiersCmd.AddCommand(updateCmd)
+	classifiersCmd.AddCommand(deleteCmd)
+
+	return classifiersCmd
+}
+
+func (classifiersFn *ClassifiersFn) printClassifier(classifierFunc func() (ClassifierData, error)) error {
+	classifierData, err := classifierFunc()
+	if err != nil {
+		return err
+	}
+
+	if classifiersFn.Wait {
+		classifierData, err = classifiersFn.WaitForClassifier()
+		if err != nil {
+			return err
+		}
+	}
+
+	return common.PrintOutput(os.Stdout, &classifierData, classifiersFn.Output, classifierData.ToText)
+}
+
+func (classifiersFn *ClassifiersFn) addExamplesCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().StringToStringVar(&classifiersFn.PositiveExamples, "positive-examples", map[string]string{}, "comma separated list of CLASS=ZIP_FILE positive examples, e.g., shoes=shoes.zip,hats=hats.zip")
+	cmd.Flags().StringVar(&classifiersFn.NegativeExamples, "negative-examples", "", "the zip file of negative examples")
+}
+
+func (classifiersFn *ClassifiersFn) addWaitCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&classifiersFn.Wait, "wait", false, "wait for the classifier training to complete")
+	cmd.Flags().IntVar(&classifiersFn.WaitTimeout, "wait-timeout", 1800, "the max seconds to wait for the classifier training to complete")
+	cmd.Flags().IntVar(&classifiersFn.PollInterval, "poll-interval", 15, "the seconds between classifier status checks while waiting")
+}
+
 func (classifyImageFn *ClassifyImageFn) classify(cmd *cobra.Command, args []string) error {
 	if classifyImageFn.StartServer {
-		http.HandleFunc("/", classifyImageFn.ClassifyHandler)
-		return http.ListenAndServe(fmt.Sprintf(":%d", classifyImageFn.Port), nil)
+		return classifyImageFn.ListenAndServe(classifyImageFn.handler())
+	} else if classifyImageFn.Stdin {
+		return classifyImageFn.ProcessLines(os.Stdin, os.Stdout, classifyImageFn.classifyLine)
 	} else {
 		classifyData, err := classifyImageFn.ClassifyImage()
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("%s\n", common.Flatten(&classifyData, classifyImageFn.Output, classifyData.ToText))
+		err = common.PrintOutput(os.Stdout, &classifyData, classifyImageFn.Output, classifyData.ToText)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// classifyLine classifies a line of the standard input as image URL
+func (classifyImageFn *ClassifyImageFn) classifyLine(line string) (string, error) {
+	lineFn := *classifyImageFn
+	lineFn.ImageURL = line
+	lineFn.ImageURLs = nil
+
+	classifyData, err := lineFn.ClassifyImage()
+	if err != nil {
+		return "", err
+	}
+
+	return common.Flatten(&classifyData, lineFn.Output, classifyData.ToText)
+}
+
+func (classifyImageFn *ClassifyImageFn) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", classifyImageFn.HealthzHandler)
+	mux.HandleFunc("/", classifyImageFn.ClassifyHandler)
+	return mux
+}
+
 func (classifyImageFn *ClassifyImageFn) addWatsonCmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&classifyImageFn.keys.watsonAPIKey, "watson-api-key", "", "watson API key")
 	cmd.PersistentFlags().StringVar(&classifyImageFn.keys.watsonAPIURL, "watson-api-url", "", "watson API URL")
@@ -114,14 +295,20 @@ func (classifyImageFn *ClassifyImageFn) addWatsonCmdFlags(cmd *cobra.Command) {
 
 func (classifyImageFn *ClassifyImageFn) addClassifyCmdFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&classifyImageFn.ImageURL, "image-url", "u", "", "the URL of the image to classify")
+	cmd.Flags().StringSliceVar(&classifyImageFn.ClassifierIDs, "classifier-ids", []string{}, "comma separated list of classifier IDs, e.g., default,food,explicit or custom classifier IDs")
+	cmd.Flags().StringSliceVar(&classifyImageFn.Owners, "owners", []string{}, "comma separated list of classifier owners, i.e., IBM and/or me")
+	cmd.Flags().Float64Var(&classifyImageFn.Threshold, "threshold", 0.0, "the minimum score a class must have to be returned by Watson, overrides --min-score when set")
+	cmd.Flags().StringVar(&classifyImageFn.AcceptLanguage, "accept-language", "", "the language of the class names, e.g., en, es, fr, ja")
 }
 
 func (classifyImageFn *ClassifyImageFn) initClassifyCmdInputFlags(args []string) error {
 	if len(args) == 1 {
 		classifyImageFn.ImageURL = args[0]
+	} else if len(args) > 1 {
+		classifyImageFn.ImageURLs = args
 	}
 
-	if classifyImageFn.ImageURL == "" {
+	if classifyImageFn.ImageURL == "" && len(classifyImageFn.ImageURLs) == 0 && !classifyImageFn.Stdin {
 		return errors.New(fmt.Sprintf("You must pass an image URL to classify"))
 	}
 
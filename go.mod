@@ -1,6 +1,6 @@
 module github.com/maximilien/knfun
 
-go 1.12
+go 1.16
 
 require (
 	cloud.google.com/go/vision v1.2.0
@@ -13,8 +13,12 @@ require (
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.4.0
 	github.com/watson-developer-cloud/go-sdk v1.0.0
-	google.golang.org/api v0.78.0 // indirect
+	go.etcd.io/bbolt v1.3.7
+	google.golang.org/api v0.78.0
+	google.golang.org/genproto v0.0.0-20220429170224-98d788798c3e
+	google.golang.org/protobuf v1.28.0
 	gopkg.in/yaml.v2 v2.2.4
 	gotest.tools v2.2.0+incompatible
+	k8s.io/client-go v0.0.0-20190226174127-78295b709ec6
 	knative.dev/client v0.9.0
 )
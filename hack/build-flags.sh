@@ -16,7 +16,7 @@ function build_flags() {
   local base="${1}"
   local now="$(date -u '+%Y-%m-%d %H:%M:%S')"
   local rev="$(git rev-parse --short HEAD)"
-  local pkg="github.com/maximilien/funcs/common"
+  local pkg="github.com/maximilien/knfun/funcs/common"
   local version="${TAG:-}"
   # Use vYYYYMMDD-local-<hash> for the version string, if not passed.
   if [[ -z "${version}" ]]; then
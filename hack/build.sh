@@ -16,7 +16,7 @@
 
 set -o pipefail
 
-source_dirs="funcs"
+source_dirs="funcs cmd"
 
 username=${USERNAME:-}
 cr_url=${CR_URL:-}
@@ -175,12 +175,24 @@ build_images() {
   echo "   🚧 🐳 twitter-fn"
   docker build --platform linux/amd64 -f ./funcs/twitter/Dockerfile -t ${cr_url}/${username}/twitter-fn .
 
+  echo "   🚧 🐳 mastodon-fn"
+  docker build --platform linux/amd64 -f ./funcs/mastodon/Dockerfile -t ${cr_url}/${username}/mastodon-fn .
+
+  echo "   🚧 🐳 reddit-fn"
+  docker build --platform linux/amd64 -f ./funcs/reddit/Dockerfile -t ${cr_url}/${username}/reddit-fn .
+
+  echo "   🚧 🐳 rss-fn"
+  docker build --platform linux/amd64 -f ./funcs/rss/Dockerfile -t ${cr_url}/${username}/rss-fn .
+
   echo "   🚧 🐳 watson-fn"
   docker build --platform linux/amd64 -f ./funcs/watson/Dockerfile -t ${cr_url}/${username}/watson-fn .
 
   echo "   🚧 🐳 gvision-fn"
   docker build --platform linux/amd64 -f ./funcs/gvision/Dockerfile -t ${cr_url}/${username}/gvision-fn .
 
+  echo "   🚧 🐳 nlu-fn"
+  docker build --platform linux/amd64 -f ./funcs/nlu/Dockerfile -t ${cr_url}/${username}/nlu-fn .
+
   echo "   🚧 🐳 summary-fn"
   docker build --platform linux/amd64 -f ./funcs/summary/Dockerfile -t ${cr_url}/${username}/summary-fn .
 }
@@ -191,12 +203,24 @@ push_images() {
   echo "   📤 🐳 twitter-fn"
   docker push ${cr_url}/${username}/twitter-fn
 
+  echo "   📤 🐳 mastodon-fn"
+  docker push ${cr_url}/${username}/mastodon-fn
+
+  echo "   📤 🐳 reddit-fn"
+  docker push ${cr_url}/${username}/reddit-fn
+
+  echo "   📤 🐳 rss-fn"
+  docker push ${cr_url}/${username}/rss-fn
+
   echo "   📤 🐳 watson-fn"
   docker push ${cr_url}/${username}/watson-fn
 
   echo "   📤 🐳 gvision-fn"
   docker push ${cr_url}/${username}/gvision-fn
 
+  echo "   📤 🐳 nlu-fn"
+  docker push ${cr_url}/${username}/nlu-fn
+
   echo "   📤 🐳 summary-fn"
   docker push ${cr_url}/${username}/summary-fn
 }
@@ -207,12 +231,24 @@ scan_images() {
   echo "   🔒 🐳 twitter-fn"
   docker scan ${cr_url}/${username}/twitter-fn
 
+  echo "   🔒 🐳 mastodon-fn"
+  docker scan ${cr_url}/${username}/mastodon-fn
+
+  echo "   🔒 🐳 reddit-fn"
+  docker scan ${cr_url}/${username}/reddit-fn
+
+  echo "   🔒 🐳 rss-fn"
+  docker scan ${cr_url}/${username}/rss-fn
+
   echo "   🔒 🐳 watson-fn"
   docker scan ${cr_url}/${username}/watson-fn
 
   echo "   🔒 🐳 gvision-fn"
   docker scan ${cr_url}/${username}/gvision-fn
 
+  echo "   🔒 🐳 nlu-fn"
+  docker scan ${cr_url}/${username}/nlu-fn
+
   echo "   🔒 🐳 summary-fn"
   docker scan ${cr_url}/${username}/summary-fn
 }
@@ -242,10 +278,15 @@ source_format() {
 
 go_build() {
   echo "🚧 Compile"
-  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o twitter-fn ./funcs/twitter/...
-  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o watson-fn ./funcs/watson/...
-  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o gvision-fn ./funcs/gvision/...
-  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o summary-fn ./funcs/summary/...
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o twitter-fn ./cmd/twitter-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o mastodon-fn ./cmd/mastodon-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o reddit-fn ./cmd/reddit-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o rss-fn ./cmd/rss-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o watson-fn ./cmd/watson-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o gvision-fn ./cmd/gvision-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o nlu-fn ./cmd/nlu-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o summary-fn ./cmd/summary-fn
+  go build -mod=vendor -ldflags "$(build_flags $(basedir))" -o knfun ./cmd/knfun
 }
 
 go_test() {
@@ -415,7 +456,7 @@ with the following options:
 -f  --fast                    Only compile (without dep update, formatting, testing, doc gen)
 -t  --test                    Run tests when used with --fast or --watch
 -c  --codegen                 Runs formatting, doc gen and update without compiling/testing
--d  --docker-images           Generates Docker images for each funcs (twitter-fn, watson-fn, summary-fn)
+-d  --docker-images           Generates Docker images for each funcs (twitter-fn, mastodon-fn, reddit-fn, rss-fn, watson-fn, gvision-fn, nlu-fn, summary-fn)
 -w  --watch                   Watch for source changes and recompile in fast mode
 -x  --all                     Build binaries for all platforms
 -h  --help                    Display this help message